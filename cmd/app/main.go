@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"nikki-noceps/serviceCatalogue/config"
 	"nikki-noceps/serviceCatalogue/internal/services"
+	customcontext "nikki-noceps/serviceCatalogue/pkg/context"
 	"nikki-noceps/serviceCatalogue/pkg/logger"
 	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
 	"nikki-noceps/serviceCatalogue/pkg/migrations"
@@ -68,6 +69,12 @@ func main() {
 		return
 	}
 
+	// Repairs catalogue changes left half applied
+	go svc.RunReconciler(customcontext.NewCustomContext(&customcontext.CustomContextConfig{
+		Ctx:    ctx,
+		Logger: logger.WITH(tag.NewAnyTag("job", "reconciler")),
+	}))
+
 	router, err := presentation.NewRouter(ctx, cfg, svc)
 	if err != nil {
 		logger.FATAL("failed to create router", tag.NewErrorTag(err))
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -10,13 +11,57 @@ import (
 var (
 	Username string = "balrog"
 	Password string = "youshallnotpass"
+	// DefaultOrgId is the organization of the built in principal, documents stored before organizations
+	// were introduced belong to it
+	DefaultOrgId string = "default"
+	// RoleAdmin is the role of the principals managing the catalogue, like its attributes schema. The built in
+	// principal has it.
+	RoleAdmin string = "admin"
+)
+
+// Supported values for Database.Driver
+const (
+	DriverElasticSearch = "elasticsearch"
+	DriverMemory        = "memory"
+	DriverSQLite        = "sqlite"
 )
 
 type (
 	Configuration struct {
-		App           App           `yaml:"App"`
-		Server        Server        `yaml:"Server"`
-		ElasticSearch ElasticSearch `yaml:"Database"`
+		App        App        `yaml:"App"`
+		Server     Server     `yaml:"Server"`
+		Database   Database   `yaml:"Database"`
+		Reconciler Reconciler `yaml:"Reconciler"`
+		Auth       Auth       `yaml:"Auth"`
+		// Deprecations announces the endpoints being phased out
+		Deprecations Deprecations `yaml:"Deprecations"`
+	}
+
+	// Deprecations tells when the deprecated endpoints were, or will be, deprecated and when they are removed.
+	// An endpoint is only announced as deprecated once its DeprecatedAt is set.
+	Deprecations struct {
+		Search Deprecation `yaml:"Search"`
+	}
+
+	// Deprecation is announced through the Deprecation header from DeprecatedAt and the Sunset header when SunsetAt
+	// is set, both are RFC3339 timestamps
+	Deprecation struct {
+		DeprecatedAt time.Time `yaml:"DeprecatedAt"`
+		SunsetAt     time.Time `yaml:"SunsetAt"`
+	}
+
+	// Auth lists the principals allowed in besides the built in one
+	Auth struct {
+		Principals []Principal `yaml:"Principals"`
+	}
+
+	// Principal is a basic authentication user, every principal belongs to an organization and only
+	// sees the catalogue of that organization. Roles grant it more, see RoleAdmin.
+	Principal struct {
+		Username string   `yaml:"Username"`
+		Password string   `yaml:"Password"`
+		OrgId    string   `yaml:"OrgId"`
+		Roles    []string `yaml:"Roles"`
 	}
 
 	Server struct {
@@ -28,6 +73,27 @@ type (
 		Environment string `yaml:"Environment"`
 	}
 
+	// Database selects the storage backend through Driver. Connection details of the
+	// backend are kept inline so that the existing Database keys keep working.
+	Database struct {
+		Driver        string `yaml:"Driver"`
+		ElasticSearch `yaml:",inline"`
+		SQLite        `yaml:",inline"`
+	}
+
+	SQLite struct {
+		Path string `yaml:"Path"`
+	}
+
+	// Reconciler configures the background job repairing half applied catalogue changes.
+	// Changes younger than GracePeriod are left alone as their request may still be in flight. A change
+	// failing to reconcile is retried with a backoff and set aside after MaxAttempts.
+	Reconciler struct {
+		Interval    time.Duration `yaml:"Interval"`
+		GracePeriod time.Duration `yaml:"GracePeriod"`
+		MaxAttempts int           `yaml:"MaxAttempts"`
+	}
+
 	ElasticSearch struct {
 		Host     string `yaml:"Host"`
 		Port     string `yaml:"Port"`
@@ -56,10 +122,30 @@ func addDefaults(config *Configuration) {
 	if config.Server.Port == "" {
 		config.Server.Port = "8080"
 	}
-	if config.ElasticSearch.Host == "" {
-		config.ElasticSearch.Host = "localhost"
+	if config.Database.Driver == "" {
+		config.Database.Driver = DriverElasticSearch
+	}
+	if config.Database.Host == "" {
+		config.Database.Host = "localhost"
+	}
+	if config.Database.Port == "" {
+		config.Database.Port = "9200"
+	}
+	if config.Database.Driver == DriverSQLite && config.Database.Path == "" {
+		config.Database.Path = "serviceCatalogue.db"
+	}
+	if config.Reconciler.Interval == 0 {
+		config.Reconciler.Interval = time.Minute
+	}
+	if config.Reconciler.GracePeriod == 0 {
+		config.Reconciler.GracePeriod = 30 * time.Second
+	}
+	if config.Reconciler.MaxAttempts == 0 {
+		config.Reconciler.MaxAttempts = 10
 	}
-	if config.ElasticSearch.Port == "" {
-		config.ElasticSearch.Port = "9200"
+	for i := range config.Auth.Principals {
+		if config.Auth.Principals[i].OrgId == "" {
+			config.Auth.Principals[i].OrgId = DefaultOrgId
+		}
 	}
 }
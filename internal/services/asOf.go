@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"time"
+)
+
+var (
+	keyUpdatedAt = "updatedAt"
+	keyCreatedAt = "createdAt"
+	// maxSnapshotServices caps the services reconstructed for a point in time listing
+	maxSnapshotServices = 10000
+)
+
+// SnapshotTooLargeErr is returned when the catalogue at a point in time has more services than can be
+// reconstructed, the listing would silently miss services otherwise
+var SnapshotTooLargeErr error = fmt.Errorf("SNAPSHOT_TOO_LARGE")
+
+// FetchServiceByIdAsOf reconstructs a service as it was at asOf. That is the live document when it was last updated
+// before asOf, otherwise the archived version which was live at asOf. Deleted services are found through their
+// archived versions as well.
+func (svc *Service) FetchServiceByIdAsOf(cctx context.CustomContext, serviceId string, asOf string) (*ServiceCatalogue, error) {
+	asOf = utcTimestamp(asOf)
+	live, err := svc.FetchServiceById(cctx, serviceId)
+	if err == nil && !isAfter(live.UpdatedAt, asOf) {
+		if isAfter(live.CreatedAt, asOf) {
+			return nil, NoDocumentFoundErr
+		}
+		return live, nil
+	}
+	if err != nil && !errors.Is(err, NoDocumentFoundErr) {
+		return nil, err
+	}
+
+	svcVersion, err := svc.fetchServiceCatalogueVersion(cctx, &database.Body{
+		Query: liveVersionsAtQuery(asOf, &database.Query{Term: &database.TermQuery{keyParentId: {Value: serviceId}}}),
+		Sort:  []*database.SortField{{keyVersion: database.Desc}},
+		Size:  database.HitsSize(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	svcCat := svcVersion.toServiceCatalogue()
+	first, err := svc.fetchServiceCatalogueVersion(cctx, &database.Body{
+		Query: &database.Query{
+			Bool: &database.BoolQuery{
+				Must: []database.Query{
+					{Term: &database.TermQuery{keyParentId: {Value: serviceId}}},
+					{Term: &database.TermQuery{keyVersion: {Value: 1}}},
+				},
+			},
+		},
+	})
+	if err != nil && !errors.Is(err, NoDocumentFoundErr) {
+		return nil, err
+	}
+	if first != nil {
+		svcCat.CreatedAt = first.CreatedAt
+		svcCat.CreatedBy = first.CreatedBy
+	}
+	return svcCat, nil
+}
+
+// snapshotAt reconstructs the catalogue as it was at asOf into an in-memory repository, so the regular
+// list queries can run against it unchanged. Catalogues of more than maxSnapshotServices services at asOf
+// fail with SnapshotTooLargeErr, see snapshotHits.
+func (svc *Service) snapshotAt(cctx context.CustomContext, asOf string) (*Service, error) {
+	liveHits, err := svc.snapshotHits(cctx, &database.Query{
+		Range: &database.RangeQuery{
+			keyUpdatedAt: {
+				Lte: asOf,
+			},
+		},
+	}, database.ServiceCatalogueIndex)
+	if err != nil {
+		return nil, err
+	}
+	lives := decodeServiceCatalogueHits(cctx, liveHits)
+
+	versionHits, err := svc.snapshotHits(cctx, liveVersionsAtQuery(asOf, nil), database.ServiceCatalogueVersionIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first versions carry when and by whom the services were created
+	firstVersionHits, err := svc.snapshotHits(cctx, &database.Query{
+		Bool: &database.BoolQuery{
+			Must: []database.Query{
+				{Term: &database.TermQuery{keyVersion: {Value: 1}}},
+				{Range: &database.RangeQuery{keyCreatedAt: {Lte: asOf}}},
+			},
+		},
+	}, database.ServiceCatalogueVersionIndex)
+	if err != nil {
+		return nil, err
+	}
+	created := map[string]*ServiceCatalogueVersion{}
+	for _, first := range decodeServiceCatalogueVersionHits(cctx, firstVersionHits) {
+		created[first.ParentId] = first
+	}
+
+	snapshot := &Service{repo: database.NewMemoryClient(), reconciler: svc.reconciler}
+	for _, svcVersion := range decodeServiceCatalogueVersionHits(cctx, versionHits) {
+		svcCat := svcVersion.toServiceCatalogue()
+		if first, ok := created[svcCat.ServiceId]; ok {
+			svcCat.CreatedAt = first.CreatedAt
+			svcCat.CreatedBy = first.CreatedBy
+		}
+		lives = append(lives, svcCat)
+	}
+	for _, svcCat := range lives {
+		svcCat.LabelPairs = labelPairs(svcCat.Labels)
+		svcCatBytes, err := json.Marshal(svcCat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse input: %w", err)
+		}
+		if _, err := snapshot.repo.CreateDocument(cctx, svcCatBytes, database.ServiceCatalogueIndex); err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// snapshotHits fetches all the documents matching the query for a snapshot. More than maxSnapshotServices
+// matching documents can not be fetched at once and fail with SnapshotTooLargeErr instead of being dropped.
+func (svc *Service) snapshotHits(cctx context.CustomContext, query *database.Query, index string) ([]any, error) {
+	result, err := svc.search(cctx, &database.Body{
+		Query:          query,
+		Size:           database.HitsSize(maxSnapshotServices),
+		TrackTotalHits: true,
+	}, index)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return nil, err
+	}
+	if result.Total > len(result.Hits) {
+		return nil, fmt.Errorf("%w: %d documents of %s match, at most %d can be reconstructed", SnapshotTooLargeErr, result.Total, index, maxSnapshotServices)
+	}
+	return result.Hits, nil
+}
+
+// liveVersionsAtQuery matches archived versions which were live at asOf, that is created before and
+// decomissioned after it
+func liveVersionsAtQuery(asOf string, query *database.Query) *database.Query {
+	must := []database.Query{{Range: &database.RangeQuery{keyCreatedAt: {Lte: asOf}}}}
+	if query != nil {
+		must = append(must, *query)
+	}
+	return &database.Query{
+		Bool: &database.BoolQuery{
+			Must:    must,
+			MustNot: []database.Query{{Range: &database.RangeQuery{keyDecomissionedAt: {Lte: asOf}}}},
+		},
+	}
+}
+
+// isAfter compares two RFC3339 timestamps, unparsable timestamps are never after
+func isAfter(timestamp string, asOf string) bool {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return false
+	}
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		return false
+	}
+	return t.After(at)
+}
+
+// utcTimestamp converts an RFC3339 timestamp to UTC, the format all the timestamps are stored in
+func utcTimestamp(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.UTC().Format(time.RFC3339)
+}
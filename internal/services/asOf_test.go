@@ -0,0 +1,92 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServicesAsOf(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, _ := newTestService(t)
+
+	payments, err := svc.CreateServiceCatalogue(cctx, &ServiceCatalogue{
+		ServiceId: "payments", Name: "payments", Description: "processes card payments", Version: 1,
+		CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-01T00:00:00Z", CreatedBy: "alice", UpdatedBy: "alice",
+	})
+	require.NoError(t, err)
+	_, err = svc.CreateServiceCatalogue(cctx, &ServiceCatalogue{
+		ServiceId: "ledger", Name: "ledger", Description: "double entry ledger", Version: 1,
+		CreatedAt: "2024-05-01T00:00:00Z", UpdatedAt: "2024-05-01T00:00:00Z", CreatedBy: "bob", UpdatedBy: "bob",
+	})
+	require.NoError(t, err)
+	_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: payments.ServiceId, Name: "acquiring", UpdatedAt: "2024-06-01T00:00:00Z", UpdatedBy: "carol"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, svc.DeleteService(cctx, payments.ServiceId, "dave", nil))
+
+	t.Run("fetch", func(t *testing.T) {
+		svcCat, err := svc.FetchServiceByIdAsOf(cctx, payments.ServiceId, "2024-03-01T02:00:00+02:00")
+		require.NoError(t, err)
+		assert.Equal(t, "payments", svcCat.Name)
+		assert.Equal(t, 1, svcCat.Version)
+		assert.Equal(t, "2024-01-01T00:00:00Z", svcCat.CreatedAt)
+		assert.Equal(t, "alice", svcCat.CreatedBy)
+
+		// deleted since
+		svcCat, err = svc.FetchServiceByIdAsOf(cctx, payments.ServiceId, "2024-07-01T00:00:00Z")
+		require.NoError(t, err)
+		assert.Equal(t, "acquiring", svcCat.Name)
+		assert.Equal(t, 2, svcCat.Version)
+		assert.Equal(t, "carol", svcCat.UpdatedBy)
+
+		_, err = svc.FetchServiceByIdAsOf(cctx, payments.ServiceId, "2023-01-01T00:00:00Z")
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+		_, err = svc.FetchServiceByIdAsOf(cctx, "ledger", "2024-04-01T00:00:00Z")
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		from, size := 0, 10
+		listParams := &ListParameters{
+			From:       &from,
+			Size:       &size,
+			TimeWindow: &TimeWindow{After: "2023-01-01T00:00:00Z", Before: "2025-01-01T00:00:00Z"},
+		}
+		listParams.AddDefaultsIfEmpty()
+
+		listParams.AsOf = "2024-05-15T00:00:00Z"
+		svcCats, _, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		require.Len(t, svcCats, 2)
+		assert.Equal(t, "ledger", svcCats[0].Name)
+		assert.Equal(t, "payments", svcCats[1].Name)
+
+		listParams.AsOf = "2024-04-01T00:00:00Z"
+		svcCats, _, err = svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		require.Len(t, svcCats, 1)
+		assert.Equal(t, "payments", svcCats[0].Name)
+	})
+
+	t.Run("list more services than can be reconstructed", func(t *testing.T) {
+		_, err := svc.CreateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: "payouts", Name: "payouts", Description: "pays out card payments", Version: 1,
+			CreatedAt: "2024-02-01T00:00:00Z", UpdatedAt: "2024-02-01T00:00:00Z", CreatedBy: "erin", UpdatedBy: "erin",
+		})
+		require.NoError(t, err)
+		defer func(max int) { maxSnapshotServices = max }(maxSnapshotServices)
+		maxSnapshotServices = 1
+
+		listParams := &ListParameters{
+			TimeWindow: &TimeWindow{After: "2023-01-01T00:00:00Z", Before: "2025-01-01T00:00:00Z"},
+			AsOf:       "2024-05-15T00:00:00Z",
+		}
+		listParams.AddDefaultsIfEmpty()
+		_, _, err = svc.ListAllServices(cctx, listParams)
+		assert.ErrorIs(t, err, SnapshotTooLargeErr, "services are not dropped silently")
+		_, err = svc.ListFacets(cctx, listParams)
+		assert.ErrorIs(t, err, SnapshotTooLargeErr)
+	})
+}
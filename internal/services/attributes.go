@@ -0,0 +1,332 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/jsonschema"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var (
+	keyAttributes      = "attributes"
+	attributesSchemaId = "attributes"
+	// maxAttributesSchemas bounds the schemas checked for conflicting attribute types, one per organization
+	maxAttributesSchemas = 1000
+	attributeNameRegex   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+)
+
+// InvalidAttributesSchemaErr is returned when an uploaded attributes schema can not be used
+var InvalidAttributesSchemaErr error = fmt.Errorf("INVALID_ATTRIBUTES_SCHEMA")
+
+// InvalidAttributesErr is returned when the attributes of a service do not match the attributes schema
+var InvalidAttributesErr error = fmt.Errorf("INVALID_ATTRIBUTES")
+
+// InvalidAttributeFilterErr is returned when listing filters or sorts on an attribute the schema does not declare
+var InvalidAttributeFilterErr error = fmt.Errorf("INVALID_ATTRIBUTE_FILTER")
+
+// SaveAttributesSchema replaces the schema the attributes of the services of the organization are validated
+// against. The declared attributes are mapped in the catalogue and version indices so they can be filtered and
+// sorted on. A mapped attribute keeps its type, attributes dropped from the schema stay mapped. The indices are
+// shared by the organizations, an attribute has the same type in the schemas of all of them. Services are only
+// validated against the schema when they are written.
+func (svc *Service) SaveAttributesSchema(cctx context.CustomContext, schemaBytes []byte, userId string) (*AttributesSchema, error) {
+	schema, err := parseAttributesSchema(schemaBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+	current, err := svc.FetchAttributesSchema(cctx)
+	switch {
+	case err == nil:
+		version = current.Version + 1
+	case !errors.Is(err, NoDocumentFoundErr):
+		return nil, err
+	}
+	if err := svc.checkMappedAttributeTypes(cctx, schema); err != nil {
+		return nil, err
+	}
+
+	mapping, err := json.Marshal(attributesMapping(schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mapping: %w", err)
+	}
+	for _, index := range []string{database.ServiceCatalogueIndex, database.ServiceCatalogueVersionIndex} {
+		if err := svc.repo.PutMapping(cctx, index, mapping); err != nil {
+			cctx.Logger().ERROR("failed to map attributes", tag.NewAnyTag("index", index), tag.NewErrorTag(err))
+			return nil, err
+		}
+	}
+
+	attributesSchema := &AttributesSchema{
+		Version:   version,
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		UpdatedBy: userId,
+		OrgId:     orgOf(cctx),
+		parsed:    schema,
+	}
+	if err := json.Unmarshal(schemaBytes, &attributesSchema.Schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	docBytes, err := json.Marshal(attributesSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input: %w", err)
+	}
+	if err := svc.repo.IndexDocument(cctx, docBytes, database.AttributesSchemaIndex, attributesSchemaDocId(orgOf(cctx))); err != nil {
+		return nil, err
+	}
+	return attributesSchema, nil
+}
+
+// FetchAttributesSchema returns the current attributes schema of the organization, NoDocumentFoundErr when
+// none was uploaded yet
+func (svc *Service) FetchAttributesSchema(cctx context.CustomContext) (*AttributesSchema, error) {
+	result, err := svc.search(cctx, &database.Body{Size: database.HitsSize(1)}, database.AttributesSchemaIndex)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	if len(result.Hits) == 0 {
+		return nil, NoDocumentFoundErr
+	}
+	hitMap, _ := result.Hits[0].(map[string]any)
+	return decodeAttributesSchema(cctx, hitMap)
+}
+
+// checkMappedAttributeTypes checks the schema against the schemas of every organization, as the attributes
+// of all of them are mapped in the same indices
+func (svc *Service) checkMappedAttributeTypes(cctx context.CustomContext, schema *jsonschema.Schema) error {
+	result, err := svc.repo.SearchAndGetHits(cctx, &database.Body{Size: database.HitsSize(maxAttributesSchemas)}, database.AttributesSchemaIndex)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return fmt.Errorf("failed to search: %w", err)
+	}
+	for _, hit := range result.Hits {
+		hitMap, _ := hit.(map[string]any)
+		current, err := decodeAttributesSchema(cctx, hitMap)
+		if err != nil {
+			return err
+		}
+		if err := checkAttributeTypes(current.parsed, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attributesSchemaDocId is the document id of the attributes schema of the organization, the default
+// organization keeps the id used before organizations were introduced
+func attributesSchemaDocId(orgId string) string {
+	if orgId == config.DefaultOrgId {
+		return attributesSchemaId
+	}
+	return attributesSchemaId + "-" + orgId
+}
+
+func decodeAttributesSchema(cctx context.CustomContext, hitMap map[string]any) (*AttributesSchema, error) {
+	attributesSchema := &AttributesSchema{}
+	if err := mapstructure.Decode(hitMap["_source"], attributesSchema); err != nil {
+		cctx.Logger().DEBUG("failed to decode hit", tag.NewErrorTag(err))
+		return nil, fmt.Errorf("failed to decode hit: %w", err)
+	}
+	schemaBytes, err := json.Marshal(attributesSchema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode schema: %w", err)
+	}
+	attributesSchema.parsed, err = jsonschema.Parse(schemaBytes)
+	if err != nil {
+		return nil, err
+	}
+	return attributesSchema, nil
+}
+
+// parseAttributesSchema parses the schema and checks it describes an object of attributes which can be
+// mapped: every attribute is a scalar or an array of scalars and its name can be used as a field name.
+func parseAttributesSchema(schemaBytes []byte) (*jsonschema.Schema, error) {
+	schema, err := jsonschema.Parse(schemaBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", InvalidAttributesSchemaErr, err)
+	}
+	if schema.Type != jsonschema.TypeObject {
+		return nil, fmt.Errorf("%w: the schema must describe an object", InvalidAttributesSchemaErr)
+	}
+	for name, property := range schema.Properties {
+		if !attributeNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("%w: invalid attribute name %q: must be alphanumeric or `_` and start with a letter", InvalidAttributesSchemaErr, name)
+		}
+		if property.Type == jsonschema.TypeArray {
+			property = property.Items
+		}
+		if property.Type == jsonschema.TypeObject || property.Type == jsonschema.TypeArray {
+			return nil, fmt.Errorf("%w: attribute %q must be a scalar or an array of scalars", InvalidAttributesSchemaErr, name)
+		}
+	}
+	return schema, nil
+}
+
+// attributeFieldType is the elasticsearch field type an attribute is mapped to. Strings are keywords so they
+// filter and sort on the exact value, arrays are mapped like their items.
+func attributeFieldType(property *jsonschema.Schema) string {
+	if property.Type == jsonschema.TypeArray {
+		property = property.Items
+	}
+	switch property.Type {
+	case jsonschema.TypeInteger:
+		return "long"
+	case jsonschema.TypeNumber:
+		return "double"
+	case jsonschema.TypeBoolean:
+		return "boolean"
+	}
+	if property.Format == jsonschema.FormatDateTime || property.Format == jsonschema.FormatDate {
+		return "date"
+	}
+	return "keyword"
+}
+
+// attributesMapping maps the declared attributes, attributes which are not declared are not indexed
+func attributesMapping(schema *jsonschema.Schema) map[string]any {
+	properties := map[string]any{}
+	for name, property := range schema.Properties {
+		properties[name] = map[string]any{"type": attributeFieldType(property)}
+	}
+	return map[string]any{
+		"properties": map[string]any{
+			keyAttributes: map[string]any{
+				"type":       "object",
+				"dynamic":    false,
+				"properties": properties,
+			},
+		},
+	}
+}
+
+// checkAttributeTypes rejects a schema changing the type an attribute is mapped to, mapped fields can not change
+func checkAttributeTypes(current *jsonschema.Schema, schema *jsonschema.Schema) error {
+	for name, property := range schema.Properties {
+		before, ok := current.Properties[name]
+		if !ok {
+			continue
+		}
+		if from, to := attributeFieldType(before), attributeFieldType(property); from != to {
+			return fmt.Errorf("%w: attribute %q is mapped as %s and can not change to %s", InvalidAttributesSchemaErr, name, from, to)
+		}
+	}
+	return nil
+}
+
+// validateAttributes validates the attributes of a service against the attributes schema. Without a schema
+// services can not have attributes.
+func (svc *Service) validateAttributes(cctx context.CustomContext, attributes map[string]any) error {
+	attributesSchema, err := svc.FetchAttributesSchema(cctx)
+	if errors.Is(err, NoDocumentFoundErr) {
+		if len(attributes) > 0 {
+			return fmt.Errorf("%w: no attributes schema has been uploaded", InvalidAttributesErr)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+	if err := attributesSchema.parsed.Validate(attributes); err != nil {
+		return fmt.Errorf("%w: %w", InvalidAttributesErr, err)
+	}
+	return nil
+}
+
+// attributesUpdate replaces the current attributes with the new ones, like labelsUpdate
+func attributesUpdate(current map[string]any, attributes map[string]any) map[string]any {
+	update := map[string]any{}
+	for key := range current {
+		update[key] = nil
+	}
+	maps.Copy(update, attributes)
+	return update
+}
+
+// compactAttributes drops the attributes nulled by attributesUpdate
+func compactAttributes(attributes map[string]any) map[string]any {
+	maps.DeleteFunc(attributes, func(_ string, value any) bool {
+		return value == nil
+	})
+	if len(attributes) == 0 {
+		return nil
+	}
+	return attributes
+}
+
+// attributesQuery compiles the attribute filters of the listing to terms queries, the values are converted to
+// the type of the attribute. Filtered and sorted attributes must be declared in the schema.
+func (svc *Service) attributesQuery(cctx context.CustomContext, listParams *ListParameters) ([]database.Query, error) {
+	sorted := []string{}
+	for _, sortField := range listParams.Sort {
+		for field := range *sortField {
+			if name, ok := strings.CutPrefix(field, keyAttributes+"."); ok {
+				sorted = append(sorted, name)
+			}
+		}
+	}
+	if len(listParams.Attributes) == 0 && len(sorted) == 0 {
+		return nil, nil
+	}
+
+	attributesSchema, err := svc.FetchAttributesSchema(cctx)
+	if err != nil && !errors.Is(err, NoDocumentFoundErr) {
+		return nil, err
+	}
+	declared := map[string]*jsonschema.Schema{}
+	if attributesSchema != nil {
+		declared = attributesSchema.parsed.Properties
+	}
+
+	for _, name := range sorted {
+		if _, ok := declared[name]; !ok {
+			return nil, fmt.Errorf("%w: can not sort on undeclared attribute %q", InvalidAttributeFilterErr, name)
+		}
+	}
+	must := []database.Query{}
+	for _, name := range mapKeys(listParams.Attributes) {
+		property, ok := declared[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: can not filter on undeclared attribute %q", InvalidAttributeFilterErr, name)
+		}
+		values := []any{}
+		for _, value := range listParams.Attributes[name] {
+			typed, err := attributeValue(property, value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid value %q for attribute %q: %w", InvalidAttributeFilterErr, value, name, err)
+			}
+			values = append(values, typed)
+		}
+		must = append(must, database.Query{Terms: &database.TermsQuery{keyAttributes + "." + name: values}})
+	}
+	return must, nil
+}
+
+// attributeValue converts a query parameter value to the type of the attribute
+func attributeValue(property *jsonschema.Schema, value string) (any, error) {
+	if property.Type == jsonschema.TypeArray {
+		property = property.Items
+	}
+	switch property.Type {
+	case jsonschema.TypeInteger, jsonschema.TypeNumber:
+		return strconv.ParseFloat(value, 64)
+	case jsonschema.TypeBoolean:
+		return strconv.ParseBool(value)
+	}
+	return value, nil
+}
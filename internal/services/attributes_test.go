@@ -0,0 +1,171 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAttributesSchema = []byte(`{
+	"type": "object",
+	"additionalProperties": false,
+	"properties": {
+		"costCenter": {"type": "string", "pattern": "^cc-[0-9]+$"},
+		"repoUrl": {"type": "string", "format": "uri"},
+		"tier": {"type": "integer", "minimum": 1, "maximum": 3},
+		"launchedAt": {"type": "string", "format": "date"},
+		"regions": {"type": "array", "items": {"type": "string"}}
+	}
+}`)
+
+func TestAttributesSchema(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, _ := newTestService(t)
+
+	_, err := svc.FetchAttributesSchema(cctx)
+	assert.ErrorIs(t, err, NoDocumentFoundErr)
+
+	for name, schema := range map[string]string{
+		"not json schema":     `{"type": "object", "properties": {"tier": {"type": "integer", "multipleOf": 2}}}`,
+		"not an object":       `{"type": "string"}`,
+		"nested object":       `{"type": "object", "properties": {"owner": {"type": "object"}}}`,
+		"invalid field name":  `{"type": "object", "properties": {"cost.center": {"type": "string"}}}`,
+		"array of arrays":     `{"type": "object", "properties": {"matrix": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}}}}`,
+		"invalid json schema": `{"type": "object"`,
+	} {
+		_, err := svc.SaveAttributesSchema(cctx, []byte(schema), "admin")
+		assert.ErrorIs(t, err, InvalidAttributesSchemaErr, name)
+	}
+
+	saved, err := svc.SaveAttributesSchema(cctx, testAttributesSchema, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, 1, saved.Version)
+
+	fetched, err := svc.FetchAttributesSchema(cctx)
+	require.NoError(t, err)
+	assert.Equal(t, saved.Schema, fetched.Schema)
+	assert.Equal(t, "admin", fetched.UpdatedBy)
+
+	t.Run("mapped attributes keep their type", func(t *testing.T) {
+		_, err := svc.SaveAttributesSchema(cctx, []byte(`{"type": "object", "properties": {"tier": {"type": "string"}}}`), "admin")
+		assert.ErrorIs(t, err, InvalidAttributesSchemaErr)
+
+		saved, err := svc.SaveAttributesSchema(cctx, testAttributesSchema, "admin")
+		require.NoError(t, err)
+		assert.Equal(t, 2, saved.Version)
+	})
+}
+
+func TestAttributesMapping(t *testing.T) {
+	schema, err := parseAttributesSchema(testAttributesSchema)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"properties": map[string]any{
+			"attributes": map[string]any{
+				"type":    "object",
+				"dynamic": false,
+				"properties": map[string]any{
+					"costCenter": map[string]any{"type": "keyword"},
+					"repoUrl":    map[string]any{"type": "keyword"},
+					"tier":       map[string]any{"type": "long"},
+					"launchedAt": map[string]any{"type": "date"},
+					"regions":    map[string]any{"type": "keyword"},
+				},
+			},
+		},
+	}, attributesMapping(schema))
+}
+
+func TestServiceAttributes(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, payments := newTestService(t)
+
+	create := func(name string, attributes map[string]any) (*ServiceCatalogue, error) {
+		return svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service with attributes", Attributes: attributes, CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+	}
+
+	_, err := create("ledger", map[string]any{"costCenter": "cc-1"})
+	assert.ErrorIs(t, err, InvalidAttributesErr, "services have no attributes without a schema")
+
+	_, err = svc.SaveAttributesSchema(cctx, testAttributesSchema, "admin")
+	require.NoError(t, err)
+
+	_, err = create("ledger", map[string]any{"costCenter": "1", "tier": 1.5, "owner": "x"})
+	assert.ErrorIs(t, err, InvalidAttributesErr)
+	assert.ErrorContains(t, err, "/costCenter: must match the pattern ^cc-[0-9]+$; /owner: is not allowed; /tier: must be of type integer")
+
+	ledger, err := create("ledger", map[string]any{"costCenter": "cc-1", "tier": float64(1), "regions": []any{"eu", "us"}})
+	require.NoError(t, err)
+	_, err = create("billing", map[string]any{"costCenter": "cc-2", "tier": float64(2), "repoUrl": "https://github.com/acme/billing"})
+	require.NoError(t, err)
+
+	list := func(attributes map[string][]string, sort ...*database.SortField) ([]string, error) {
+		listParams := &ListParameters{Attributes: attributes, Sort: sort}
+		listParams.AddDefaultsIfEmpty()
+		require.NoError(t, listParams.Validate())
+		svcCats, _, err := svc.ListAllServices(cctx, listParams)
+		names := []string{}
+		for _, svcCat := range svcCats {
+			names = append(names, svcCat.Name)
+		}
+		return names, err
+	}
+
+	t.Run("filter and sort", func(t *testing.T) {
+		names, err := list(map[string][]string{"tier": {"2"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"billing"}, names)
+
+		names, err = list(map[string][]string{"costCenter": {"cc-1", "cc-2"}, "regions": {"eu"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ledger"}, names)
+
+		names, err = list(nil, &database.SortField{"attributes.tier": database.Desc})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"billing", "ledger", "payments"}, names)
+
+		_, err = list(map[string][]string{"owner": {"x"}})
+		assert.ErrorIs(t, err, InvalidAttributeFilterErr)
+		_, err = list(map[string][]string{"tier": {"high"}})
+		assert.ErrorIs(t, err, InvalidAttributeFilterErr)
+		_, err = list(nil, &database.SortField{"attributes.owner": database.Asc})
+		assert.ErrorIs(t, err, InvalidAttributeFilterErr)
+	})
+
+	t.Run("updates replace the attributes", func(t *testing.T) {
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: ledger.ServiceId, Attributes: map[string]any{"tier": float64(4)}, UpdatedBy: "bob"}, nil)
+		assert.ErrorIs(t, err, InvalidAttributesErr)
+
+		_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: ledger.ServiceId, Attributes: map[string]any{"costCenter": "cc-3"}, UpdatedBy: "bob"}, nil)
+		require.NoError(t, err)
+		svcCat, err := svc.FetchServiceById(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"costCenter": "cc-3"}, svcCat.Attributes)
+
+		// other updates leave the attributes alone
+		_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: payments.ServiceId, Name: "acquiring", UpdatedBy: "bob"}, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("restoring a version restores the attributes", func(t *testing.T) {
+		versions, err := svc.ListAllServiceVersions(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, float64(1), versions[0].Attributes["tier"])
+
+		restored, err := svc.RestoreServiceCatalogueVersion(cctx, &RestoreServiceCatalogueVersionRequest{
+			ServiceId: ledger.ServiceId, VersionId: versions[0].VersionId, RestoredBy: "carol",
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, ledger.Attributes, restored.Attributes)
+
+		svcCat, err := svc.FetchServiceById(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		assert.Equal(t, ledger.Attributes, svcCat.Attributes)
+	})
+}
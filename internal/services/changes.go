@@ -0,0 +1,282 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"slices"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var (
+	changeOperationUpdate   = "update"
+	changeOperationDelete   = "delete"
+	keyChangeCreatedAt      = "createdAt"
+	keyChangeRetryAt        = "retryAt"
+	keyChangeDeadLetteredAt = "deadLetteredAt"
+	reconcileBatchSize      = 50
+	// maxReconcileBackoff caps the wait between two attempts to reconcile a change
+	maxReconcileBackoff = time.Hour
+)
+
+// applyChange archives the live version and mutates the live document as one unit of work.
+// The change is written to the outbox first and removed once both the steps are done.
+// Mutating the live document is the commit point: a failure before it rolls back the archived
+// version, anything left behind after it is rolled forward by the reconciler. The mutation is only
+// rolled back when it definitely did not happen, other failures like a timeout may come after the
+// write was applied and are left in the outbox for the reconciler to find out, see isChangeApplied.
+func (svc *Service) applyChange(cctx context.CustomContext, change *ServiceCatalogueChange) error {
+	if err := svc.saveChange(cctx, change); err != nil {
+		return err
+	}
+
+	svcVersion, err := svc.CreateServiceCatalogueVersion(cctx, change.Archive)
+	if err != nil {
+		svc.rollbackChange(cctx, change)
+		return err
+	}
+	cctx.Logger().DEBUG("version created", tag.NewAnyTag("svc_version", svcVersion))
+
+	if err := svc.mutateLiveDocument(cctx, change); err != nil {
+		if errors.Is(err, database.VersionConflictErr) || errors.Is(err, database.DocumentMissingErr) {
+			svc.rollbackChange(cctx, change)
+		} else {
+			cctx.Logger().WARN("change left for the reconciler", tag.NewAnyTag("changeId", change.ChangeId), tag.NewErrorTag(err))
+		}
+		return err
+	}
+
+	if err := svc.completeChange(cctx, change); err != nil {
+		// the change is applied, the reconciler will clean up the outbox entry
+		cctx.Logger().WARN("failed to complete change", tag.NewAnyTag("changeId", change.ChangeId), tag.NewErrorTag(err))
+	}
+	return nil
+}
+
+func (svc *Service) saveChange(cctx context.CustomContext, change *ServiceCatalogueChange) error {
+	change.OrgId = orgOf(cctx)
+	changeBytes, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to parse change: %w", err)
+	}
+	return svc.repo.IndexDocument(cctx, changeBytes, database.ServiceCatalogueChangeIndex, change.ChangeId)
+}
+
+func (svc *Service) mutateLiveDocument(cctx context.CustomContext, change *ServiceCatalogueChange) error {
+	switch change.Operation {
+	case changeOperationUpdate:
+		updateBytes, err := json.Marshal(&database.UpdateBody{Doc: change.Update})
+		if err != nil {
+			cctx.Logger().DEBUG("failed to convert struct to map", tag.NewErrorTag(err))
+			return fmt.Errorf("failed to parse input: %w", err)
+		}
+		return svc.repo.UpdateDocument(cctx, updateBytes, database.ServiceCatalogueIndex, change.DocumentId, change.Revision)
+	case changeOperationDelete:
+		return svc.deleteServiceCatalogue(cctx, change.DocumentId, change.Revision)
+	default:
+		return fmt.Errorf("unknown change operation: %q", change.Operation)
+	}
+}
+
+// rollbackChange removes the archived version and the outbox entry. Failures are logged and left
+// for the reconciler to retry.
+func (svc *Service) rollbackChange(cctx context.CustomContext, change *ServiceCatalogueChange) {
+	err := svc.repo.DeleteDocument(cctx, database.ServiceCatalogueVersionIndex, change.Archive.VersionId, nil)
+	if err != nil && !errors.Is(err, database.DocumentMissingErr) {
+		cctx.Logger().ERROR("failed to rollback archived version", tag.NewAnyTag("changeId", change.ChangeId), tag.NewErrorTag(err))
+		return
+	}
+	if err := svc.completeChange(cctx, change); err != nil {
+		cctx.Logger().ERROR("failed to remove rolled back change", tag.NewAnyTag("changeId", change.ChangeId), tag.NewErrorTag(err))
+	}
+}
+
+func (svc *Service) completeChange(cctx context.CustomContext, change *ServiceCatalogueChange) error {
+	err := svc.repo.DeleteDocument(cctx, database.ServiceCatalogueChangeIndex, change.ChangeId, nil)
+	if err != nil && !errors.Is(err, database.DocumentMissingErr) {
+		return err
+	}
+	return nil
+}
+
+// isChangeApplied checks whether the live document was mutated by the change. The live version moving on
+// does not tell on its own, a concurrent writer may have moved it instead of the change:
+//   - an update is applied when the version it wrote carries its UpdatedAt, either live or archived by a later
+//     change. Until the later change archived it the change can not be told and an error is returned.
+//   - a delete is applied when the live document is gone, or restored, and no other change archived the version
+//     it deleted.
+//
+// Changes recorded without UpdatedAt are judged on the live version alone.
+func (svc *Service) isChangeApplied(cctx context.CustomContext, change *ServiceCatalogueChange) (bool, error) {
+	svcCat, err := svc.FetchServiceById(cctx, change.ServiceId)
+	if err != nil && !errors.Is(err, NoDocumentFoundErr) {
+		return false, err
+	}
+	if svcCat != nil && svcCat.Version <= change.Version {
+		return false, nil
+	}
+
+	switch change.Operation {
+	case changeOperationDelete:
+		archived, err := svc.archivedVersions(cctx, change.ServiceId, change.Version)
+		if err != nil {
+			return false, err
+		}
+		return !slices.ContainsFunc(archived, func(svcVersion *ServiceCatalogueVersion) bool {
+			return svcVersion.VersionId != change.Archive.VersionId
+		}), nil
+	case changeOperationUpdate:
+		if change.UpdatedAt == "" {
+			return svcCat != nil, nil
+		}
+		if svcCat != nil && svcCat.Version == change.Version+1 {
+			return svcCat.UpdatedAt == change.UpdatedAt, nil
+		}
+		archived, err := svc.archivedVersions(cctx, change.ServiceId, change.Version+1)
+		if err != nil {
+			return false, err
+		}
+		if len(archived) == 0 && svcCat != nil {
+			return false, fmt.Errorf("version %d of service %s is not archived yet", change.Version+1, change.ServiceId)
+		}
+		// without an archive the service was deleted at the version the update started from
+		return slices.ContainsFunc(archived, func(svcVersion *ServiceCatalogueVersion) bool {
+			return svcVersion.CreatedAt == change.UpdatedAt
+		}), nil
+	default:
+		return false, fmt.Errorf("unknown change operation: %q", change.Operation)
+	}
+}
+
+// archivedVersions looks up the archived versions of a service with the version number, there is more than one
+// only while a change archiving it is pending or when a change was applied twice
+func (svc *Service) archivedVersions(cctx context.CustomContext, serviceId string, version int) ([]*ServiceCatalogueVersion, error) {
+	return svc.searchAndFetchServiceCatalogueVersions(cctx, &database.Body{
+		Query: &database.Query{
+			Bool: &database.BoolQuery{
+				Filter: []database.Query{
+					{Term: &database.TermQuery{keyParentId: {Value: serviceId}}},
+					{Term: &database.TermQuery{keyVersion: {Value: version}}},
+				},
+			},
+		},
+	})
+}
+
+// reconcileChange rolls an applied change forward by making sure the archived version exists,
+// otherwise rolls it back
+func (svc *Service) reconcileChange(cctx context.CustomContext, change *ServiceCatalogueChange) error {
+	applied, err := svc.isChangeApplied(cctx, change)
+	if err != nil {
+		return err
+	}
+
+	if !applied {
+		cctx.Logger().INFO("rolling back change", tag.NewAnyTag("changeId", change.ChangeId))
+		err := svc.repo.DeleteDocument(cctx, database.ServiceCatalogueVersionIndex, change.Archive.VersionId, nil)
+		if err != nil && !errors.Is(err, database.DocumentMissingErr) {
+			return err
+		}
+		return svc.completeChange(cctx, change)
+	}
+
+	cctx.Logger().INFO("rolling forward change", tag.NewAnyTag("changeId", change.ChangeId))
+	if _, err := svc.CreateServiceCatalogueVersion(cctx, change.Archive); err != nil {
+		return err
+	}
+	return svc.completeChange(cctx, change)
+}
+
+// ReconcileChanges repairs changes which are older than the grace period and still in the outbox,
+// which happens when the process died or the database failed midway through a change. The outbox of
+// every organization is reconciled, each change on behalf of the organization which made it.
+// Changes failing to reconcile are retried later, see retryChange, so they do not hold up the changes behind them.
+func (svc *Service) ReconcileChanges(cctx context.CustomContext) error {
+	now := time.Now().UTC()
+	before := now.Add(-svc.reconciler.GracePeriod).Format(time.RFC3339)
+	body := &database.Body{
+		Query: &database.Query{
+			Bool: &database.BoolQuery{
+				Filter: []database.Query{
+					{Range: &database.RangeQuery{keyChangeCreatedAt: {Lte: before}}},
+					{Bool: &database.BoolQuery{Should: []database.Query{
+						{Range: &database.RangeQuery{keyChangeRetryAt: {Lte: now.Format(time.RFC3339)}}},
+						{Bool: &database.BoolQuery{MustNot: []database.Query{{Exists: &database.ExistsQuery{Field: keyChangeRetryAt}}}}},
+					}}},
+				},
+				MustNot: []database.Query{{Exists: &database.ExistsQuery{Field: keyChangeDeadLetteredAt}}},
+			},
+		},
+		Sort: []*database.SortField{{keyChangeCreatedAt: database.Asc}},
+		Size: database.HitsSize(reconcileBatchSize),
+	}
+
+	result, err := svc.repo.SearchAndGetHits(cctx, body, database.ServiceCatalogueChangeIndex)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pending changes: %w", err)
+	}
+
+	errs := []error{}
+	for _, hit := range result.Hits {
+		hitMap, ok := hit.(map[string]any)
+		if !ok {
+			cctx.Logger().DEBUG("failed to parse hit", tag.NewAnyTag("hit", hit))
+			continue
+		}
+		change := &ServiceCatalogueChange{}
+		if err := mapstructure.Decode(hitMap["_source"], change); err != nil {
+			cctx.Logger().DEBUG("failed to decode hit", tag.NewErrorTag(err))
+			continue
+		}
+		orgCtx := context.WithOrgID(cctx, change.OrgId)
+		if err := svc.reconcileChange(orgCtx, change); err != nil {
+			cctx.Logger().ERROR("failed to reconcile change", tag.NewAnyTag("changeId", change.ChangeId), tag.NewErrorTag(err))
+			errs = append(errs, err)
+			if err := svc.retryChange(orgCtx, change, now); err != nil {
+				cctx.Logger().ERROR("failed to schedule change retry", tag.NewAnyTag("changeId", change.ChangeId), tag.NewErrorTag(err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retryChange counts a failed attempt to reconcile the change and holds it back for twice as long as after the
+// attempt before, starting at the reconciler interval. After the configured attempts the change is dead lettered,
+// it stays in the outbox for an operator to look into but is not retried anymore.
+func (svc *Service) retryChange(cctx context.CustomContext, change *ServiceCatalogueChange, now time.Time) error {
+	change.Attempts++
+	if svc.reconciler.MaxAttempts > 0 && change.Attempts >= svc.reconciler.MaxAttempts {
+		cctx.Logger().ERROR("change dead lettered", tag.NewAnyTag("changeId", change.ChangeId), tag.NewAnyTag("attempts", change.Attempts))
+		change.RetryAt = ""
+		change.DeadLetteredAt = now.Format(time.RFC3339)
+		return svc.saveChange(cctx, change)
+	}
+	backoff := svc.reconciler.Interval
+	for attempt := 1; attempt < change.Attempts && backoff < maxReconcileBackoff; attempt++ {
+		backoff *= 2
+	}
+	change.RetryAt = now.Add(min(backoff, maxReconcileBackoff)).Format(time.RFC3339)
+	return svc.saveChange(cctx, change)
+}
+
+// RunReconciler runs ReconcileChanges every configured interval until the context is cancelled
+func (svc *Service) RunReconciler(cctx context.CustomContext) {
+	ticker := time.NewTicker(svc.reconciler.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cctx.Done():
+			return
+		case <-ticker.C:
+			if err := svc.ReconcileChanges(cctx); err != nil {
+				cctx.Logger().ERROR("RECONCILIATION_FAILED", tag.NewErrorTag(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyRepository fails updates and deletes on the live index with failLive while it is set. With applyLive
+// the write is applied before failing, like a response lost after elasticsearch applied it.
+type flakyRepository struct {
+	*database.MemoryClient
+	failLive  error
+	applyLive bool
+}
+
+func (f *flakyRepository) UpdateDocument(cctx context.CustomContext, docBytes []byte, index string, docId string, revision *database.Revision) error {
+	if f.failLive == nil || index != database.ServiceCatalogueIndex {
+		return f.MemoryClient.UpdateDocument(cctx, docBytes, index, docId, revision)
+	}
+	if f.applyLive {
+		if err := f.MemoryClient.UpdateDocument(cctx, docBytes, index, docId, revision); err != nil {
+			return err
+		}
+	}
+	return f.failLive
+}
+
+func (f *flakyRepository) DeleteDocument(cctx context.CustomContext, index string, docId string, revision *database.Revision) error {
+	if f.failLive == nil || index != database.ServiceCatalogueIndex {
+		return f.MemoryClient.DeleteDocument(cctx, index, docId, revision)
+	}
+	if f.applyLive {
+		if err := f.MemoryClient.DeleteDocument(cctx, index, docId, revision); err != nil {
+			return err
+		}
+	}
+	return f.failLive
+}
+
+func newTestService(t *testing.T) (*Service, *flakyRepository, *ServiceCatalogue) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	repo := &flakyRepository{MemoryClient: database.NewMemoryClient()}
+	svc := &Service{repo: repo, reconciler: config.Reconciler{}}
+
+	req := &CreateServiceCatalogueRequest{Name: "payments", Description: "processes card payments", CreatedBy: "alice"}
+	svcCat, err := svc.CreateServiceCatalogue(cctx, req.RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+	return svc, repo, svcCat
+}
+
+func pendingChanges(t *testing.T, svc *Service) []any {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	result, err := svc.repo.SearchAndGetHits(cctx, &database.Body{}, database.ServiceCatalogueChangeIndex)
+	require.NoError(t, err)
+	return result.Hits
+}
+
+func TestUpdateServiceCatalogue_RollsBackArchiveOnFailure(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, repo, svcCat := newTestService(t)
+
+	repo.failLive = fmt.Errorf("update failed: %w", database.VersionConflictErr)
+	_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedBy: "bob"}, nil)
+	require.Error(t, err)
+
+	versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+	assert.Empty(t, pendingChanges(t, svc))
+
+	repo.failLive = nil
+	updated, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedBy: "bob"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	versions, err = svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, 1, versions[0].Version)
+	assert.Empty(t, pendingChanges(t, svc))
+}
+
+func TestUpdateServiceCatalogue_LeavesAmbiguousFailureToReconciler(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+
+	t.Run("write applied before the error", func(t *testing.T) {
+		svc, repo, svcCat := newTestService(t)
+		repo.failLive, repo.applyLive = fmt.Errorf("context deadline exceeded"), true
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedBy: "bob"}, nil)
+		require.Error(t, err)
+
+		versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1, "the history of the applied update is kept")
+		assert.Len(t, pendingChanges(t, svc), 1)
+
+		repo.failLive = nil
+		require.NoError(t, svc.ReconcileChanges(cctx))
+		live, err := svc.FetchServiceById(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		assert.Equal(t, "acquiring", live.Name)
+		versions, err = svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, 1, versions[0].Version)
+		assert.Empty(t, pendingChanges(t, svc))
+	})
+
+	t.Run("write not applied", func(t *testing.T) {
+		svc, repo, svcCat := newTestService(t)
+		repo.failLive = fmt.Errorf("context deadline exceeded")
+		err := svc.DeleteService(cctx, svcCat.ServiceId, "bob", nil)
+		require.Error(t, err)
+		assert.Len(t, pendingChanges(t, svc), 1)
+
+		repo.failLive = nil
+		require.NoError(t, svc.ReconcileChanges(cctx))
+		versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+		assert.Empty(t, pendingChanges(t, svc))
+		_, err = svc.FetchServiceById(cctx, svcCat.ServiceId)
+		assert.NoError(t, err)
+	})
+}
+
+func TestReconcileChanges(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+
+	t.Run("rolls back a change which never reached the live document", func(t *testing.T) {
+		svc, _, svcCat := newTestService(t)
+		change := &ServiceCatalogueChange{
+			ChangeId:   "change-1",
+			Operation:  changeOperationDelete,
+			ServiceId:  svcCat.ServiceId,
+			DocumentId: "unused",
+			Version:    svcCat.Version,
+			Archive:    &ServiceCatalogueVersion{ParentId: svcCat.ServiceId, VersionId: "version-1", Version: svcCat.Version},
+			CreatedAt:  "2024-08-01T10:00:00Z",
+		}
+		require.NoError(t, svc.saveChange(cctx, change))
+		_, err := svc.CreateServiceCatalogueVersion(cctx, change.Archive)
+		require.NoError(t, err)
+
+		require.NoError(t, svc.ReconcileChanges(cctx))
+
+		versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+		assert.Empty(t, pendingChanges(t, svc))
+		_, err = svc.FetchServiceById(cctx, svcCat.ServiceId)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rolls forward a change applied to the live document", func(t *testing.T) {
+		svc, _, svcCat := newTestService(t)
+		hitMap, err := svc.searchAndFetchServiceCatalogue(cctx, &database.Body{
+			Query: &database.Query{Term: &database.TermQuery{keyServiceId: {Value: svcCat.ServiceId}}},
+		})
+		require.NoError(t, err)
+		change := &ServiceCatalogueChange{
+			ChangeId:   "change-2",
+			Operation:  changeOperationUpdate,
+			ServiceId:  svcCat.ServiceId,
+			DocumentId: hitMap["_id"].(string),
+			Version:    svcCat.Version,
+			Archive:    &ServiceCatalogueVersion{ParentId: svcCat.ServiceId, VersionId: "version-2", Version: svcCat.Version},
+			Update:     map[string]any{"name": "acquiring", "version": svcCat.Version + 1},
+			CreatedAt:  "2024-08-01T10:00:00Z",
+		}
+		// the process died after the live document was updated, before archiving
+		require.NoError(t, svc.saveChange(cctx, change))
+		require.NoError(t, svc.mutateLiveDocument(cctx, change))
+
+		require.NoError(t, svc.ReconcileChanges(cctx))
+
+		versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, "version-2", versions[0].VersionId)
+		assert.Empty(t, pendingChanges(t, svc))
+	})
+
+	t.Run("rolls back an update when another writer moved the version", func(t *testing.T) {
+		svc, repo, svcCat := newTestService(t)
+		repo.failLive = fmt.Errorf("context deadline exceeded")
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedBy: "bob", UpdatedAt: "2024-08-01T10:00:00Z"}, nil)
+		require.Error(t, err)
+		repo.failLive = nil
+		_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "issuing", UpdatedBy: "carol", UpdatedAt: "2024-08-01T10:00:01Z"}, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, svc.ReconcileChanges(cctx))
+
+		versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1, "the version archived by the failed update is not kept next to the one of the other writer")
+		assert.Equal(t, "carol", versions[0].DecomissionedBy)
+		assert.Empty(t, pendingChanges(t, svc))
+	})
+
+	t.Run("rolls forward an update archived by a later change", func(t *testing.T) {
+		svc, repo, svcCat := newTestService(t)
+		repo.failLive, repo.applyLive = fmt.Errorf("context deadline exceeded"), true
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedBy: "bob", UpdatedAt: "2024-08-01T10:00:00Z"}, nil)
+		require.Error(t, err)
+		repo.failLive = nil
+		_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "issuing", UpdatedBy: "carol", UpdatedAt: "2024-08-01T10:00:01Z"}, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, svc.ReconcileChanges(cctx))
+
+		versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, []string{"payments", "acquiring"}, []string{versions[0].Name, versions[1].Name})
+		assert.Empty(t, pendingChanges(t, svc))
+	})
+
+	t.Run("rolls back a delete when another writer moved the version", func(t *testing.T) {
+		svc, repo, svcCat := newTestService(t)
+		repo.failLive = fmt.Errorf("context deadline exceeded")
+		require.Error(t, svc.DeleteService(cctx, svcCat.ServiceId, "bob", nil))
+		repo.failLive = nil
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "issuing", UpdatedBy: "carol"}, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, svc.ReconcileChanges(cctx))
+
+		versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.False(t, versions[0].Deleted)
+		assert.Empty(t, pendingChanges(t, svc))
+	})
+}
+
+func TestReconcileChanges_RetriesFailingChangesLater(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, svcCat := newTestService(t)
+	svc.reconciler = config.Reconciler{Interval: time.Minute, MaxAttempts: 3}
+	batchSize := reconcileBatchSize
+	reconcileBatchSize = 1
+	t.Cleanup(func() { reconcileBatchSize = batchSize })
+
+	broken := &ServiceCatalogueChange{
+		ChangeId:  "change-broken",
+		Operation: "rename",
+		ServiceId: svcCat.ServiceId,
+		Version:   svcCat.Version - 1,
+		Archive:   &ServiceCatalogueVersion{ParentId: svcCat.ServiceId, VersionId: "version-broken", Version: svcCat.Version - 1},
+		CreatedAt: "2024-08-01T10:00:00Z",
+	}
+	pending := &ServiceCatalogueChange{
+		ChangeId:  "change-pending",
+		Operation: changeOperationDelete,
+		ServiceId: svcCat.ServiceId,
+		Version:   svcCat.Version,
+		Archive:   &ServiceCatalogueVersion{ParentId: svcCat.ServiceId, VersionId: "version-pending", Version: svcCat.Version},
+		CreatedAt: "2024-08-01T10:00:01Z",
+	}
+	require.NoError(t, svc.saveChange(cctx, broken))
+	require.NoError(t, svc.saveChange(cctx, pending))
+
+	change := func(changeId string) *ServiceCatalogueChange {
+		for _, hit := range pendingChanges(t, svc) {
+			change := &ServiceCatalogueChange{}
+			require.NoError(t, mapstructure.Decode(hit.(map[string]any)["_source"], change))
+			if change.ChangeId == changeId {
+				return change
+			}
+		}
+		return nil
+	}
+
+	require.Error(t, svc.ReconcileChanges(cctx))
+	assert.Equal(t, 1, change(broken.ChangeId).Attempts)
+	assert.NotEmpty(t, change(broken.ChangeId).RetryAt)
+
+	// the failing change is held back so the change behind it is reconciled
+	require.NoError(t, svc.ReconcileChanges(cctx))
+	assert.Nil(t, change(pending.ChangeId))
+	assert.Equal(t, 1, change(broken.ChangeId).Attempts)
+
+	// once it failed too often it is dead lettered and left alone
+	svc.reconciler.Interval = 0
+	due := change(broken.ChangeId)
+	due.RetryAt = time.Now().UTC().Format(time.RFC3339)
+	require.NoError(t, svc.saveChange(cctx, due))
+	require.Error(t, svc.ReconcileChanges(cctx))
+	require.Error(t, svc.ReconcileChanges(cctx))
+	dead := change(broken.ChangeId)
+	assert.Equal(t, 3, dead.Attempts)
+	assert.NotEmpty(t, dead.DeadLetteredAt)
+	require.NoError(t, svc.ReconcileChanges(cctx))
+	assert.Equal(t, 3, change(broken.ChangeId).Attempts)
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"slices"
+	"strings"
+)
+
+var (
+	keyDependsOn          = "dependsOn"
+	keyDependsOnServiceId = "dependsOn.serviceId.keyword"
+	maxDependencyDepth    = 10
+	// maxGraphServices is how many services are fetched per hop while walking the graph
+	maxGraphServices = 10000
+)
+
+var (
+	DependencyHTTP     = "http"
+	DependencyGRPC     = "grpc"
+	DependencyQueue    = "queue"
+	DependencyDatabase = "database"
+)
+
+// DependencyDirection is which way the dependency graph is walked from a service
+type DependencyDirection string
+
+var (
+	// Dependencies follows the services a service depends on
+	Dependencies DependencyDirection = "dependencies"
+	// Dependents follows the services depending on a service
+	Dependents DependencyDirection = "dependents"
+)
+
+// InvalidDependencyErr is returned when a service depends on itself or on a service which does not exist
+var InvalidDependencyErr error = fmt.Errorf("INVALID_DEPENDENCY")
+
+// DependencyGraph walks the dependency graph from the service up to depth hops breadth first. Every service
+// reached is a node at the least number of hops it is reachable in, the edges are all the declared
+// dependencies between the nodes.
+func (svc *Service) DependencyGraph(cctx context.CustomContext, serviceId string, direction DependencyDirection, depth int) (*DependencyGraph, error) {
+	root, err := svc.FetchServiceById(cctx, serviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &DependencyGraph{
+		ServiceId: serviceId,
+		Direction: string(direction),
+		Depth:     depth,
+		Nodes:     []*DependencyNode{dependencyNode(root, serviceId, 0, "")},
+		Edges:     []*DependencyEdge{},
+	}
+	visited := map[string]bool{serviceId: true}
+	frontier := []*ServiceCatalogue{root}
+	for hop := 1; hop <= depth && len(frontier) > 0; hop++ {
+		if direction == Dependents {
+			frontier, err = svc.walkDependents(cctx, graph, frontier, visited, hop)
+		} else {
+			frontier, err = svc.walkDependencies(cctx, graph, frontier, visited, hop)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
+}
+
+// walkDependencies adds the services the frontier depends on to the graph and returns the ones not seen before
+func (svc *Service) walkDependencies(cctx context.CustomContext, graph *DependencyGraph, frontier []*ServiceCatalogue, visited map[string]bool, hop int) ([]*ServiceCatalogue, error) {
+	via := map[string]string{}
+	for _, svcCat := range frontier {
+		for _, dependency := range svcCat.DependsOn {
+			graph.Edges = append(graph.Edges, &DependencyEdge{From: svcCat.ServiceId, To: dependency.ServiceId, Type: dependency.Type})
+			if !visited[dependency.ServiceId] {
+				visited[dependency.ServiceId] = true
+				via[dependency.ServiceId] = svcCat.ServiceId
+			}
+		}
+	}
+	if len(via) == 0 {
+		return nil, nil
+	}
+
+	next, err := svc.fetchServicesById(cctx, mapKeys(via))
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]*ServiceCatalogue{}
+	for _, svcCat := range next {
+		found[svcCat.ServiceId] = svcCat
+	}
+	for _, serviceId := range mapKeys(via) {
+		graph.Nodes = append(graph.Nodes, dependencyNode(found[serviceId], serviceId, hop, via[serviceId]))
+	}
+	return next, nil
+}
+
+// walkDependents adds the services depending on the frontier to the graph and returns the ones not seen before
+func (svc *Service) walkDependents(cctx context.CustomContext, graph *DependencyGraph, frontier []*ServiceCatalogue, visited map[string]bool, hop int) ([]*ServiceCatalogue, error) {
+	targets := map[string]bool{}
+	for _, svcCat := range frontier {
+		targets[svcCat.ServiceId] = true
+	}
+	dependents, err := svc.fetchDependents(cctx, mapKeys(targets))
+	if err != nil {
+		return nil, err
+	}
+
+	next := []*ServiceCatalogue{}
+	for _, dependent := range dependents {
+		for _, dependency := range dependent.DependsOn {
+			if !targets[dependency.ServiceId] {
+				continue
+			}
+			graph.Edges = append(graph.Edges, &DependencyEdge{From: dependent.ServiceId, To: dependency.ServiceId, Type: dependency.Type})
+			if !visited[dependent.ServiceId] {
+				visited[dependent.ServiceId] = true
+				graph.Nodes = append(graph.Nodes, dependencyNode(dependent, dependent.ServiceId, hop, dependency.ServiceId))
+				next = append(next, dependent)
+			}
+		}
+	}
+	return next, nil
+}
+
+// validateDependencies checks the service does not depend on itself and that every service it depends on
+// exists. A dependency cycle is allowed but reported back as a warning, since services calling each
+// other are common even if not desired.
+func (svc *Service) validateDependencies(cctx context.CustomContext, serviceId string, dependsOn []*Dependency) ([]string, error) {
+	if len(dependsOn) == 0 {
+		return nil, nil
+	}
+
+	targets := map[string]bool{}
+	for _, dependency := range dependsOn {
+		if dependency.ServiceId == serviceId {
+			return nil, fmt.Errorf("%w: a service can not depend on itself", InvalidDependencyErr)
+		}
+		targets[dependency.ServiceId] = true
+	}
+	found, err := svc.fetchServicesById(cctx, mapKeys(targets))
+	if err != nil {
+		return nil, err
+	}
+	for _, svcCat := range found {
+		delete(targets, svcCat.ServiceId)
+	}
+	if len(targets) > 0 {
+		return nil, fmt.Errorf("%w: unknown services %s", InvalidDependencyErr, strings.Join(mapKeys(targets), ", "))
+	}
+
+	warnings, err := svc.dependencyCycles(cctx, serviceId, dependsOn)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		cctx.Logger().WARN("dependency cycle", tag.NewAnyTag("serviceId", serviceId), tag.NewAnyTag("cycle", warning))
+	}
+	return warnings, nil
+}
+
+// dependencyCycles finds the cycles the dependencies would close. A dependency closes a cycle when its
+// target already depends on the service, directly or transitively, so the dependents of the service are
+// walked and every cycle is described by the path back to the service.
+func (svc *Service) dependencyCycles(cctx context.CustomContext, serviceId string, dependsOn []*Dependency) ([]string, error) {
+	graph := &DependencyGraph{ServiceId: serviceId}
+	visited := map[string]bool{serviceId: true}
+	frontier := []*ServiceCatalogue{{ServiceId: serviceId}}
+	var err error
+	for hop := 1; hop <= maxDependencyDepth && len(frontier) > 0; hop++ {
+		frontier, err = svc.walkDependents(cctx, graph, frontier, visited, hop)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	via := map[string]string{}
+	for _, node := range graph.Nodes {
+		via[node.ServiceId] = node.Via
+	}
+	warnings := []string{}
+	for _, dependency := range dependsOn {
+		if _, ok := via[dependency.ServiceId]; !ok {
+			continue
+		}
+		path := []string{serviceId}
+		for id := dependency.ServiceId; id != serviceId; id = via[id] {
+			path = append(path, id)
+		}
+		path = append(path, serviceId)
+		warnings = append(warnings, fmt.Sprintf("dependency cycle: %s", strings.Join(path, " -> ")))
+	}
+	return warnings, nil
+}
+
+// fetchServicesById fetches the live services with the serviceIds, missing services are left out
+func (svc *Service) fetchServicesById(cctx context.CustomContext, serviceIds []string) ([]*ServiceCatalogue, error) {
+	return svc.fetchServicesByTerms(cctx, keyServiceId, serviceIds)
+}
+
+// fetchDependents fetches the live services depending on any of the serviceIds
+func (svc *Service) fetchDependents(cctx context.CustomContext, serviceIds []string) ([]*ServiceCatalogue, error) {
+	return svc.fetchServicesByTerms(cctx, keyDependsOnServiceId, serviceIds)
+}
+
+func (svc *Service) fetchServicesByTerms(cctx context.CustomContext, field string, values []string) ([]*ServiceCatalogue, error) {
+	terms := make([]any, 0, len(values))
+	for _, value := range values {
+		terms = append(terms, value)
+	}
+	result, err := svc.search(cctx, &database.Body{
+		Query: &database.Query{Terms: &database.TermsQuery{field: terms}},
+		Sort:  []*database.SortField{{keyServiceId: database.Asc}},
+		Size:  database.HitsSize(maxGraphServices),
+	}, database.ServiceCatalogueIndex)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	return decodeServiceCatalogueHits(cctx, result.Hits), nil
+}
+
+// dependencyNode describes the service in the graph, a nil service is a dependency which does not exist anymore
+func dependencyNode(svcCat *ServiceCatalogue, serviceId string, depth int, via string) *DependencyNode {
+	if svcCat == nil {
+		return &DependencyNode{ServiceId: serviceId, Depth: depth, Via: via, Missing: true}
+	}
+	return &DependencyNode{
+		ServiceId: serviceId,
+		Name:      svcCat.Name,
+		OwnerTeam: svcCat.OwnerTeam,
+		Depth:     depth,
+		Via:       via,
+	}
+}
+
+// validateUniqueDependencies checks a service is depended on only once
+func validateUniqueDependencies(value any) error {
+	dependsOn, _ := value.([]*Dependency)
+	seen := map[string]bool{}
+	for _, dependency := range dependsOn {
+		if dependency == nil {
+			return fmt.Errorf("dependency must not be null")
+		}
+		if seen[dependency.ServiceId] {
+			return fmt.Errorf("duplicate dependency on %q", dependency.ServiceId)
+		}
+		seen[dependency.ServiceId] = true
+	}
+	return nil
+}
+
+// mapKeys returns the keys of the map sorted, so graphs and messages come out the same every time
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependenciesValidation(t *testing.T) {
+	create := &CreateServiceCatalogueRequest{
+		Name: "checkout", Description: "checks out shopping carts", Ownership: Ownership{OwnerTeam: "payments"}, CreatedBy: "alice",
+		DependsOn: []*Dependency{{ServiceId: "a", Type: DependencyHTTP}, {ServiceId: "b", Type: DependencyQueue}},
+	}
+	assert.NoError(t, create.Validate())
+
+	create.DependsOn = []*Dependency{{ServiceId: "a", Type: "smtp"}}
+	assert.Error(t, create.Validate())
+	create.DependsOn = []*Dependency{{Type: DependencyGRPC}}
+	assert.Error(t, create.Validate())
+	create.DependsOn = []*Dependency{{ServiceId: "a", Type: DependencyHTTP}, {ServiceId: "a", Type: DependencyGRPC}}
+	assert.Error(t, create.Validate())
+
+	// replacing the dependencies alone is a valid update
+	update := &UpdateServiceCatalogueRequest{ServiceId: "a", UpdatedBy: "bob", DependsOn: []*Dependency{}}
+	assert.NoError(t, update.Validate())
+}
+
+func TestDependencyGraph(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, payments := newTestService(t)
+	create := func(name string, dependsOn ...*Dependency) *ServiceCatalogue {
+		svcCat, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service with dependencies", DependsOn: dependsOn, CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+		assert.Empty(t, svcCat.Warnings)
+		return svcCat
+	}
+	ledger := create("ledger")
+	gateway := create("gateway", &Dependency{ServiceId: payments.ServiceId, Type: DependencyHTTP})
+	checkout := create("checkout", &Dependency{ServiceId: gateway.ServiceId, Type: DependencyHTTP})
+
+	_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+		ServiceId: payments.ServiceId, DependsOn: []*Dependency{{ServiceId: ledger.ServiceId, Type: DependencyGRPC}}, UpdatedBy: "bob",
+	}, nil)
+	require.NoError(t, err)
+
+	nodes := func(serviceId string, direction DependencyDirection, depth int) map[string]int {
+		graph, err := svc.DependencyGraph(cctx, serviceId, direction, depth)
+		require.NoError(t, err)
+		depths := map[string]int{}
+		for _, node := range graph.Nodes {
+			depths[node.Name] = node.Depth
+		}
+		return depths
+	}
+
+	t.Run("dependencies", func(t *testing.T) {
+		assert.Equal(t, map[string]int{"checkout": 0, "gateway": 1}, nodes(checkout.ServiceId, Dependencies, 1))
+		assert.Equal(t, map[string]int{"checkout": 0, "gateway": 1, "payments": 2, "ledger": 3}, nodes(checkout.ServiceId, Dependencies, 3))
+
+		graph, err := svc.DependencyGraph(cctx, gateway.ServiceId, Dependencies, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []*DependencyEdge{{From: gateway.ServiceId, To: payments.ServiceId, Type: DependencyHTTP}}, graph.Edges)
+		assert.Equal(t, gateway.ServiceId, graph.Nodes[1].Via)
+	})
+
+	t.Run("dependents", func(t *testing.T) {
+		assert.Equal(t, map[string]int{"ledger": 0, "payments": 1, "gateway": 2}, nodes(ledger.ServiceId, Dependents, 2))
+		assert.Equal(t, map[string]int{"checkout": 0}, nodes(checkout.ServiceId, Dependents, 5))
+	})
+
+	t.Run("unknown and self dependencies", func(t *testing.T) {
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: ledger.ServiceId, DependsOn: []*Dependency{{ServiceId: "missing", Type: DependencyDatabase}}, UpdatedBy: "bob",
+		}, nil)
+		assert.ErrorIs(t, err, InvalidDependencyErr)
+
+		_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: ledger.ServiceId, DependsOn: []*Dependency{{ServiceId: ledger.ServiceId, Type: DependencyDatabase}}, UpdatedBy: "bob",
+		}, nil)
+		assert.ErrorIs(t, err, InvalidDependencyErr)
+
+		_, err = svc.DependencyGraph(cctx, "missing", Dependencies, 1)
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+	})
+
+	t.Run("cycles warn", func(t *testing.T) {
+		updated, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: ledger.ServiceId, DependsOn: []*Dependency{{ServiceId: checkout.ServiceId, Type: DependencyQueue}}, UpdatedBy: "bob",
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"dependency cycle: " + ledger.ServiceId + " -> " + checkout.ServiceId + " -> " + gateway.ServiceId + " -> " + payments.ServiceId + " -> " + ledger.ServiceId,
+		}, updated.Warnings)
+		assert.Equal(t, map[string]int{"ledger": 0, "checkout": 1, "gateway": 2, "payments": 3}, nodes(ledger.ServiceId, Dependencies, 5))
+	})
+
+	t.Run("replaced and kept in the version history", func(t *testing.T) {
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: payments.ServiceId, DependsOn: []*Dependency{}, UpdatedBy: "bob"}, nil)
+		require.NoError(t, err)
+		svcCat, err := svc.FetchServiceById(cctx, payments.ServiceId)
+		require.NoError(t, err)
+		assert.Empty(t, svcCat.DependsOn)
+
+		versions, err := svc.ListAllServiceVersions(cctx, payments.ServiceId)
+		require.NoError(t, err)
+		var withLedger *ServiceCatalogueVersion
+		for _, version := range versions {
+			if len(version.DependsOn) > 0 {
+				withLedger = version
+			}
+		}
+		require.NotNil(t, withLedger)
+		assert.Equal(t, []*Dependency{{ServiceId: ledger.ServiceId, Type: DependencyGRPC}}, withLedger.DependsOn)
+
+		restored, err := svc.RestoreServiceCatalogueVersion(cctx, &RestoreServiceCatalogueVersionRequest{
+			ServiceId: payments.ServiceId, VersionId: withLedger.VersionId, RestoredBy: "carol",
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, withLedger.DependsOn, restored.DependsOn)
+		assert.Equal(t, map[string]int{"payments": 0, "ledger": 1}, nodes(payments.ServiceId, Dependencies, 1))
+	})
+}
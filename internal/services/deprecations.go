@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"time"
+)
+
+var (
+	keyDeprecatedAt = "deprecatedAt"
+	keySunsetAt     = "sunsetAt"
+	keyReplacedBy   = "replacedBy"
+)
+
+// InvalidDeprecationErr is returned when the sunset or the replacement of a service do not fit its lifecycle
+var InvalidDeprecationErr error = fmt.Errorf("INVALID_DEPRECATION")
+
+// validateDeprecation checks the sunset and the replacement of a service ending up in the lifecycle. Only
+// deprecated services take them, a deprecated service needs a sunset and is replaced by another service
+// in the catalogue.
+func (svc *Service) validateDeprecation(cctx context.CustomContext, serviceId string, lifecycle string, current Deprecation, input Deprecation) error {
+	if lifecycle != LifecycleDeprecated {
+		if input.SunsetAt != "" || input.ReplacedBy != "" {
+			return fmt.Errorf("%w: only deprecated services have a sunset and a replacement", InvalidDeprecationErr)
+		}
+		return nil
+	}
+	if input.SunsetAt == "" && current.SunsetAt == "" {
+		return fmt.Errorf("%w: a deprecated service needs a sunset date", InvalidDeprecationErr)
+	}
+	if input.ReplacedBy == "" {
+		return nil
+	}
+	if input.ReplacedBy == serviceId {
+		return fmt.Errorf("%w: a service can not replace itself", InvalidDeprecationErr)
+	}
+	_, err := svc.FetchServiceById(cctx, input.ReplacedBy)
+	if errors.Is(err, NoDocumentFoundErr) {
+		return fmt.Errorf("%w: unknown replacement %s", InvalidDeprecationErr, input.ReplacedBy)
+	}
+	return err
+}
+
+// deprecationUpdate stamps when the service got deprecated on the transition to deprecated. Taking a
+// deprecation back clears it, a retired service keeps it.
+func deprecationUpdate(updateMap map[string]any, transition *LifecycleTransition, now string) {
+	switch {
+	case transition == nil:
+	case transition.To == LifecycleDeprecated:
+		updateMap[keyDeprecatedAt] = now
+	case transition.From == LifecycleDeprecated && transition.To != LifecycleRetired:
+		updateMap[keyDeprecatedAt], updateMap[keySunsetAt], updateMap[keyReplacedBy] = nil, nil, nil
+	}
+}
+
+// ListDeprecations reports the deprecated services, the closest sunset first. Every service comes with the
+// services still depending on it, so the ones past their sunset which can not go away yet stand out.
+func (svc *Service) ListDeprecations(cctx context.CustomContext, listParams *ListDeprecationsParameters) ([]*DeprecatedService, *Page, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	must := []database.Query{{Term: &database.TermQuery{keyLifecycle: {Value: LifecycleDeprecated}}}}
+	if listParams.PastSunset {
+		must = append(must, database.Query{Range: &database.RangeQuery{keySunsetAt: {Lte: now}}})
+	}
+	body := &database.Body{
+		Query: &database.Query{Bool: &database.BoolQuery{Must: must}},
+		Sort:  []*database.SortField{{keySunsetAt: database.Asc}},
+		From:  *listParams.From,
+		Size:  listParams.Size,
+	}
+
+	hits, page, err := svc.searchPage(cctx, body, database.ServiceCatalogueIndex, keyServiceId, listParams.Cursor, listParams.Pit, Cursor{})
+	if err != nil {
+		return nil, nil, err
+	}
+	svcCats := decodeServiceCatalogueHits(cctx, hits)
+
+	serviceIds := make([]string, 0, len(svcCats))
+	for _, svcCat := range svcCats {
+		serviceIds = append(serviceIds, svcCat.ServiceId)
+	}
+	dependents := map[string][]string{}
+	if len(serviceIds) > 0 {
+		svcDependents, err := svc.fetchDependents(cctx, serviceIds)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, dependent := range svcDependents {
+			if lifecycleOf(dependent) == LifecycleRetired {
+				continue
+			}
+			for _, dependency := range dependent.DependsOn {
+				dependents[dependency.ServiceId] = append(dependents[dependency.ServiceId], dependent.ServiceId)
+			}
+		}
+	}
+
+	deprecations := []*DeprecatedService{}
+	for _, svcCat := range svcCats {
+		deprecated := &DeprecatedService{
+			ServiceId:   svcCat.ServiceId,
+			Name:        svcCat.Name,
+			OwnerTeam:   svcCat.OwnerTeam,
+			Deprecation: svcCat.Deprecation,
+			PastSunset:  svcCat.SunsetAt != "" && svcCat.SunsetAt <= now,
+			Dependents:  dependents[svcCat.ServiceId],
+		}
+		if deprecated.Dependents == nil {
+			deprecated.Dependents = []string{}
+		}
+		deprecated.Overdue = deprecated.PastSunset && len(deprecated.Dependents) > 0
+		deprecations = append(deprecations, deprecated)
+	}
+	return deprecations, page, nil
+}
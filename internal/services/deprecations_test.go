@@ -0,0 +1,111 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecations(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, payments := newTestService(t)
+	create := func(name string, dependsOn ...*Dependency) *ServiceCatalogue {
+		svcCat, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service to deprecate", Lifecycle: LifecycleProduction, DependsOn: dependsOn, CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+		return svcCat
+	}
+	ledger := create("ledger")
+	ledgerV2 := create("ledger-v2")
+	create("gateway", &Dependency{ServiceId: ledger.ServiceId, Type: DependencyGRPC})
+	archive := create("archive")
+	retired := create("reports", &Dependency{ServiceId: archive.ServiceId, Type: DependencyDatabase})
+
+	update := func(svcCat *ServiceCatalogue) (*ServiceCatalogue, error) {
+		svcCat.UpdatedBy = "bob"
+		return svc.UpdateServiceCatalogue(cctx, svcCat, nil)
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := update(&ServiceCatalogue{ServiceId: ledger.ServiceId, Lifecycle: LifecycleDeprecated})
+		assert.ErrorIs(t, err, InvalidDeprecationErr)
+		_, err = update(&ServiceCatalogue{ServiceId: ledger.ServiceId, Deprecation: Deprecation{SunsetAt: "2020-01-01T00:00:00Z"}})
+		assert.ErrorIs(t, err, InvalidDeprecationErr)
+		_, err = update(&ServiceCatalogue{
+			ServiceId: ledger.ServiceId, Lifecycle: LifecycleDeprecated, Deprecation: Deprecation{SunsetAt: "2020-01-01T00:00:00Z", ReplacedBy: "missing"},
+		})
+		assert.ErrorIs(t, err, InvalidDeprecationErr)
+		_, err = update(&ServiceCatalogue{
+			ServiceId: ledger.ServiceId, Lifecycle: LifecycleDeprecated, Deprecation: Deprecation{SunsetAt: "2020-01-01T00:00:00Z", ReplacedBy: ledger.ServiceId},
+		})
+		assert.ErrorIs(t, err, InvalidDeprecationErr)
+	})
+
+	updated, err := update(&ServiceCatalogue{
+		ServiceId: ledger.ServiceId, Lifecycle: LifecycleDeprecated, Deprecation: Deprecation{SunsetAt: "2020-01-01T00:00:00Z", ReplacedBy: ledgerV2.ServiceId},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, updated.DeprecatedAt)
+	_, err = update(&ServiceCatalogue{ServiceId: archive.ServiceId, Lifecycle: LifecycleDeprecated, Deprecation: Deprecation{SunsetAt: "2999-01-01T00:00:00Z"}})
+	require.NoError(t, err)
+	_, err = update(&ServiceCatalogue{ServiceId: retired.ServiceId, Lifecycle: LifecycleDeprecated, Deprecation: Deprecation{SunsetAt: "2021-01-01T00:00:00Z"}})
+	require.NoError(t, err)
+	_, err = update(&ServiceCatalogue{ServiceId: retired.ServiceId, Lifecycle: LifecycleRetired})
+	require.NoError(t, err)
+
+	svcCat, err := svc.FetchServiceById(cctx, ledger.ServiceId)
+	require.NoError(t, err)
+	assert.Equal(t, Deprecation{DeprecatedAt: updated.DeprecatedAt, SunsetAt: "2020-01-01T00:00:00Z", ReplacedBy: ledgerV2.ServiceId}, svcCat.Deprecation)
+
+	t.Run("report", func(t *testing.T) {
+		listParams := &ListDeprecationsParameters{}
+		listParams.AddDefaultsIfEmpty()
+		deprecations, page, err := svc.ListDeprecations(cctx, listParams)
+		require.NoError(t, err)
+		require.Len(t, deprecations, 2)
+		assert.Equal(t, 2, page.Total)
+
+		assert.Equal(t, "ledger", deprecations[0].Name)
+		assert.True(t, deprecations[0].PastSunset)
+		assert.Len(t, deprecations[0].Dependents, 1)
+		assert.True(t, deprecations[0].Overdue)
+
+		// the retired reports service does not hold the archive back
+		assert.Equal(t, "archive", deprecations[1].Name)
+		assert.False(t, deprecations[1].PastSunset)
+		assert.Empty(t, deprecations[1].Dependents)
+		assert.False(t, deprecations[1].Overdue)
+
+		listParams.PastSunset = true
+		deprecations, _, err = svc.ListDeprecations(cctx, listParams)
+		require.NoError(t, err)
+		require.Len(t, deprecations, 1)
+		assert.Equal(t, "ledger", deprecations[0].Name)
+	})
+
+	t.Run("taking the deprecation back clears it", func(t *testing.T) {
+		updated, err := update(&ServiceCatalogue{ServiceId: ledger.ServiceId, Lifecycle: LifecycleProduction})
+		require.NoError(t, err)
+		assert.Equal(t, Deprecation{}, updated.Deprecation)
+
+		svcCat, err := svc.FetchServiceById(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		assert.Equal(t, Deprecation{}, svcCat.Deprecation)
+
+		versions, err := svc.ListAllServiceVersions(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		sunsets := []string{}
+		for _, version := range versions {
+			sunsets = append(sunsets, version.SunsetAt)
+		}
+		assert.Contains(t, sunsets, "2020-01-01T00:00:00Z")
+	})
+
+	// services which are not deprecated take no sunset on create either
+	_, err = svc.CreateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: "a", Name: "a", Deprecation: Deprecation{SunsetAt: "2020-01-01T00:00:00Z"}})
+	assert.ErrorIs(t, err, InvalidDeprecationErr)
+	assert.Equal(t, LifecycleProposed, payments.Lifecycle)
+}
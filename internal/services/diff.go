@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// CurrentVersion refers to the live document of a service when diffing versions
+var CurrentVersion = "current"
+
+// diffIgnoredFields are bookkeeping fields which change on every version
+var diffIgnoredFields = map[string]bool{
+	"serviceId":  true,
+	"orgId":      true,
+	"labelPairs": true,
+	"version":    true,
+	"createdAt":  true,
+	"updatedAt":  true,
+	"createdBy":  true,
+	"updatedBy":  true,
+}
+
+// DiffServiceVersions compares two versions of a service field by field. Either side can be an archived version
+// or the live document referred to as CurrentVersion. The revisions made after the from version up to the to
+// version are returned with who made them and when.
+func (svc *Service) DiffServiceVersions(cctx context.CustomContext, serviceId string, params *DiffParameters) (*ServiceCatalogueDiff, error) {
+	versions, err := svc.ListAllServiceVersions(cctx, serviceId)
+	if err != nil {
+		return nil, err
+	}
+	live, err := svc.FetchServiceById(cctx, serviceId)
+	if err != nil && !errors.Is(err, NoDocumentFoundErr) {
+		return nil, err
+	}
+
+	history := map[int]*ServiceCatalogue{}
+	for _, version := range versions {
+		history[version.Version] = version.toServiceCatalogue()
+	}
+	if live != nil {
+		history[live.Version] = live
+	}
+	if len(history) == 0 {
+		return nil, NoDocumentFoundErr
+	}
+
+	from, err := resolveDiffVersion(params.From, history, live)
+	if err != nil {
+		return nil, err
+	}
+	to, err := resolveDiffVersion(params.To, history, live)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := diffServiceCatalogues(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []*ServiceCatalogueRevision{}
+	low, high := from.Version, to.Version
+	if low > high {
+		low, high = high, low
+	}
+	for v := low + 1; v <= high; v++ {
+		if svcCat, ok := history[v]; ok {
+			revisions = append(revisions, newServiceCatalogueRevision(svcCat))
+		}
+	}
+
+	return &ServiceCatalogueDiff{
+		ServiceId: serviceId,
+		From:      newServiceCatalogueRevision(from),
+		To:        newServiceCatalogueRevision(to),
+		Changes:   changes,
+		Revisions: revisions,
+	}, nil
+}
+
+func resolveDiffVersion(version string, history map[int]*ServiceCatalogue, live *ServiceCatalogue) (*ServiceCatalogue, error) {
+	if version == CurrentVersion {
+		if live == nil {
+			return nil, fmt.Errorf("service is deleted, no current version: %w", NoDocumentFoundErr)
+		}
+		return live, nil
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	svcCat, ok := history[v]
+	if !ok {
+		return nil, fmt.Errorf("version %d not found: %w", v, NoDocumentFoundErr)
+	}
+	return svcCat, nil
+}
+
+// diffServiceCatalogues lists the fields which differ between the two services sorted by field name
+func diffServiceCatalogues(from, to *ServiceCatalogue) ([]*FieldDiff, error) {
+	fromMap, err := structToMap(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate diff: %w", err)
+	}
+	toMap, err := structToMap(to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate diff: %w", err)
+	}
+
+	fields := map[string]bool{}
+	for field := range fromMap {
+		fields[field] = true
+	}
+	for field := range toMap {
+		fields[field] = true
+	}
+
+	changes := []*FieldDiff{}
+	for field := range fields {
+		if diffIgnoredFields[field] || reflect.DeepEqual(fromMap[field], toMap[field]) {
+			continue
+		}
+		changes = append(changes, &FieldDiff{Field: field, From: fromMap[field], To: toMap[field]})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+func newServiceCatalogueRevision(svcCat *ServiceCatalogue) *ServiceCatalogueRevision {
+	return &ServiceCatalogueRevision{
+		Version:   svcCat.Version,
+		ChangedAt: svcCat.UpdatedAt,
+		ChangedBy: svcCat.UpdatedBy,
+	}
+}
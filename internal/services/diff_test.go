@@ -0,0 +1,40 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffServiceVersions(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, svcCat := newTestService(t)
+
+	_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedAt: "2024-08-02T10:00:00Z", UpdatedBy: "bob"}, nil)
+	require.NoError(t, err)
+	_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Description: "acquires card payments from merchants", UpdatedAt: "2024-08-03T10:00:00Z", UpdatedBy: "carol"}, nil)
+	require.NoError(t, err)
+
+	diff, err := svc.DiffServiceVersions(cctx, svcCat.ServiceId, &DiffParameters{From: "1", To: CurrentVersion})
+	require.NoError(t, err)
+	assert.Equal(t, 1, diff.From.Version)
+	assert.Equal(t, "alice", diff.From.ChangedBy)
+	assert.Equal(t, &ServiceCatalogueRevision{Version: 3, ChangedAt: "2024-08-03T10:00:00Z", ChangedBy: "carol"}, diff.To)
+	assert.Equal(t, []*FieldDiff{
+		{Field: "description", From: "processes card payments", To: "acquires card payments from merchants"},
+		{Field: "name", From: "payments", To: "acquiring"},
+	}, diff.Changes)
+	assert.Equal(t, []*ServiceCatalogueRevision{
+		{Version: 2, ChangedAt: "2024-08-02T10:00:00Z", ChangedBy: "bob"},
+		{Version: 3, ChangedAt: "2024-08-03T10:00:00Z", ChangedBy: "carol"},
+	}, diff.Revisions)
+
+	diff, err = svc.DiffServiceVersions(cctx, svcCat.ServiceId, &DiffParameters{From: "2", To: "3"})
+	require.NoError(t, err)
+	assert.Equal(t, []*FieldDiff{{Field: "description", From: "processes card payments", To: "acquires card payments from merchants"}}, diff.Changes)
+
+	_, err = svc.DiffServiceVersions(cctx, svcCat.ServiceId, &DiffParameters{From: "7", To: CurrentVersion})
+	assert.ErrorIs(t, err, NoDocumentFoundErr)
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"strings"
+	"time"
+)
+
+var (
+	keyCreatedBy          = "createdBy.keyword"
+	facetLabels           = "labels"
+	facetOwners           = "owners"
+	facetLifecycles       = "lifecycles"
+	facetCreatedBy        = "createdBy"
+	facetCreatedPerMonth  = "createdPerMonth"
+	maxFacetBuckets       = 100
+	maxLabelFacetBuckets  = 1000
+	createdPerMonthFormat = "2006-01"
+)
+
+// facets counts the services matching the query per label, owner team, lifecycle, creator and month of creation.
+// The counts are aggregated by the database over all the matching services irrespective of pagination.
+func (svc *Service) facets(cctx context.CustomContext, query *database.Query) (*Facets, error) {
+	result, err := svc.search(cctx, &database.Body{
+		Query: query,
+		Size:  database.HitsSize(0),
+		Aggs: map[string]*database.Aggregation{
+			facetLabels:     {Terms: &database.TermsAggregation{Field: keyLabelPairs, Size: maxLabelFacetBuckets}},
+			facetOwners:     {Terms: &database.TermsAggregation{Field: keyOwnerTeam, Size: maxFacetBuckets}},
+			facetLifecycles: {Terms: &database.TermsAggregation{Field: keyLifecycle, Size: maxFacetBuckets, Missing: LifecycleProduction}},
+			facetCreatedBy:  {Terms: &database.TermsAggregation{Field: keyCreatedBy, Size: maxFacetBuckets}},
+			facetCreatedPerMonth: {DateHistogram: &database.DateHistogramAggregation{
+				Field:            keyCreatedAt,
+				CalendarInterval: database.CalendarMonth,
+			}},
+		},
+	}, database.ServiceCatalogueIndex)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return nil, err
+	}
+
+	facets := &Facets{
+		Labels:          map[string]map[string]int{},
+		Owners:          termCounts(result.Aggregations[facetOwners]),
+		Lifecycles:      termCounts(result.Aggregations[facetLifecycles]),
+		CreatedBy:       termCounts(result.Aggregations[facetCreatedBy]),
+		CreatedPerMonth: []*MonthlyCount{},
+	}
+	for pair, count := range termCounts(result.Aggregations[facetLabels]) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if facets.Labels[key] == nil {
+			facets.Labels[key] = map[string]int{}
+		}
+		facets.Labels[key][value] = count
+	}
+	if histogram := result.Aggregations[facetCreatedPerMonth]; histogram != nil {
+		for _, bucket := range histogram.Buckets {
+			millis, ok := bucket.Key.(float64)
+			if !ok {
+				return nil, fmt.Errorf("failed to parse the month of bucket %v", bucket.Key)
+			}
+			facets.CreatedPerMonth = append(facets.CreatedPerMonth, &MonthlyCount{
+				Month: time.UnixMilli(int64(millis)).UTC().Format(createdPerMonthFormat),
+				Count: bucket.DocCount,
+			})
+		}
+	}
+	return facets, nil
+}
+
+// termCounts are the counts of the buckets of a terms aggregation per value
+func termCounts(result *database.AggregationResult) map[string]int {
+	counts := map[string]int{}
+	if result == nil {
+		return counts
+	}
+	for _, bucket := range result.Buckets {
+		counts[fmt.Sprint(bucket.Key)] = bucket.DocCount
+	}
+	return counts
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFacets(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, repo, _ := newTestService(t)
+
+	team, err := svc.CreateTeam(cctx, (&CreateTeamRequest{Name: "ledgers", CreatedBy: "alice"}).RequestStructToTeamStruct(cctx))
+	require.NoError(t, err)
+	_, err = svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+		Name: "ledger", Description: "double entry ledger for payments", Ownership: Ownership{OwnerTeam: team.TeamId},
+		Labels: map[string]string{"tier": "1"}, Lifecycle: LifecycleExperimental, CreatedBy: "bob",
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	for i, createdAt := range []string{"2024-01-15T10:00:00Z", "2024-03-02T10:00:00Z"} {
+		legacy := fmt.Sprintf(`{"serviceId": "legacy-%d", "name": "legacy payments %d", "createdAt": %q, "createdBy": "carol", "updatedAt": %q}`,
+			i, i, createdAt, now.Format(time.RFC3339))
+		_, err := repo.CreateDocument(cctx, []byte(legacy), database.ServiceCatalogueIndex)
+		require.NoError(t, err)
+	}
+
+	t.Run("list", func(t *testing.T) {
+		listParams := &ListParameters{Facets: true}
+		listParams.AddDefaultsIfEmpty()
+		facets, err := svc.ListFacets(cctx, listParams)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]map[string]int{"tier": {"1": 1}}, facets.Labels)
+		assert.Equal(t, map[string]int{team.TeamId: 1}, facets.Owners)
+		assert.Equal(t, map[string]int{LifecycleProposed: 1, LifecycleExperimental: 1, LifecycleProduction: 2}, facets.Lifecycles, "services without a lifecycle are in production")
+		assert.Equal(t, map[string]int{"alice": 1, "bob": 1, "carol": 2}, facets.CreatedBy)
+
+		months := []*MonthlyCount{{Month: "2024-01", Count: 1}, {Month: "2024-02", Count: 0}, {Month: "2024-03", Count: 1}}
+		require.Greater(t, len(facets.CreatedPerMonth), len(months))
+		assert.Equal(t, months, facets.CreatedPerMonth[:len(months)], "months without services are counted too")
+		assert.Equal(t, &MonthlyCount{Month: now.Format("2006-01"), Count: 2}, facets.CreatedPerMonth[len(facets.CreatedPerMonth)-1])
+	})
+
+	t.Run("list filtered on the owner", func(t *testing.T) {
+		listParams := &ListParameters{Owner: team.TeamId, Facets: true}
+		listParams.AddDefaultsIfEmpty()
+		facets, err := svc.ListFacets(cctx, listParams)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"bob": 1}, facets.CreatedBy)
+		assert.Equal(t, []*MonthlyCount{{Month: now.Format("2006-01"), Count: 1}}, facets.CreatedPerMonth)
+	})
+
+	t.Run("search counts all the matches irrespective of the page", func(t *testing.T) {
+		size := 1
+		searchParams := &SearchParameters{Search: "payments", Size: &size, Facets: true}
+		searchParams.AddDefaultsIfEmpty()
+		svcCats, _, err := svc.FuzzySearchService(cctx, searchParams)
+		require.NoError(t, err)
+		assert.Len(t, svcCats, 1)
+
+		facets, err := svc.SearchFacets(cctx, searchParams)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"alice": 1, "bob": 1, "carol": 2}, facets.CreatedBy)
+		assert.Empty(t, facets.Labels["pci"])
+	})
+
+	t.Run("updated labels are counted", func(t *testing.T) {
+		searchParams := &SearchParameters{Search: "ledger"}
+		searchParams.AddDefaultsIfEmpty()
+		svcCats, _, err := svc.FuzzySearchService(cctx, searchParams)
+		require.NoError(t, err)
+		require.NotEmpty(t, svcCats)
+		_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: svcCats[0].ServiceId, Labels: map[string]string{"tier": "2", "pci": "true"}, UpdatedBy: "bob",
+		}, nil)
+		require.NoError(t, err)
+
+		listParams := &ListParameters{Facets: true}
+		listParams.AddDefaultsIfEmpty()
+		facets, err := svc.ListFacets(cctx, listParams)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]map[string]int{"tier": {"2": 1}, "pci": {"true": 1}}, facets.Labels)
+	})
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
 	"nikki-noceps/serviceCatalogue/pkg/context"
 	"nikki-noceps/serviceCatalogue/pkg/database"
@@ -12,11 +13,16 @@ import (
 // searchAndFetchServiceCatalogueList searches es with body provided.
 // Parses the response and fetches _source from hits.hits and tranforms to service catalogue list
 func (svc *Service) searchAndFetchServiceCatalogueList(cctx context.CustomContext, body *database.Body) ([]*ServiceCatalogue, error) {
-	hits, err := svc.esClient.SearchAndGetHits(cctx, body, database.ServiceCatalogueIndex)
+	result, err := svc.search(cctx, body, database.ServiceCatalogueIndex)
 	if err != nil {
 		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
 		return nil, err
 	}
+	return decodeServiceCatalogueHits(cctx, result.Hits), nil
+}
+
+// decodeServiceCatalogueHits fetches _source from hits and tranforms to service catalogue list
+func decodeServiceCatalogueHits(cctx context.CustomContext, hits []any) []*ServiceCatalogue {
 	svcCatalogue := []*ServiceCatalogue{}
 	for _, hit := range hits {
 		hitMap, ok := hit.(map[string]interface{})
@@ -30,25 +36,28 @@ func (svc *Service) searchAndFetchServiceCatalogueList(cctx context.CustomContex
 			cctx.Logger().DEBUG("failed to decode hit", tag.NewErrorTag(err))
 			continue
 		}
+		svcCat.Labels = compactLabels(svcCat.Labels)
+		svcCat.Attributes = compactAttributes(svcCat.Attributes)
+		svcCat.Highlights = decodeHighlights(hitMap["highlight"])
 		svcCatalogue = append(svcCatalogue, &svcCat)
 	}
-	return svcCatalogue, nil
+	return svcCatalogue
 }
 
 // searchAndFetchServiceCatalogue searches es with body provided.
 // Parses the response and fetches _source from hits.hits and tranforms to service catalogue object
 func (svc *Service) searchAndFetchServiceCatalogue(cctx context.CustomContext, body *database.Body) (map[string]any, error) {
-	hits, err := svc.esClient.SearchAndGetHits(cctx, body, database.ServiceCatalogueIndex)
+	result, err := svc.search(cctx, body, database.ServiceCatalogueIndex)
 	if err != nil {
 		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
-	if len(hits) == 0 {
+	if len(result.Hits) == 0 {
 		return nil, NoDocumentFoundErr
 	}
-	hitmap, ok := hits[0].(map[string]any)
+	hitmap, ok := result.Hits[0].(map[string]any)
 	if !ok {
-		cctx.Logger().DEBUG("failed to parse hit", tag.NewAnyTag("hit", hits))
+		cctx.Logger().DEBUG("failed to parse hit", tag.NewAnyTag("hit", result.Hits))
 		return nil, fmt.Errorf("document parsing failed")
 	}
 
@@ -58,11 +67,16 @@ func (svc *Service) searchAndFetchServiceCatalogue(cctx context.CustomContext, b
 // searchAndFetchServiceCatalogueVersions searches for all versions of a given serviceId
 // Parses the response and fetches _source from hits.hits and tranforms to service catalogue versions list
 func (svc *Service) searchAndFetchServiceCatalogueVersions(cctx context.CustomContext, body *database.Body) ([]*ServiceCatalogueVersion, error) {
-	hits, err := svc.esClient.SearchAndGetHits(cctx, body, database.ServiceCatalogueVersionIndex)
+	result, err := svc.search(cctx, body, database.ServiceCatalogueVersionIndex)
 	if err != nil {
 		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
 		return nil, err
 	}
+	return decodeServiceCatalogueVersionHits(cctx, result.Hits), nil
+}
+
+// decodeServiceCatalogueVersionHits fetches _source from hits and tranforms to service catalogue versions list
+func decodeServiceCatalogueVersionHits(cctx context.CustomContext, hits []any) []*ServiceCatalogueVersion {
 	svcCatalogue := []*ServiceCatalogueVersion{}
 	for _, hit := range hits {
 		hitMap, ok := hit.(map[string]interface{})
@@ -78,37 +92,39 @@ func (svc *Service) searchAndFetchServiceCatalogueVersions(cctx context.CustomCo
 		}
 		svcCatalogue = append(svcCatalogue, &svcCat)
 	}
-	return svcCatalogue, nil
+	return svcCatalogue
 }
 
-// fetchServiceCatalogueVersion looks up a specific versionId provided in body
-// Parses the response and fetches _source from hits.hits and tranforms to service catalogue versions list
-func (svc *Service) fetchServiceCatalogueVersion(cctx context.CustomContext, body *database.Body) (*ServiceCatalogueVersion, error) {
-	hits, err := svc.esClient.SearchAndGetHits(cctx, body, database.ServiceCatalogueVersionIndex)
+// searchAndFetchServiceCatalogueVersion searches the versions index with body provided and returns the first hit
+func (svc *Service) searchAndFetchServiceCatalogueVersion(cctx context.CustomContext, body *database.Body) (map[string]any, error) {
+	result, err := svc.search(cctx, body, database.ServiceCatalogueVersionIndex)
 	if err != nil {
 		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
 		return nil, fmt.Errorf("failed to search: %w", err)
 	}
-	if len(hits) == 0 {
+	if len(result.Hits) == 0 {
 		return nil, NoDocumentFoundErr
 	}
-	hitmap, ok := hits[0].(map[string]any)
+	hitmap, ok := result.Hits[0].(map[string]any)
 	if !ok {
-		cctx.Logger().DEBUG("failed to parse hit", tag.NewAnyTag("hit", hits))
+		cctx.Logger().DEBUG("failed to parse hit", tag.NewAnyTag("hit", result.Hits))
 		return nil, fmt.Errorf("document parsing failed")
 	}
+	return hitmap, nil
+}
 
-	svcCat := &ServiceCatalogueVersion{}
-	err = mapstructure.Decode(hitmap["_source"], svcCat)
+// fetchServiceCatalogueVersion looks up a specific versionId provided in body
+// Parses the response and fetches _source from hits.hits and tranforms to service catalogue versions list
+func (svc *Service) fetchServiceCatalogueVersion(cctx context.CustomContext, body *database.Body) (*ServiceCatalogueVersion, error) {
+	hitmap, err := svc.searchAndFetchServiceCatalogueVersion(cctx, body)
 	if err != nil {
-		cctx.Logger().DEBUG("failed to decode hit", tag.NewErrorTag(err))
-		return nil, fmt.Errorf("failed to decode hit: %w", err)
+		return nil, err
 	}
-	return svcCat, nil
+	return svc.mapToServiceCatalogueVersion(cctx, hitmap)
 }
 
-func (svc *Service) deleteServiceCatalogue(cctx context.CustomContext, docId string) error {
-	return svc.esClient.DeleteDocument(cctx, database.ServiceCatalogueIndex, docId)
+func (svc *Service) deleteServiceCatalogue(cctx context.CustomContext, docId string, revision *database.Revision) error {
+	return svc.repo.DeleteDocument(cctx, database.ServiceCatalogueIndex, docId, revision)
 }
 
 // parses hits["_source"] received from es to service catalogue response
@@ -119,9 +135,44 @@ func (svc *Service) mapToServiceCatalogue(cctx context.CustomContext, hitmap map
 		cctx.Logger().DEBUG("failed to decode hit", tag.NewErrorTag(err))
 		return nil, fmt.Errorf("failed to decode hit: %w", err)
 	}
+	svcCat.Labels = compactLabels(svcCat.Labels)
+	svcCat.Attributes = compactAttributes(svcCat.Attributes)
 	return svcCat, nil
 }
 
+// updatedServiceCatalogue is the service as stored once the partial update was applied on the source of the hit
+// it was read from, the update is merged the way the repository merges it
+func (svc *Service) updatedServiceCatalogue(cctx context.CustomContext, hitmap map[string]any, updateMap map[string]any) (*ServiceCatalogue, error) {
+	sourceBytes, err := json.Marshal(hitmap["_source"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal hit: %w", err)
+	}
+	updateBytes, err := json.Marshal(updateMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal update doc: %w", err)
+	}
+	source, update := map[string]any{}, map[string]any{}
+	if err := json.Unmarshal(sourceBytes, &source); err != nil {
+		return nil, fmt.Errorf("failed to parse hit: %w", err)
+	}
+	if err := json.Unmarshal(updateBytes, &update); err != nil {
+		return nil, fmt.Errorf("failed to parse update doc: %w", err)
+	}
+	database.MergeDocuments(source, update)
+	return svc.mapToServiceCatalogue(cctx, map[string]any{"_source": source})
+}
+
+// parses hits["_source"] received from es to service catalogue version
+func (svc *Service) mapToServiceCatalogueVersion(cctx context.CustomContext, hitmap map[string]any) (*ServiceCatalogueVersion, error) {
+	svcVersion := &ServiceCatalogueVersion{}
+	err := mapstructure.Decode(hitmap["_source"], svcVersion)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to decode hit", tag.NewErrorTag(err))
+		return nil, fmt.Errorf("failed to decode hit: %w", err)
+	}
+	return svcVersion, nil
+}
+
 func structToMap(v any) (map[string]any, error) {
 	var result map[string]any
 	err := mapstructure.Decode(v, &result)
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/database"
+)
+
+var (
+	defaultHighlightPreTag  = "<em>"
+	defaultHighlightPostTag = "</em>"
+	maxHighlightTagLength   = 32
+)
+
+// searchHighlight highlights the words matching a search in the name and the description with the tags. The name
+// is returned whole and the description in fragments around the matching words. The fragments are rendered as
+// html, so the text around the tags is html escaped.
+func searchHighlight(preTag string, postTag string) *database.Highlight {
+	return &database.Highlight{
+		Fields: map[string]*database.HighlightField{
+			database.NameField:        {},
+			database.DescriptionField: {},
+		},
+		PreTags:  []string{preTag},
+		PostTags: []string{postTag},
+		Encoder:  database.HighlightEncoderHTML,
+	}
+}
+
+// decodeHighlights decodes the `highlight` of a hit, the fragments per field. It is nil for hits without one.
+func decodeHighlights(highlight any) map[string][]string {
+	fields, ok := highlight.(map[string]any)
+	if !ok || len(fields) == 0 {
+		return nil
+	}
+	highlights := map[string][]string{}
+	for field, fragments := range fields {
+		values, _ := fragments.([]any)
+		for _, value := range values {
+			if fragment, ok := value.(string); ok {
+				highlights[field] = append(highlights[field], fragment)
+			}
+		}
+	}
+	return highlights
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchHighlights(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, _ := newTestService(t)
+
+	_, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+		Name: "ledger", Description: "double entry ledger for payments", CreatedBy: "alice",
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	search := func(searchParams *SearchParameters) map[string]map[string][]string {
+		searchParams.AddDefaultsIfEmpty()
+		require.NoError(t, searchParams.Validate())
+		svcCats, _, err := svc.FuzzySearchService(cctx, searchParams)
+		require.NoError(t, err)
+		highlights := map[string]map[string][]string{}
+		for _, svcCat := range svcCats {
+			highlights[svcCat.Name] = svcCat.Highlights
+		}
+		return highlights
+	}
+
+	assert.Equal(t, map[string]map[string][]string{
+		"payments": {"name": {"<em>payments</em>"}, "description": {"processes card <em>payments</em>"}},
+		"ledger":   {"description": {"double entry ledger for <em>payments</em>"}},
+	}, search(&SearchParameters{Search: "paymnts"}), "the description is highlighted when only the description matches")
+
+	assert.Equal(t, map[string]map[string][]string{
+		"ledger": {"name": {"<mark>ledger</mark>"}, "description": {"double entry <mark>ledger</mark> for payments"}},
+	}, search(&SearchParameters{Search: "ledger", PreTag: "<mark>", PostTag: "</mark>"}))
+
+	_, err = svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+		Name: "vault", Description: `card vault <img src=x onerror="alert(1)">`, CreatedBy: "alice",
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string][]string{
+		"vault": {"name": {"<em>vault</em>"}, "description": {"card <em>vault</em> &lt;img src=x onerror=&#34;alert(1)&#34;&gt;"}},
+	}, search(&SearchParameters{Search: "vault"}), "the markup of the description is escaped")
+
+	searchParams := &SearchParameters{Search: "ledger", PreTag: "<" + strings.Repeat("b", maxHighlightTagLength) + ">"}
+	searchParams.AddDefaultsIfEmpty()
+	assert.ErrorContains(t, searchParams.Validate(), "preTag")
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"slices"
+	"strings"
+)
+
+var (
+	ImpactFormatJSON    = "json"
+	ImpactFormatDOT     = "dot"
+	ImpactFormatMermaid = "mermaid"
+)
+
+var (
+	dotEscaper     = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	mermaidEscaper = strings.NewReplacer(`"`, "#quot;")
+)
+
+// ImpactAnalysis answers what is affected when the service goes down: the services depending on it up to
+// depth hops, each with the shortest path the outage travels to it, and the teams owning them. The teams are
+// resolved to their names so the report reads without another lookup.
+func (svc *Service) ImpactAnalysis(cctx context.CustomContext, serviceId string, depth int) (*Impact, error) {
+	graph, err := svc.DependencyGraph(cctx, serviceId, Dependents, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[string]*DependencyNode{}
+	teamIds := []string{}
+	for _, node := range graph.Nodes {
+		nodes[node.ServiceId] = node
+		if node.OwnerTeam != "" && !slices.Contains(teamIds, node.OwnerTeam) {
+			teamIds = append(teamIds, node.OwnerTeam)
+		}
+	}
+	teamNames, err := svc.teamNames(cctx, teamIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range graph.Nodes {
+		node.OwnerTeamName = teamNames[node.OwnerTeam]
+	}
+
+	impact := &Impact{
+		ServiceId: serviceId,
+		Depth:     depth,
+		Impacted:  []*ImpactedService{},
+		Teams:     []*ImpactedTeam{},
+		Graph:     graph,
+	}
+	for _, node := range graph.Nodes[1:] {
+		path := []string{}
+		for id := node.ServiceId; id != ""; id = nodes[id].Via {
+			path = append(path, id)
+		}
+		slices.Reverse(path)
+
+		impact.Impacted = append(impact.Impacted, &ImpactedService{
+			ServiceId:     node.ServiceId,
+			Name:          node.Name,
+			OwnerTeam:     node.OwnerTeam,
+			OwnerTeamName: node.OwnerTeamName,
+			Depth:         node.Depth,
+			Path:          path,
+		})
+		if node.OwnerTeam != "" && !slices.ContainsFunc(impact.Teams, func(team *ImpactedTeam) bool { return team.TeamId == node.OwnerTeam }) {
+			impact.Teams = append(impact.Teams, &ImpactedTeam{TeamId: node.OwnerTeam, Name: node.OwnerTeamName})
+		}
+	}
+	slices.SortFunc(impact.Teams, func(a, b *ImpactedTeam) int { return strings.Compare(a.TeamId, b.TeamId) })
+	return impact, nil
+}
+
+// DOT renders the graph in the graphviz DOT language. Edges point from a service to the service it depends
+// on and the root service is highlighted.
+func (g *DependencyGraph) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph \"%s\" {\n", dotEscaper.Replace(g.ServiceId))
+	b.WriteString("  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		style := ""
+		if node.ServiceId == g.ServiceId {
+			style = `, style=filled, fillcolor="#f8d7da"`
+		}
+		fmt.Fprintf(&b, "  \"%s\" [label=\"%s\"%s];\n", dotEscaper.Replace(node.ServiceId), nodeLabel(node, dotEscaper, `\n`), style)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  \"%s\" -> \"%s\" [label=\"%s\"];\n", dotEscaper.Replace(edge.From), dotEscaper.Replace(edge.To), dotEscaper.Replace(edge.Type))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a mermaid flowchart, laid out like DOT. Mermaid ids can not hold every
+// character of a serviceId so the nodes are numbered.
+func (g *DependencyGraph) Mermaid() string {
+	ids := map[string]string{}
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for i, node := range g.Nodes {
+		ids[node.ServiceId] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[node.ServiceId], nodeLabel(node, mermaidEscaper, "<br/>"))
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", ids[edge.From], mermaidEscaper.Replace(edge.Type), ids[edge.To])
+	}
+	if root, ok := ids[g.ServiceId]; ok {
+		fmt.Fprintf(&b, "  style %s fill:#f8d7da\n", root)
+	}
+	return b.String()
+}
+
+// nodeLabel is the escaped name of the service followed by its owner team on the next line, by name when it was
+// resolved
+func nodeLabel(node *DependencyNode, escaper *strings.Replacer, lineBreak string) string {
+	label := node.Name
+	if label == "" {
+		label = node.ServiceId
+	}
+	label = escaper.Replace(label)
+	switch {
+	case node.OwnerTeamName != "":
+		label += lineBreak + escaper.Replace(node.OwnerTeamName)
+	case node.OwnerTeam != "":
+		label += lineBreak + escaper.Replace(node.OwnerTeam)
+	}
+	return label
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImpactAnalysis(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, payments := newTestService(t)
+	storefront := newTestTeam(t, svc, "storefront")
+	create := func(name string, ownerTeam string, dependsOn ...*Dependency) *ServiceCatalogue {
+		svcCat, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service with dependencies", Ownership: Ownership{OwnerTeam: ownerTeam},
+			DependsOn: dependsOn, CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+		return svcCat
+	}
+	gateway := create("gateway", "", &Dependency{ServiceId: payments.ServiceId, Type: DependencyHTTP})
+	checkout := create("checkout", storefront.TeamId, &Dependency{ServiceId: gateway.ServiceId, Type: DependencyHTTP})
+	refunds := create("refunds", storefront.TeamId,
+		&Dependency{ServiceId: payments.ServiceId, Type: DependencyQueue},
+		&Dependency{ServiceId: checkout.ServiceId, Type: DependencyGRPC},
+	)
+	create("search", "")
+
+	impact, err := svc.ImpactAnalysis(cctx, payments.ServiceId, maxDependencyDepth)
+	require.NoError(t, err)
+	paths := map[string][]string{}
+	for _, impacted := range impact.Impacted {
+		paths[impacted.Name] = impacted.Path
+	}
+	assert.Equal(t, map[string][]string{
+		"gateway":  {payments.ServiceId, gateway.ServiceId},
+		"refunds":  {payments.ServiceId, refunds.ServiceId},
+		"checkout": {payments.ServiceId, gateway.ServiceId, checkout.ServiceId},
+	}, paths)
+	assert.Equal(t, []*ImpactedTeam{{TeamId: storefront.TeamId, Name: "storefront"}}, impact.Teams)
+	for _, impacted := range impact.Impacted {
+		if impacted.OwnerTeam != "" {
+			assert.Equal(t, "storefront", impacted.OwnerTeamName, impacted.Name)
+		}
+	}
+
+	impact, err = svc.ImpactAnalysis(cctx, payments.ServiceId, 1)
+	require.NoError(t, err)
+	assert.Len(t, impact.Impacted, 2)
+
+	impact, err = svc.ImpactAnalysis(cctx, gateway.ServiceId, maxDependencyDepth)
+	require.NoError(t, err)
+	assert.Equal(t, "digraph \""+gateway.ServiceId+"\" {\n"+
+		"  rankdir=LR;\n"+
+		"  \""+gateway.ServiceId+"\" [label=\"gateway\", style=filled, fillcolor=\"#f8d7da\"];\n"+
+		"  \""+checkout.ServiceId+"\" [label=\"checkout\\nstorefront\"];\n"+
+		"  \""+refunds.ServiceId+"\" [label=\"refunds\\nstorefront\"];\n"+
+		"  \""+checkout.ServiceId+"\" -> \""+gateway.ServiceId+"\" [label=\"http\"];\n"+
+		"  \""+refunds.ServiceId+"\" -> \""+checkout.ServiceId+"\" [label=\"grpc\"];\n"+
+		"}\n", impact.Graph.DOT())
+	assert.Equal(t, "graph LR\n"+
+		"  n0[\"gateway\"]\n"+
+		"  n1[\"checkout<br/>storefront\"]\n"+
+		"  n2[\"refunds<br/>storefront\"]\n"+
+		"  n1 -->|http| n0\n"+
+		"  n2 -->|grpc| n1\n"+
+		"  style n0 fill:#f8d7da\n", impact.Graph.Mermaid())
+
+	_, err = svc.ImpactAnalysis(cctx, "missing", 1)
+	assert.ErrorIs(t, err, NoDocumentFoundErr)
+}
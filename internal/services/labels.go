@@ -0,0 +1,188 @@
+package services
+
+import (
+	"fmt"
+	"maps"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	keyLabels       = "labels"
+	keyLabelPairs   = "labelPairs"
+	labelKeyRegex   = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]{0,61}[a-z0-9])?$`)
+	labelValueRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?$`)
+	labelSetRegex   = regexp.MustCompile(`^(\S+)\s+(in|notin)\s+\((.*)\)$`)
+	maxLabels       = 32
+)
+
+// InvalidLabelSelectorErr is returned when a label selector can not be parsed
+var InvalidLabelSelectorErr error = fmt.Errorf("INVALID_LABEL_SELECTOR")
+
+// LabelOperator is the operator of a label selector requirement, following kubernetes label selectors
+type LabelOperator string
+
+var (
+	LabelEquals       LabelOperator = "="
+	LabelNotEquals    LabelOperator = "!="
+	LabelIn           LabelOperator = "in"
+	LabelNotIn        LabelOperator = "notin"
+	LabelExists       LabelOperator = "exists"
+	LabelDoesNotExist LabelOperator = "!"
+)
+
+// LabelRequirement is a single requirement of a label selector like `tier=1` or `env in (prd,stg)`
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	Values   []string
+}
+
+// ParseLabelSelector parses a comma separated kubernetes style label selector. Supported are
+// `key=value`, `key==value`, `key!=value`, `key in (v1,v2)`, `key notin (v1,v2)`, `key` and `!key`.
+func ParseLabelSelector(selector string) ([]*LabelRequirement, error) {
+	requirements := []*LabelRequirement{}
+	for _, term := range splitLabelSelector(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return nil, fmt.Errorf("%w: empty requirement in %q", InvalidLabelSelectorErr, selector)
+		}
+
+		requirement := &LabelRequirement{}
+		switch {
+		case labelSetRegex.MatchString(term):
+			match := labelSetRegex.FindStringSubmatch(term)
+			requirement.Key = match[1]
+			requirement.Operator = LabelOperator(match[2])
+			for _, value := range strings.Split(match[3], ",") {
+				requirement.Values = append(requirement.Values, strings.TrimSpace(value))
+			}
+		case strings.HasPrefix(term, "!"):
+			requirement.Key = strings.TrimSpace(term[1:])
+			requirement.Operator = LabelDoesNotExist
+		case strings.Contains(term, "!="):
+			key, value, _ := strings.Cut(term, "!=")
+			requirement.Key, requirement.Operator, requirement.Values = strings.TrimSpace(key), LabelNotEquals, []string{strings.TrimSpace(value)}
+		case strings.Contains(term, "="):
+			key, value, _ := strings.Cut(term, "=")
+			value = strings.TrimPrefix(value, "=")
+			requirement.Key, requirement.Operator, requirement.Values = strings.TrimSpace(key), LabelEquals, []string{strings.TrimSpace(value)}
+		default:
+			requirement.Key = term
+			requirement.Operator = LabelExists
+		}
+
+		if !labelKeyRegex.MatchString(requirement.Key) {
+			return nil, fmt.Errorf("%w: invalid label key %q", InvalidLabelSelectorErr, requirement.Key)
+		}
+		for _, value := range requirement.Values {
+			if !labelValueRegex.MatchString(value) {
+				return nil, fmt.Errorf("%w: invalid value %q for label %q", InvalidLabelSelectorErr, value, requirement.Key)
+			}
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// splitLabelSelector splits the selector on the commas which are not inside a value set
+func splitLabelSelector(selector string) []string {
+	terms := []string{}
+	depth, start := 0, 0
+	for i, char := range selector {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, selector[start:])
+}
+
+// labelSelectorQuery compiles the requirements to bool clauses. Like kubernetes `!=` and `notin`
+// also match services which do not have the label.
+func labelSelectorQuery(requirements []*LabelRequirement) (must []database.Query, mustNot []database.Query) {
+	for _, requirement := range requirements {
+		field := keyLabels + "." + requirement.Key
+		values := make([]any, 0, len(requirement.Values))
+		for _, value := range requirement.Values {
+			values = append(values, value)
+		}
+
+		switch requirement.Operator {
+		case LabelEquals:
+			must = append(must, database.Query{Term: &database.TermQuery{field: {Value: requirement.Values[0]}}})
+		case LabelNotEquals:
+			mustNot = append(mustNot, database.Query{Term: &database.TermQuery{field: {Value: requirement.Values[0]}}})
+		case LabelIn:
+			must = append(must, database.Query{Terms: &database.TermsQuery{field: values}})
+		case LabelNotIn:
+			mustNot = append(mustNot, database.Query{Terms: &database.TermsQuery{field: values}})
+		case LabelExists:
+			must = append(must, database.Query{Exists: &database.ExistsQuery{Field: field}})
+		case LabelDoesNotExist:
+			mustNot = append(mustNot, database.Query{Exists: &database.ExistsQuery{Field: field}})
+		}
+	}
+	return must, mustNot
+}
+
+// validateLabels checks the label keys and values and their count
+func validateLabels(value any) error {
+	labels, _ := value.(map[string]string)
+	if len(labels) > maxLabels {
+		return fmt.Errorf("at most %d labels are allowed", maxLabels)
+	}
+	for key, value := range labels {
+		if !labelKeyRegex.MatchString(key) {
+			return fmt.Errorf("invalid label key %q: must be lower case alphanumeric, `-` or `_`", key)
+		}
+		if !labelValueRegex.MatchString(value) {
+			return fmt.Errorf("invalid value %q for label %q: must be alphanumeric, `-`, `_` or `.`", value, key)
+		}
+	}
+	return nil
+}
+
+// labelsUpdate replaces the current labels with the new ones. Partial updates merge objects, so the
+// labels which are not kept anymore are set to null.
+func labelsUpdate(current map[string]string, labels map[string]string) map[string]any {
+	update := map[string]any{}
+	for key := range current {
+		update[key] = nil
+	}
+	for key, value := range labels {
+		update[key] = value
+	}
+	return update
+}
+
+// compactLabels drops the labels nulled by labelsUpdate, which are decoded as empty values
+func compactLabels(labels map[string]string) map[string]string {
+	maps.DeleteFunc(labels, func(_ string, value string) bool {
+		return value == ""
+	})
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// labelPairs are the labels as sorted `key=value` pairs. Labels are stored as a flattened field which can not
+// be aggregated per key, the pairs are stored alongside so the services can be counted per label key and value.
+func labelPairs(labels map[string]string) []string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
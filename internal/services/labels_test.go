@@ -0,0 +1,123 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	requirements, err := ParseLabelSelector("tier=1,pci==true,env!=dev,region in (eu, us),lang notin (java),oncall,!legacy")
+	require.NoError(t, err)
+	assert.Equal(t, []*LabelRequirement{
+		{Key: "tier", Operator: LabelEquals, Values: []string{"1"}},
+		{Key: "pci", Operator: LabelEquals, Values: []string{"true"}},
+		{Key: "env", Operator: LabelNotEquals, Values: []string{"dev"}},
+		{Key: "region", Operator: LabelIn, Values: []string{"eu", "us"}},
+		{Key: "lang", Operator: LabelNotIn, Values: []string{"java"}},
+		{Key: "oncall", Operator: LabelExists},
+		{Key: "legacy", Operator: LabelDoesNotExist},
+	}, requirements)
+
+	for _, selector := range []string{"", "tier=1,", "Tier=1", "tier=", "region in (eu,)", "tier=a b"} {
+		_, err := ParseLabelSelector(selector)
+		assert.ErrorIs(t, err, InvalidLabelSelectorErr, selector)
+	}
+}
+
+func TestLabelsValidation(t *testing.T) {
+	create := &CreateServiceCatalogueRequest{
+		Name: "payments", Description: "processes card payments", Ownership: Ownership{OwnerTeam: "payments"}, CreatedBy: "alice",
+		Labels: map[string]string{"tier": "1", "pci": "true"},
+	}
+	assert.NoError(t, create.Validate())
+
+	create.Labels = map[string]string{"Tier": "1"}
+	assert.Error(t, create.Validate())
+	create.Labels = map[string]string{"tier": ""}
+	assert.Error(t, create.Validate())
+
+	// replacing the labels alone is a valid update
+	update := &UpdateServiceCatalogueRequest{ServiceId: "a", UpdatedBy: "bob", Labels: map[string]string{}}
+	assert.NoError(t, update.Validate())
+}
+
+func TestListAllServices_Labels(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, payments := newTestService(t)
+	create := func(name string, labels map[string]string) *ServiceCatalogue {
+		svcCat, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service with labels", Labels: labels, CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+		return svcCat
+	}
+	create("ledger", map[string]string{"tier": "1", "pci": "true", "lang": "go"})
+	create("notifications", map[string]string{"tier": "2", "lang": "java"})
+
+	_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+		ServiceId: payments.ServiceId, Labels: map[string]string{"tier": "1", "legacy": "true"}, UpdatedBy: "bob",
+	}, nil)
+	require.NoError(t, err)
+
+	list := func(selector string) []string {
+		listParams := &ListParameters{}
+		listParams.LabelSelector, err = ParseLabelSelector(selector)
+		require.NoError(t, err)
+		listParams.AddDefaultsIfEmpty()
+		svcCats, _, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		names := []string{}
+		for _, svcCat := range svcCats {
+			names = append(names, svcCat.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	assert.Equal(t, []string{"ledger", "payments"}, list("tier=1"))
+	assert.Equal(t, []string{"ledger"}, list("tier=1,pci=true"))
+	assert.Equal(t, []string{"notifications", "payments"}, list("pci!=true"))
+	assert.Equal(t, []string{"ledger", "notifications"}, list("lang in (go,java)"))
+	assert.Equal(t, []string{"payments"}, list("lang notin (go,java)"))
+	assert.Equal(t, []string{"payments"}, list("legacy"))
+	assert.Equal(t, []string{"ledger", "notifications"}, list("!legacy"))
+
+	t.Run("facets", func(t *testing.T) {
+		listParams := &ListParameters{}
+		listParams.AddDefaultsIfEmpty()
+		facets, err := svc.ListFacets(cctx, listParams)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"1": 2, "2": 1}, facets.Labels["tier"])
+		assert.Equal(t, map[string]int{"go": 1, "java": 1}, facets.Labels["lang"])
+	})
+
+	t.Run("updates replace the labels", func(t *testing.T) {
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: payments.ServiceId, Labels: map[string]string{"tier": "2"}, UpdatedBy: "bob",
+		}, nil)
+		require.NoError(t, err)
+		svcCat, err := svc.FetchServiceById(cctx, payments.ServiceId)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"tier": "2"}, svcCat.Labels)
+		assert.Equal(t, []string{"ledger", "notifications", "payments"}, list("!legacy"))
+
+		versions, err := svc.ListAllServiceVersions(cctx, payments.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, map[string]string{"tier": "1", "legacy": "true"}, versions[1].Labels)
+
+		// restoring the first version drops all the labels added since
+		restored, err := svc.RestoreServiceCatalogueVersion(cctx, &RestoreServiceCatalogueVersionRequest{
+			ServiceId: payments.ServiceId, VersionId: versions[0].VersionId, RestoredBy: "bob",
+		}, nil)
+		require.NoError(t, err)
+		assert.Empty(t, restored.Labels)
+		svcCat, err = svc.FetchServiceById(cctx, payments.ServiceId)
+		require.NoError(t, err)
+		assert.Empty(t, svcCat.Labels)
+	})
+}
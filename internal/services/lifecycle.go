@@ -0,0 +1,81 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"slices"
+)
+
+var keyLifecycle = "lifecycle.keyword"
+
+var (
+	LifecycleProposed     = "proposed"
+	LifecycleExperimental = "experimental"
+	LifecycleProduction   = "production"
+	LifecycleDeprecated   = "deprecated"
+	LifecycleRetired      = "retired"
+	lifecycles            = []any{LifecycleProposed, LifecycleExperimental, LifecycleProduction, LifecycleDeprecated, LifecycleRetired}
+	// initialLifecycles are the lifecycles a service can be created in, the later ones are only reached through
+	// lifecycleTransitions so every move is recorded on the versions
+	initialLifecycles = []any{LifecycleProposed, LifecycleExperimental}
+)
+
+// lifecycleTransitions are the states a service can move to from each state. Services normally move forward
+// one state at a time, a proposal can skip trying things out or be dropped, a deprecation can be taken
+// back and retired is final.
+var lifecycleTransitions = map[string][]string{
+	LifecycleProposed:     {LifecycleExperimental, LifecycleProduction, LifecycleRetired},
+	LifecycleExperimental: {LifecycleProduction, LifecycleRetired},
+	LifecycleProduction:   {LifecycleDeprecated},
+	LifecycleDeprecated:   {LifecycleProduction, LifecycleRetired},
+	LifecycleRetired:      {},
+}
+
+// InvalidLifecycleTransitionErr is returned when a service can not move from its lifecycle to the requested one
+var InvalidLifecycleTransitionErr error = fmt.Errorf("INVALID_LIFECYCLE_TRANSITION")
+
+// lifecycleOf is the lifecycle of the service. Services catalogued before lifecycles were introduced have
+// none and are taken to be in production.
+func lifecycleOf(svcCat *ServiceCatalogue) string {
+	if svcCat.Lifecycle == "" {
+		return LifecycleProduction
+	}
+	return svcCat.Lifecycle
+}
+
+// lifecycleTransition checks the service can move to the lifecycle and returns the transition,
+// nil when the lifecycle does not change
+func lifecycleTransition(svcCat *ServiceCatalogue, lifecycle string) (*LifecycleTransition, error) {
+	from := lifecycleOf(svcCat)
+	if lifecycle == "" || lifecycle == from {
+		return nil, nil
+	}
+	if !slices.Contains(lifecycleTransitions[from], lifecycle) {
+		return nil, fmt.Errorf("%w: %s can not move from %s to %s", InvalidLifecycleTransitionErr, svcCat.ServiceId, from, lifecycle)
+	}
+	return &LifecycleTransition{From: from, To: lifecycle}, nil
+}
+
+// lifecycleQuery lists the services in any of the lifecycles, retired services are left out unless asked for.
+// Services without a lifecycle are listed as production, see lifecycleOf.
+func lifecycleQuery(lifecycles []string) (must []database.Query, mustNot []database.Query) {
+	if len(lifecycles) == 0 {
+		return nil, []database.Query{{Term: &database.TermQuery{keyLifecycle: {Value: LifecycleRetired}}}}
+	}
+	values := make([]any, 0, len(lifecycles))
+	for _, lifecycle := range lifecycles {
+		values = append(values, lifecycle)
+	}
+	query := database.Query{Terms: &database.TermsQuery{keyLifecycle: values}}
+	if slices.Contains(lifecycles, LifecycleProduction) {
+		query = database.Query{
+			Bool: &database.BoolQuery{
+				Should: []database.Query{
+					query,
+					{Bool: &database.BoolQuery{MustNot: []database.Query{{Exists: &database.ExistsQuery{Field: keyLifecycle}}}}},
+				},
+			},
+		}
+	}
+	return []database.Query{query}, nil
+}
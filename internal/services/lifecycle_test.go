@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		allowed  bool
+	}{
+		{LifecycleProposed, LifecycleExperimental, true},
+		{LifecycleProposed, LifecycleProduction, true},
+		{LifecycleExperimental, LifecycleProduction, true},
+		{LifecycleProduction, LifecycleDeprecated, true},
+		{LifecycleDeprecated, LifecycleProduction, true},
+		{LifecycleDeprecated, LifecycleRetired, true},
+		{LifecycleProduction, LifecycleRetired, false},
+		{LifecycleProduction, LifecycleProposed, false},
+		{LifecycleRetired, LifecycleProduction, false},
+		// services without a lifecycle are in production
+		{"", LifecycleDeprecated, true},
+		{"", LifecycleExperimental, false},
+	} {
+		transition, err := lifecycleTransition(&ServiceCatalogue{Lifecycle: tc.from}, tc.to)
+		if !tc.allowed {
+			assert.ErrorIs(t, err, InvalidLifecycleTransitionErr, "%s -> %s", tc.from, tc.to)
+			continue
+		}
+		require.NoError(t, err, "%s -> %s", tc.from, tc.to)
+		assert.Equal(t, tc.to, transition.To)
+	}
+
+	transition, err := lifecycleTransition(&ServiceCatalogue{Lifecycle: LifecycleRetired}, LifecycleRetired)
+	assert.NoError(t, err)
+	assert.Nil(t, transition)
+}
+
+func TestCreateLifecycleValidation(t *testing.T) {
+	create := &CreateServiceCatalogueRequest{
+		Name: "payments", Description: "processes card payments", Ownership: Ownership{OwnerTeam: "payments"}, CreatedBy: "alice",
+	}
+	for _, lifecycle := range []string{"", LifecycleProposed, LifecycleExperimental} {
+		create.Lifecycle = lifecycle
+		assert.NoError(t, create.Validate(), lifecycle)
+	}
+	// later lifecycles are only reached through transitions
+	for _, lifecycle := range []string{LifecycleProduction, LifecycleDeprecated, LifecycleRetired} {
+		create.Lifecycle = lifecycle
+		assert.ErrorContains(t, create.Validate(), "lifecycle: must be proposed or experimental", lifecycle)
+	}
+}
+
+func TestListAllServices_Lifecycle(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, payments := newTestService(t)
+	assert.Equal(t, LifecycleProposed, payments.Lifecycle)
+
+	create := func(name string, lifecycle string) *ServiceCatalogue {
+		svcCat, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service with a lifecycle", Lifecycle: lifecycle, CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+		return svcCat
+	}
+	ledger := create("ledger", LifecycleProduction)
+	create("fraud", LifecycleExperimental)
+
+	transition := func(svcCat *ServiceCatalogue, lifecycle string) error {
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Lifecycle: lifecycle, UpdatedBy: "bob"}, nil)
+		return err
+	}
+	_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+		ServiceId: ledger.ServiceId, Lifecycle: LifecycleDeprecated, Deprecation: Deprecation{SunsetAt: "2030-01-01T00:00:00Z"}, UpdatedBy: "bob",
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, transition(ledger, LifecycleRetired))
+	assert.ErrorIs(t, transition(ledger, LifecycleProduction), InvalidLifecycleTransitionErr)
+	assert.ErrorIs(t, transition(payments, LifecycleDeprecated), InvalidLifecycleTransitionErr)
+
+	list := func(lifecycles ...string) []string {
+		listParams := &ListParameters{Lifecycle: lifecycles}
+		listParams.AddDefaultsIfEmpty()
+		require.NoError(t, listParams.Validate())
+		svcCats, _, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		names := []string{}
+		for _, svcCat := range svcCats {
+			names = append(names, svcCat.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	assert.Equal(t, []string{"fraud", "payments"}, list())
+	assert.Equal(t, []string{"ledger"}, list(LifecycleRetired))
+	assert.Equal(t, []string{"fraud", "ledger"}, list(LifecycleExperimental, LifecycleRetired))
+
+	t.Run("transitions are recorded in the versions", func(t *testing.T) {
+		versions, err := svc.ListAllServiceVersions(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		transitions := []*LifecycleTransition{}
+		for _, version := range versions {
+			if version.Transition != nil {
+				transitions = append(transitions, version.Transition)
+			}
+		}
+		assert.ElementsMatch(t, []*LifecycleTransition{
+			{From: LifecycleProduction, To: LifecycleDeprecated},
+			{From: LifecycleDeprecated, To: LifecycleRetired},
+		}, transitions)
+	})
+
+	t.Run("restoring a version keeps the lifecycle", func(t *testing.T) {
+		versions, err := svc.ListAllServiceVersions(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		restored, err := svc.RestoreServiceCatalogueVersion(cctx, &RestoreServiceCatalogueVersionRequest{
+			ServiceId: ledger.ServiceId, VersionId: versions[len(versions)-1].VersionId, RestoredBy: "carol",
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, LifecycleRetired, restored.Lifecycle)
+	})
+
+	t.Run("services without a lifecycle are listed as production", func(t *testing.T) {
+		current := create("current", LifecycleProduction)
+		legacy := fmt.Sprintf(`{"serviceId":"c0ffee","name":"mainframe","version":1,"createdAt":%q,"updatedAt":%q}`, current.CreatedAt, current.UpdatedAt)
+		_, err := svc.repo.CreateDocument(cctx, []byte(legacy), database.ServiceCatalogueIndex)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"current", "mainframe"}, list(LifecycleProduction))
+		assert.Contains(t, list(), "mainframe")
+	})
+}
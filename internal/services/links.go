@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"regexp"
+	"strings"
+)
+
+var (
+	keyLinks         = "links"
+	keyLinkKind      = "links.kind"
+	keyLinkHost      = "links.host"
+	environmentRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,30}[a-z0-9])?$`)
+	maxLinks         = 32
+)
+
+var (
+	LinkRepository    = "repository"
+	LinkDashboard     = "dashboard"
+	LinkRunbook       = "runbook"
+	LinkDocumentation = "documentation"
+	LinkEndpoint      = "endpoint"
+)
+
+var linkKinds = []any{LinkRepository, LinkDashboard, LinkRunbook, LinkDocumentation, LinkEndpoint}
+
+// withLinkHosts sets the host of every link from its url, so services can be listed by the host of their links
+func withLinkHosts(links []*Link) []*Link {
+	for _, link := range links {
+		link.Host = linkHost(link.Url)
+	}
+	return links
+}
+
+// linkHost is the lower cased host of the url without the port, empty when the url can not be parsed
+func linkHost(rawUrl string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// linksQuery lists the services with a link on any of the hosts, of the kind when set. The host and the kind
+// have to match on the same link, a repository on one host and a dashboard on another is not a match.
+func linksQuery(hosts []string, kind string) []database.Query {
+	if len(hosts) == 0 && kind == "" {
+		return nil
+	}
+	must := []database.Query{}
+	if len(hosts) > 0 {
+		values := make([]any, 0, len(hosts))
+		for _, host := range hosts {
+			values = append(values, strings.ToLower(host))
+		}
+		must = append(must, database.Query{Terms: &database.TermsQuery{keyLinkHost: values}})
+	}
+	if kind != "" {
+		must = append(must, database.Query{Term: &database.TermQuery{keyLinkKind: {Value: kind}}})
+	}
+	return []database.Query{{
+		Nested: &database.NestedQuery{
+			Path:  keyLinks,
+			Query: database.Query{Bool: &database.BoolQuery{Must: must}},
+		},
+	}}
+}
+
+// validateLinkUrl accepts absolute http and https urls
+func validateLinkUrl(value any) error {
+	rawUrl, _ := value.(string)
+	if rawUrl == "" {
+		return nil
+	}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("must be an absolute http or https url")
+	}
+	return nil
+}
+
+func validateLinks(value any) error {
+	links, _ := value.([]*Link)
+	if len(links) > maxLinks {
+		return fmt.Errorf("at most %d links are allowed", maxLinks)
+	}
+	seen := map[string]bool{}
+	for _, link := range links {
+		if link == nil {
+			return fmt.Errorf("link must not be null")
+		}
+		key := link.Kind + " " + link.Url
+		if seen[key] {
+			return fmt.Errorf("duplicate %s link to %q", link.Kind, link.Url)
+		}
+		seen[key] = true
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinksValidation(t *testing.T) {
+	request := func(links ...*Link) *CreateServiceCatalogueRequest {
+		return &CreateServiceCatalogueRequest{
+			Name: "ledger", Description: "double entry ledger for payments", Ownership: Ownership{OwnerTeam: "payments"},
+			Links: links, CreatedBy: "alice",
+		}
+	}
+
+	assert.NoError(t, request(
+		&Link{Kind: LinkRepository, Url: "https://github.com/acme/ledger"},
+		&Link{Kind: LinkEndpoint, Title: "API", Url: "http://ledger.internal:8080/v1", Environment: "staging"},
+	).Validate())
+
+	for name, link := range map[string]*Link{
+		"missing kind":         {Url: "https://github.com/acme/ledger"},
+		"unknown kind":         {Kind: "wiki", Url: "https://github.com/acme/ledger"},
+		"missing url":          {Kind: LinkRunbook},
+		"relative url":         {Kind: LinkRunbook, Url: "/runbooks/ledger"},
+		"not http":             {Kind: LinkRepository, Url: "git@github.com:acme/ledger.git"},
+		"invalid environment":  {Kind: LinkEndpoint, Url: "https://ledger.acme.com", Environment: "Production!"},
+		"url without the host": {Kind: LinkDashboard, Url: "https://"},
+	} {
+		assert.Error(t, request(link).Validate(), name)
+	}
+	assert.ErrorContains(t, request(
+		&Link{Kind: LinkDashboard, Url: "https://grafana.acme.com/d/ledger"},
+		&Link{Kind: LinkDashboard, Url: "https://grafana.acme.com/d/ledger"},
+	).Validate(), "duplicate dashboard link")
+}
+
+func TestServiceLinks(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, _ := newTestService(t)
+
+	create := func(name string, links ...*Link) *ServiceCatalogue {
+		svcCat, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service with links", Links: links, CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+		return svcCat
+	}
+	ledger := create("ledger",
+		&Link{Kind: LinkRepository, Url: "https://GitHub.com/acme/ledger"},
+		&Link{Kind: LinkDashboard, Title: "Latency", Url: "https://grafana.acme.com:3000/d/ledger"},
+	)
+	create("billing",
+		&Link{Kind: LinkRepository, Url: "https://gitlab.acme.com/acme/billing"},
+		&Link{Kind: LinkDocumentation, Url: "https://github.com/acme/billing/wiki"},
+	)
+	assert.Equal(t, "github.com", ledger.Links[0].Host)
+	assert.Equal(t, "grafana.acme.com", ledger.Links[1].Host)
+
+	list := func(hosts []string, kind string) []string {
+		listParams := &ListParameters{LinkHost: hosts, LinkKind: kind}
+		listParams.AddDefaultsIfEmpty()
+		require.NoError(t, listParams.Validate())
+		svcCats, _, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		names := []string{}
+		for _, svcCat := range svcCats {
+			names = append(names, svcCat.Name)
+		}
+		return names
+	}
+
+	t.Run("list by link host", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"ledger", "billing"}, list([]string{"github.com"}, ""))
+		assert.Equal(t, []string{"ledger"}, list([]string{"GITHUB.com"}, LinkRepository), "the host and kind match on the same link")
+		assert.ElementsMatch(t, []string{"ledger", "billing"}, list([]string{"github.com", "gitlab.acme.com"}, LinkRepository))
+		assert.Equal(t, []string{"billing"}, list(nil, LinkDocumentation))
+		assert.Empty(t, list([]string{"bitbucket.org"}, ""))
+	})
+
+	t.Run("updates replace the links and keep the previous ones in the history", func(t *testing.T) {
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: ledger.ServiceId,
+			Links:     []*Link{{Kind: LinkRepository, Url: "https://gitlab.acme.com/acme/ledger"}},
+			UpdatedBy: "bob",
+		}, nil)
+		require.NoError(t, err)
+
+		svcCat, err := svc.FetchServiceById(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		assert.Equal(t, []*Link{{Kind: LinkRepository, Url: "https://gitlab.acme.com/acme/ledger", Host: "gitlab.acme.com"}}, svcCat.Links)
+
+		versions, err := svc.ListAllServiceVersions(cctx, ledger.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, ledger.Links, versions[0].Links)
+
+		diff, err := svc.DiffServiceVersions(cctx, ledger.ServiceId, &DiffParameters{From: "1", To: CurrentVersion})
+		require.NoError(t, err)
+		fields := []string{}
+		for _, change := range diff.Changes {
+			fields = append(fields, change.Field)
+		}
+		assert.Contains(t, fields, "links")
+
+		restored, err := svc.RestoreServiceCatalogueVersion(cctx, &RestoreServiceCatalogueVersionRequest{
+			ServiceId: ledger.ServiceId, VersionId: versions[0].VersionId, RestoredBy: "carol",
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, ledger.Links, restored.Links)
+		assert.Equal(t, []string{"ledger"}, list([]string{"github.com"}, LinkRepository))
+	})
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+)
+
+var keyOrgId = "orgId.keyword"
+
+// orgOf is the organization the caller belongs to. Callers which are not authenticated, like jobs,
+// act on the default organization unless they scope themselves with context.WithOrgID.
+func orgOf(cctx context.CustomContext) string {
+	if orgId := cctx.OrgID(); orgId != "" {
+		return orgId
+	}
+	return config.DefaultOrgId
+}
+
+// search runs the search scoped to the organization of the caller, see orgQuery. Every search on behalf of
+// a caller goes through it so organizations never see each other's documents.
+func (svc *Service) search(cctx context.CustomContext, body *database.Body, index string) (*database.SearchResult, error) {
+	scoped := *body
+	scoped.Query = orgQuery(orgOf(cctx), body.Query)
+	return svc.repo.SearchAndGetHits(cctx, &scoped, index)
+}
+
+// orgQuery restricts the query to the documents of the organization without changing their relevance. Documents
+// stored before organizations were introduced have none and belong to the default organization.
+func orgQuery(orgId string, query *database.Query) *database.Query {
+	scope := database.Query{Term: &database.TermQuery{keyOrgId: {Value: orgId}}}
+	if orgId == config.DefaultOrgId {
+		scope = database.Query{
+			Bool: &database.BoolQuery{
+				Should: []database.Query{
+					scope,
+					{Bool: &database.BoolQuery{MustNot: []database.Query{{Exists: &database.ExistsQuery{Field: keyOrgId}}}}},
+				},
+			},
+		}
+	}
+	must := []database.Query{}
+	if query != nil {
+		must = append(must, *query)
+	}
+	return &database.Query{Bool: &database.BoolQuery{Must: must, Filter: []database.Query{scope}}}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganizations(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	acme := context.WithOrgID(cctx, "acme")
+	svc, repo, payments := newTestService(t)
+
+	create := func(cctx context.CustomContext, name string) *ServiceCatalogue {
+		svcCat, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service of acme", CreatedBy: "wile"}).RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+		return svcCat
+	}
+	list := func(cctx context.CustomContext) []string {
+		listParams := &ListParameters{}
+		listParams.AddDefaultsIfEmpty()
+		svcCats, _, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		names := []string{}
+		for _, svcCat := range svcCats {
+			names = append(names, svcCat.Name)
+		}
+		return names
+	}
+
+	rockets := create(acme, "rockets")
+	assert.Equal(t, "acme", rockets.OrgId)
+	assert.Equal(t, "default", payments.OrgId)
+
+	t.Run("list and search", func(t *testing.T) {
+		assert.Equal(t, []string{"payments"}, list(cctx))
+		assert.Equal(t, []string{"rockets"}, list(acme))
+
+		searchParams := &SearchParameters{Search: "service"}
+		searchParams.AddDefaultsIfEmpty()
+		svcCats, _, err := svc.FuzzySearchService(cctx, searchParams)
+		require.NoError(t, err)
+		assert.Empty(t, svcCats)
+		svcCats, _, err = svc.FuzzySearchService(acme, searchParams)
+		require.NoError(t, err)
+		assert.Len(t, svcCats, 1)
+	})
+
+	t.Run("fetch, update and versions", func(t *testing.T) {
+		_, err := svc.FetchServiceById(cctx, rockets.ServiceId)
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+		_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: rockets.ServiceId, Name: "stolen", UpdatedBy: "bob"}, nil)
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+
+		_, err = svc.UpdateServiceCatalogue(acme, &ServiceCatalogue{ServiceId: rockets.ServiceId, Name: "rockets-v2", UpdatedBy: "wile"}, nil)
+		require.NoError(t, err)
+		versions, err := svc.ListAllServiceVersions(acme, rockets.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, "acme", versions[0].OrgId)
+
+		versions, err = svc.ListAllServiceVersions(cctx, rockets.ServiceId)
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+
+		svcCat, err := svc.FetchServiceById(acme, rockets.ServiceId)
+		require.NoError(t, err)
+		assert.Equal(t, "acme", svcCat.OrgId, "updates keep the organization")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		err := svc.DeleteService(acme, payments.ServiceId, "wile", nil)
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+		_, err = svc.FetchServiceById(cctx, payments.ServiceId)
+		require.NoError(t, err)
+	})
+
+	t.Run("teams", func(t *testing.T) {
+		team, err := svc.CreateTeam(acme, (&CreateTeamRequest{Name: "coyotes", CreatedBy: "wile"}).RequestStructToTeamStruct(acme))
+		require.NoError(t, err)
+		_, err = svc.FetchTeamById(cctx, team.TeamId)
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+
+		_, err = svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: "ledger", Description: "owned by another organization", Ownership: Ownership{OwnerTeam: team.TeamId}, CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+		assert.ErrorIs(t, err, UnknownTeamErr)
+	})
+
+	t.Run("attributes schemas", func(t *testing.T) {
+		_, err := svc.SaveAttributesSchema(acme, []byte(`{"type": "object", "properties": {"tier": {"type": "integer"}}}`), "wile")
+		require.NoError(t, err)
+		_, err = svc.FetchAttributesSchema(cctx)
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+
+		// the attributes of every organization are mapped in the same indices
+		_, err = svc.SaveAttributesSchema(cctx, []byte(`{"type": "object", "properties": {"tier": {"type": "string"}}}`), "alice")
+		assert.ErrorIs(t, err, InvalidAttributesSchemaErr)
+	})
+
+	t.Run("documents without organization belong to the default organization", func(t *testing.T) {
+		legacy := fmt.Sprintf(`{"serviceId": "legacy", "name": "legacy", "version": 1, "updatedAt": %q}`, time.Now().UTC().Format(time.RFC3339))
+		_, err := repo.CreateDocument(cctx, []byte(legacy), database.ServiceCatalogueIndex)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"legacy", "payments"}, list(cctx))
+		assert.Equal(t, []string{"rockets-v2"}, list(acme))
+	})
+
+	t.Run("changes are reconciled on behalf of their organization", func(t *testing.T) {
+		change := &ServiceCatalogueChange{
+			ChangeId:  "change-1",
+			Operation: changeOperationDelete,
+			ServiceId: rockets.ServiceId,
+			Version:   2,
+			Archive:   &ServiceCatalogueVersion{ParentId: rockets.ServiceId, VersionId: "version-1", Version: 2},
+			CreatedAt: "2024-08-01T10:00:00Z",
+		}
+		require.NoError(t, svc.saveChange(acme, change))
+		require.NoError(t, svc.ReconcileChanges(cctx))
+
+		// the live document of acme is found, so the delete is rolled back rather than rolled forward
+		versions, err := svc.ListAllServiceVersions(acme, rockets.ServiceId)
+		require.NoError(t, err)
+		assert.Len(t, versions, 1)
+		assert.Empty(t, pendingChanges(t, svc))
+	})
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnershipValidation(t *testing.T) {
+	create := &CreateServiceCatalogueRequest{Name: "payments", Description: "processes card payments", CreatedBy: "alice"}
+	errs, ok := create.Validate().(validation.Errors)
+	require.True(t, ok)
+	assert.Contains(t, errs, "ownerTeam")
+
+	create.Ownership = Ownership{OwnerTeam: "payments", SlackChannel: "payments", Email: "not an email"}
+	errs, ok = create.Validate().(validation.Errors)
+	require.True(t, ok)
+	assert.Contains(t, errs, "slackChannel")
+	assert.Contains(t, errs, "email")
+	assert.NotContains(t, errs, "ownerTeam")
+
+	create.Ownership = Ownership{OwnerTeam: "payments", SlackChannel: "#payments-oncall", Email: "payments@example.com"}
+	assert.NoError(t, create.Validate())
+
+	// changing only the ownership is a valid update
+	update := &UpdateServiceCatalogueRequest{ServiceId: "a", UpdatedBy: "bob", Ownership: Ownership{OnCall: "carol"}}
+	assert.NoError(t, update.Validate())
+}
+
+func newTestTeam(t *testing.T, svc *Service, name string) *Team {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	team, err := svc.CreateTeam(cctx, (&CreateTeamRequest{Name: name, CreatedBy: "alice"}).RequestStructToTeamStruct(cctx))
+	require.NoError(t, err)
+	return team
+}
+
+func TestListAllServices_Owner(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, svcCat := newTestService(t)
+	payments := newTestTeam(t, svc, "payments")
+	accounting := newTestTeam(t, svc, "accounting")
+	acquiring := newTestTeam(t, svc, "acquiring")
+
+	_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+		ServiceId: svcCat.ServiceId,
+		Ownership: Ownership{OwnerTeam: payments.TeamId, OnCall: "carol"},
+		UpdatedBy: "bob",
+	}, nil)
+	require.NoError(t, err)
+	_, err = svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+		Name: "ledger", Description: "double entry ledger", Ownership: Ownership{OwnerTeam: accounting.TeamId}, CreatedBy: "alice",
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	listParams := &ListParameters{Owner: payments.TeamId}
+	listParams.AddDefaultsIfEmpty()
+	svcCats, page, err := svc.ListAllServices(cctx, listParams)
+	require.NoError(t, err)
+	require.Len(t, svcCats, 1)
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, svcCat.ServiceId, svcCats[0].ServiceId)
+	assert.Equal(t, Ownership{OwnerTeam: payments.TeamId, OnCall: "carol"}, svcCats[0].Ownership)
+
+	// the ownership is part of the version history
+	_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+		ServiceId: svcCat.ServiceId,
+		Ownership: Ownership{OwnerTeam: acquiring.TeamId},
+		UpdatedBy: "bob",
+	}, nil)
+	require.NoError(t, err)
+	versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, payments.TeamId, versions[1].OwnerTeam)
+	assert.Equal(t, "carol", versions[1].OnCall)
+}
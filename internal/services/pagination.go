@@ -0,0 +1,196 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"slices"
+	"strings"
+)
+
+var (
+	keyScore = "_score"
+	// pointInTimeKeepAlive is how long a point in time stays open between two pages
+	pointInTimeKeepAlive = "1m"
+	defaultPageSize      = 10
+)
+
+// InvalidCursorErr is returned when a cursor token can not be decoded
+var InvalidCursorErr error = fmt.Errorf("INVALID_CURSOR")
+
+// Cursor is a position in a listing handed out to clients as an opaque token. Besides the position it pins
+// what the first page was sorted and filtered on, so every following page lines up with it.
+type Cursor struct {
+	SearchAfter []any `json:"searchAfter"`
+	// Reverse pages backwards returning the hits sorted before SearchAfter
+	Reverse bool `json:"reverse,omitempty"`
+	// From is the offset of the page the cursor leads to
+	From           int                   `json:"from,omitempty"`
+	PitId          string                `json:"pitId,omitempty"`
+	Sort           []*database.SortField `json:"sort,omitempty"`
+	TimeStampField string                `json:"timeStampField,omitempty"`
+	TimeWindow     *TimeWindow           `json:"timeWindow,omitempty"`
+	AsOf           string                `json:"asOf,omitempty"`
+}
+
+// Page describes a page of a listing: how many hits there are in total, where the page is and what it was
+// sorted and filtered on. Next and Prev link to the pages around it, empty when there is none.
+type Page struct {
+	Total          int                   `json:"total"`
+	TotalRelation  string                `json:"totalRelation"`
+	From           int                   `json:"from"`
+	Size           int                   `json:"size"`
+	Sort           []*database.SortField `json:"sort"`
+	TimeStampField string                `json:"timestampfield,omitempty"`
+	TimeWindow     *TimeWindow           `json:"timewindow,omitempty"`
+	AsOf           string                `json:"asOf,omitempty"`
+	HasMore        bool                  `json:"hasMore"`
+	Next           string                `json:"next,omitempty"`
+	Prev           string                `json:"prev,omitempty"`
+}
+
+// Encode encodes the cursor into an opaque url safe token
+func (c *Cursor) Encode() string {
+	cursorBytes, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorBytes)
+}
+
+// DecodeCursor decodes a token generated by Cursor.Encode
+func DecodeCursor(token string) (*Cursor, error) {
+	cursorBytes, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", InvalidCursorErr, err)
+	}
+	cursor := &Cursor{}
+	if err := json.Unmarshal(cursorBytes, cursor); err != nil {
+		return nil, fmt.Errorf("%w: %w", InvalidCursorErr, err)
+	}
+	if len(cursor.SearchAfter) == 0 {
+		return nil, fmt.Errorf("%w: missing position", InvalidCursorErr)
+	}
+	return cursor, nil
+}
+
+// searchPage searches a single page of hits using search_after. The sort gets a tiebreaker so every hit
+// has a unique position. base holds what all the cursors of the listing share, a point in time is opened
+// on the first page when pit is set. One hit more than the page size is fetched to know if there are more.
+func (svc *Service) searchPage(cctx context.CustomContext, body *database.Body, index string, tiebreaker string, cursor *Cursor, pit bool, base Cursor) ([]any, *Page, error) {
+	size := defaultPageSize
+	if body.Size != nil {
+		size = *body.Size
+	}
+	body.Sort = withTiebreaker(body.Sort, tiebreaker)
+	base.Sort = body.Sort
+	page := &Page{
+		From:           body.From,
+		Size:           size,
+		Sort:           body.Sort,
+		TimeStampField: base.TimeStampField,
+		TimeWindow:     base.TimeWindow,
+		AsOf:           base.AsOf,
+	}
+
+	reverse := false
+	if cursor != nil {
+		page.From = cursor.From
+		body.From = 0
+		body.SearchAfter = cursor.SearchAfter
+		base.PitId = cursor.PitId
+		if cursor.Reverse {
+			reverse = true
+			body.Sort = reverseSort(body.Sort)
+		}
+	} else if pit {
+		pitId, err := svc.repo.OpenPointInTime(cctx, index, pointInTimeKeepAlive)
+		if err != nil {
+			cctx.Logger().DEBUG("failed to open point in time", tag.NewErrorTag(err))
+			return nil, nil, err
+		}
+		base.PitId = pitId
+	}
+	if base.PitId != "" {
+		body.Pit = &database.PointInTime{Id: base.PitId, KeepAlive: pointInTimeKeepAlive}
+	}
+	body.Size = database.HitsSize(size + 1)
+	body.TrackTotalHits = true
+
+	result, err := svc.search(cctx, body, index)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return nil, nil, err
+	}
+	page.Total = result.Total
+	page.TotalRelation = result.TotalRelation
+
+	hits := result.Hits
+	more := len(hits) > size
+	if more {
+		hits = hits[:size]
+	}
+	if reverse {
+		slices.Reverse(hits)
+	}
+	if len(hits) == 0 {
+		return hits, page, nil
+	}
+
+	if (reverse && more) || (!reverse && page.From > 0) {
+		prev := base
+		prev.SearchAfter = sortValuesOf(hits[0])
+		prev.Reverse = true
+		prev.From = max(page.From-size, 0)
+		page.Prev = prev.Encode()
+	}
+	if (!reverse && more) || reverse {
+		next := base
+		next.SearchAfter = sortValuesOf(hits[len(hits)-1])
+		next.From = page.From + len(hits)
+		page.Next = next.Encode()
+		page.HasMore = true
+	}
+	return hits, page, nil
+}
+
+// withTiebreaker appends the tiebreaker to the sort, sorting on relevance first when there is no sort
+func withTiebreaker(sortFields []*database.SortField, tiebreaker string) []*database.SortField {
+	sorted := slices.Clone(sortFields)
+	if len(sorted) == 0 {
+		sorted = append(sorted, &database.SortField{keyScore: database.Desc})
+	}
+	for _, sortField := range sorted {
+		if _, ok := (*sortField)[tiebreaker]; ok {
+			return sorted
+		}
+	}
+	return append(sorted, &database.SortField{tiebreaker: database.Asc})
+}
+
+func reverseSort(sortFields []*database.SortField) []*database.SortField {
+	reversed := make([]*database.SortField, 0, len(sortFields))
+	for _, sortField := range sortFields {
+		flipped := database.SortField{}
+		for field, order := range *sortField {
+			flipped[field] = database.Desc
+			if strings.EqualFold(string(order), string(database.Desc)) {
+				flipped[field] = database.Asc
+			}
+		}
+		reversed = append(reversed, &flipped)
+	}
+	return reversed
+}
+
+func sortValuesOf(hit any) []any {
+	hitMap, ok := hit.(map[string]any)
+	if !ok {
+		return nil
+	}
+	values, _ := hitMap["sort"].([]any)
+	return values
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAllServices_Cursor(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc := &Service{repo: database.NewMemoryClient()}
+	for i := 1; i <= 5; i++ {
+		// every service shares the same timestamp, the tiebreaker decides the order
+		_, err := svc.CreateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: fmt.Sprintf("service-%d", i), Name: fmt.Sprintf("service-%d", i), Version: 1,
+			CreatedAt: "2024-08-01T10:00:00Z", UpdatedAt: "2024-08-01T10:00:00Z",
+		})
+		require.NoError(t, err)
+	}
+
+	names := func(svcCats []*ServiceCatalogue) []string {
+		names := []string{}
+		for _, svcCat := range svcCats {
+			names = append(names, svcCat.Name)
+		}
+		return names
+	}
+	list := func(cursor string) ([]*ServiceCatalogue, *Page) {
+		size := 2
+		listParams := &ListParameters{
+			Size:       &size,
+			TimeWindow: &TimeWindow{After: "2024-07-01T00:00:00Z", Before: "2024-09-01T00:00:00Z"},
+		}
+		if cursor != "" {
+			decoded, err := DecodeCursor(cursor)
+			require.NoError(t, err)
+			listParams.Cursor = decoded
+		}
+		listParams.AddDefaultsIfEmpty()
+		svcCats, page, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		return svcCats, page
+	}
+
+	svcCats, page := list("")
+	assert.Equal(t, []string{"service-1", "service-2"}, names(svcCats))
+	assert.Empty(t, page.Prev)
+	assert.Equal(t, 5, page.Total)
+	assert.Equal(t, database.TotalRelationEqual, page.TotalRelation)
+	assert.Equal(t, 0, page.From)
+	assert.Equal(t, 2, page.Size)
+	assert.True(t, page.HasMore)
+
+	svcCats, page = list(page.Next)
+	assert.Equal(t, []string{"service-3", "service-4"}, names(svcCats))
+	assert.Equal(t, 2, page.From)
+
+	last, lastPage := list(page.Next)
+	assert.Equal(t, []string{"service-5"}, names(last))
+	assert.Empty(t, lastPage.Next)
+	assert.Equal(t, 4, lastPage.From)
+	assert.Equal(t, 5, lastPage.Total)
+	assert.False(t, lastPage.HasMore)
+
+	svcCats, page = list(lastPage.Prev)
+	assert.Equal(t, []string{"service-3", "service-4"}, names(svcCats))
+	assert.Equal(t, 2, page.From)
+	svcCats, page = list(page.Prev)
+	assert.Equal(t, []string{"service-1", "service-2"}, names(svcCats))
+	assert.Empty(t, page.Prev)
+	assert.NotEmpty(t, page.Next)
+
+	_, err := DecodeCursor("not a cursor")
+	assert.ErrorIs(t, err, InvalidCursorErr)
+}
@@ -0,0 +1,432 @@
+package services
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	queryFieldOwner     = "owner"
+	queryFieldLifecycle = "lifecycle"
+	queryFieldLabels    = "labels."
+	maxQueryLength      = 1000
+	// maxOwnerTeams caps the teams an owner clause resolves to
+	maxOwnerTeams = 100
+)
+
+// InvalidQueryErr is returned when a query string can not be parsed, see QuerySyntaxError
+var InvalidQueryErr error = fmt.Errorf("INVALID_QUERY")
+
+// QuerySyntaxError tells what is wrong with a query string and where, Position is the 1-based position of the
+// character the error was found at
+type QuerySyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s at position %d", InvalidQueryErr, e.Message, e.Position)
+}
+
+func (e *QuerySyntaxError) Unwrap() error {
+	return InvalidQueryErr
+}
+
+// QueryOperator compares the field of a query clause with the value
+type QueryOperator string
+
+var (
+	QueryEquals         QueryOperator = ":"
+	QueryGreater        QueryOperator = ">"
+	QueryGreaterOrEqual QueryOperator = ">="
+	QueryLess           QueryOperator = "<"
+	QueryLessOrEqual    QueryOperator = "<="
+	// the longer operators first so `>=` is not taken for `>`
+	queryOperators = []QueryOperator{QueryGreaterOrEqual, QueryLessOrEqual, QueryEquals, QueryGreater, QueryLess}
+)
+
+type queryFieldKind int
+
+const (
+	queryKeyword queryFieldKind = iota
+	queryDate
+	queryNumber
+)
+
+// queryFields are the fields a query string can filter on besides `labels.<key>`, by their name in the query.
+// Only the dates and the version can be compared with `>`, `>=`, `<` and `<=`.
+var queryFields = map[string]struct {
+	key  string
+	kind queryFieldKind
+}{
+	queryFieldOwner:     {key: keyOwnerTeam},
+	queryFieldLifecycle: {key: keyLifecycle},
+	"name":              {key: "name.keyword"},
+	"createdBy":         {key: keyCreatedBy},
+	"updatedBy":         {key: "updatedBy.keyword"},
+	"createdAt":         {key: keyCreatedAt, kind: queryDate},
+	"updatedAt":         {key: keyUpdatedAt, kind: queryDate},
+	"version":           {key: keyVersion, kind: queryNumber},
+}
+
+// ParseQuery parses a query string like `owner:payments lifecycle:production "card vault" -deprecated updatedAt>2026-01-01`.
+// Clauses are separated by white space and a leading `-` negates a clause. The phrases and the fields all have to
+// match while the words match together like `search`. A clause is
+//   - a free text word, fuzzy matched on the name and the description
+//   - a `"quoted phrase"`, matched word for word on the name or the description
+//   - `field:value` on one of queryFields or `labels.<key>`, the value can be quoted. The owner is a team name or
+//     id, see queryOwners.
+//   - `field>value`, `field>=value`, `field<value` or `field<=value` on the dates and the version. Dates are RFC3339
+//     timestamps or days like 2026-01-01, which stand for the whole day in UTC. A listing filtered on a date is
+//     not held to the default time window, see ListParameters.defaultsTimeWindow.
+//
+// Errors are a *QuerySyntaxError wrapping InvalidQueryErr. The query is compiled by queryStringQuery.
+func ParseQuery(q string) (*Query, error) {
+	parser := &queryParser{runes: []rune(q)}
+	if len(parser.runes) > maxQueryLength {
+		return nil, parser.errorf(maxQueryLength, "the query is longer than %d characters", maxQueryLength)
+	}
+
+	query := &Query{Clauses: []*QueryClause{}}
+	for {
+		parser.skipSpaces()
+		if parser.done() {
+			return query, nil
+		}
+		clause, err := parser.parseClause()
+		if err != nil {
+			return nil, err
+		}
+		query.Clauses = append(query.Clauses, clause)
+	}
+}
+
+// queryParser reads a query string rune by rune, pos is the index of the next rune
+type queryParser struct {
+	runes []rune
+	pos   int
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.runes)
+}
+
+func (p *queryParser) peek() rune {
+	return p.runes[p.pos]
+}
+
+// atSeparator tells whether the clause ends at the current position
+func (p *queryParser) atSeparator() bool {
+	return p.done() || unicode.IsSpace(p.peek())
+}
+
+func (p *queryParser) skipSpaces() {
+	for !p.done() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+// errorf is a QuerySyntaxError at the index of a rune
+func (p *queryParser) errorf(pos int, format string, args ...any) error {
+	return &QuerySyntaxError{Position: pos + 1, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) parseClause() (*QueryClause, error) {
+	clause := &QueryClause{}
+	if p.peek() == '-' {
+		clause.Negated = true
+		p.pos++
+		if p.atSeparator() {
+			return nil, p.errorf(p.pos-1, "expected a word, a phrase or a field after -")
+		}
+	}
+	if p.peek() == '"' {
+		phrase, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		clause.Value, clause.Phrase = phrase, true
+		return clause, nil
+	}
+
+	start := p.pos
+	for !p.atSeparator() && p.peek() != '"' && !isQueryOperator(p.peek()) {
+		p.pos++
+	}
+	word := string(p.runes[start:p.pos])
+	if p.atSeparator() {
+		clause.Value = word
+		return clause, nil
+	}
+	if p.peek() == '"' {
+		return nil, p.errorf(p.pos, "unexpected quote, separate phrases from words with white space")
+	}
+
+	operatorAt := p.pos
+	operator := p.parseOperator()
+	if word == "" {
+		return nil, p.errorf(operatorAt, "missing field before %s", operator)
+	}
+	valueAt := p.pos
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, p.errorf(valueAt, "missing value for %s", word)
+	}
+	if err := p.checkField(word, start, operator, operatorAt, value, valueAt); err != nil {
+		return nil, err
+	}
+	clause.Field, clause.Operator, clause.Value = word, operator, value
+	return clause, nil
+}
+
+func (p *queryParser) parseOperator() QueryOperator {
+	rest := string(p.runes[p.pos:min(p.pos+2, len(p.runes))])
+	for _, operator := range queryOperators {
+		if strings.HasPrefix(rest, string(operator)) {
+			p.pos += len(operator)
+			return operator
+		}
+	}
+	return ""
+}
+
+// parseValue reads the value of a field up to the white space, or a quoted value
+func (p *queryParser) parseValue() (string, error) {
+	if !p.done() && p.peek() == '"' {
+		return p.parseQuoted()
+	}
+	start := p.pos
+	for !p.atSeparator() {
+		if p.peek() == '"' {
+			return "", p.errorf(p.pos, "unexpected quote, quote the whole value")
+		}
+		p.pos++
+	}
+	return string(p.runes[start:p.pos]), nil
+}
+
+// parseQuoted reads the text between the quote at the current position and the closing one
+func (p *queryParser) parseQuoted() (string, error) {
+	start := p.pos
+	end := slices.Index(p.runes[start+1:], '"')
+	if end < 0 {
+		return "", p.errorf(start, "unterminated quote")
+	}
+	quoted := string(p.runes[start+1 : start+1+end])
+	p.pos = start + end + 2
+	if strings.TrimSpace(quoted) == "" {
+		return "", p.errorf(start, "empty quotes")
+	}
+	if !p.atSeparator() {
+		return "", p.errorf(p.pos, "expected white space after the closing quote")
+	}
+	return quoted, nil
+}
+
+// checkField checks the field is known, can be compared with the operator and the value fits it
+func (p *queryParser) checkField(field string, fieldAt int, operator QueryOperator, operatorAt int, value string, valueAt int) error {
+	if key, ok := strings.CutPrefix(field, queryFieldLabels); ok {
+		if !labelKeyRegex.MatchString(key) {
+			return p.errorf(fieldAt, "invalid label key %q", key)
+		}
+		if operator != QueryEquals {
+			return p.errorf(operatorAt, "labels can not be compared with %s", operator)
+		}
+		if !labelValueRegex.MatchString(value) {
+			return p.errorf(valueAt, "invalid value %q for label %q", value, key)
+		}
+		return nil
+	}
+
+	queryField, ok := queryFields[field]
+	if !ok {
+		return p.errorf(fieldAt, "unknown field %q", field)
+	}
+	if queryField.kind == queryKeyword && operator != QueryEquals {
+		return p.errorf(operatorAt, "%s can not be compared with %s, only the dates and the version can", field, operator)
+	}
+	switch {
+	case field == queryFieldLifecycle && !slices.Contains(lifecycles, any(value)):
+		return p.errorf(valueAt, "unknown lifecycle %q", value)
+	case queryField.kind == queryDate:
+		if _, _, err := parseQueryDate(value); err != nil {
+			return p.errorf(valueAt, "invalid date %q for %s, expected a day like 2006-01-02 or an RFC3339 timestamp", value, field)
+		}
+	case queryField.kind == queryNumber:
+		if _, err := strconv.Atoi(value); err != nil {
+			return p.errorf(valueAt, "invalid number %q for %s", value, field)
+		}
+	}
+	return nil
+}
+
+func isQueryOperator(r rune) bool {
+	return r == ':' || r == '>' || r == '<'
+}
+
+// parseQueryDate parses a day or an RFC3339 timestamp to UTC timestamps, dayEnd is the start of the next day
+// for a day and empty for a timestamp
+func parseQueryDate(value string) (start string, dayEnd string, err error) {
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		return day.Format(time.RFC3339), day.AddDate(0, 0, 1).Format(time.RFC3339), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", "", err
+	}
+	return t.UTC().Format(time.RFC3339), "", nil
+}
+
+// queryOwners resolves the values of the owner clauses of the query to the ids of the owner teams. A value is taken
+// as a team id as well as a team name, names are not unique and resolve to every team of that name.
+func (svc *Service) queryOwners(cctx context.CustomContext, query *Query) (map[string][]string, error) {
+	owners := map[string][]string{}
+	if query == nil {
+		return owners, nil
+	}
+	for _, clause := range query.Clauses {
+		if _, ok := owners[clause.Value]; ok || clause.Field != queryFieldOwner {
+			continue
+		}
+		result, err := svc.search(cctx, &database.Body{
+			Query: &database.Query{Term: &database.TermQuery{keyName: {Value: clause.Value}}},
+			Size:  database.HitsSize(maxOwnerTeams),
+		}, database.TeamIndex)
+		if err != nil {
+			cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+			return nil, err
+		}
+		owners[clause.Value] = []string{clause.Value}
+		for _, team := range decodeTeamHits(cctx, result.Hits) {
+			if team.TeamId != clause.Value {
+				owners[clause.Value] = append(owners[clause.Value], team.TeamId)
+			}
+		}
+	}
+	return owners, nil
+}
+
+// queryStringQuery compiles a query parsed by ParseQuery to bool clauses. The free text words are fuzzy matched
+// together like search and score the services along with the phrases, the fields filter the services without
+// scoring them and the negated clauses leave services out. The owners are the team ids of the owner clauses,
+// see queryOwners.
+func queryStringQuery(query *Query, owners map[string][]string) (must []database.Query, filter []database.Query, mustNot []database.Query) {
+	if query == nil {
+		return nil, nil, nil
+	}
+	words := []string{}
+	for _, clause := range query.Clauses {
+		switch {
+		case clause.Field == "" && !clause.Phrase && !clause.Negated:
+			words = append(words, clause.Value)
+		case clause.Field == "":
+			text := database.Query{
+				MultiMatch: &database.MultiMatch{
+					Fields: []string{database.NameField, database.DescriptionField},
+					Query:  clause.Value,
+				},
+			}
+			if clause.Phrase {
+				text.MultiMatch.Type = database.MultiMatchPhrase
+			}
+			if clause.Negated {
+				mustNot = append(mustNot, text)
+			} else {
+				must = append(must, text)
+			}
+		case clause.Negated:
+			mustNot = append(mustNot, fieldQuery(clause, owners))
+		default:
+			filter = append(filter, fieldQuery(clause, owners))
+		}
+	}
+	if len(words) > 0 {
+		must = append(must, *searchQuery(strings.Join(words, " ")))
+	}
+	return must, filter, mustNot
+}
+
+// fieldQuery matches a clause on a field, the clause has been checked by ParseQuery
+func fieldQuery(clause *QueryClause, owners map[string][]string) database.Query {
+	if key, ok := strings.CutPrefix(clause.Field, queryFieldLabels); ok {
+		return database.Query{Term: &database.TermQuery{keyLabels + "." + key: {Value: clause.Value}}}
+	}
+	if clause.Field == queryFieldLifecycle {
+		lifecycle, _ := lifecycleQuery([]string{clause.Value})
+		return lifecycle[0]
+	}
+	if clause.Field == queryFieldOwner {
+		teamIds := []any{clause.Value}
+		if resolved, ok := owners[clause.Value]; ok {
+			teamIds = make([]any, 0, len(resolved))
+			for _, teamId := range resolved {
+				teamIds = append(teamIds, teamId)
+			}
+		}
+		return database.Query{Terms: &database.TermsQuery{keyOwnerTeam: teamIds}}
+	}
+
+	queryField := queryFields[clause.Field]
+	switch queryField.kind {
+	case queryDate:
+		start, dayEnd, _ := parseQueryDate(clause.Value)
+		return comparisonQuery(queryField.key, clause.Operator, start, dayEnd)
+	case queryNumber:
+		number, _ := strconv.Atoi(clause.Value)
+		return comparisonQuery(queryField.key, clause.Operator, number, "")
+	}
+	return database.Query{Term: &database.TermQuery{queryField.key: {Value: clause.Value}}}
+}
+
+// comparisonQuery compares the field with the value. A day, dayEnd being the start of the next one, is compared as
+// the whole of it, `>2026-01-01` being from the next day on and `:2026-01-01` all of the day.
+func comparisonQuery(key string, operator QueryOperator, value any, dayEnd string) database.Query {
+	switch {
+	case operator == QueryEquals && dayEnd != "":
+		return database.Query{Range: &database.RangeQuery{key: {Gte: value, Lt: dayEnd}}}
+	case operator == QueryEquals:
+		return database.Query{Range: &database.RangeQuery{key: {Gte: value, Lte: value}}}
+	case operator == QueryGreater && dayEnd != "":
+		return database.Query{Range: &database.RangeQuery{key: {Gte: dayEnd}}}
+	case operator == QueryGreater:
+		return database.Query{Range: &database.RangeQuery{key: {Gt: value}}}
+	case operator == QueryGreaterOrEqual:
+		return database.Query{Range: &database.RangeQuery{key: {Gte: value}}}
+	case operator == QueryLess:
+		return database.Query{Range: &database.RangeQuery{key: {Lt: value}}}
+	case operator == QueryLessOrEqual && dayEnd != "":
+		return database.Query{Range: &database.RangeQuery{key: {Lt: dayEnd}}}
+	}
+	return database.Query{Range: &database.RangeQuery{key: {Lte: value}}}
+}
+
+// selectsLifecycle tells whether the query asks for services in a lifecycle, retired services are then not left out
+func (query *Query) selectsLifecycle() bool {
+	return query != nil && slices.ContainsFunc(query.Clauses, func(clause *QueryClause) bool {
+		return clause.Field == queryFieldLifecycle && !clause.Negated
+	})
+}
+
+// filtersDates tells whether the query filters on when the services were created or updated
+func (query *Query) filtersDates() bool {
+	return query != nil && slices.ContainsFunc(query.Clauses, func(clause *QueryClause) bool {
+		return queryFields[clause.Field].kind == queryDate
+	})
+}
+
+// fullText tells whether the query has words or phrases scoring the services
+func (query *Query) fullText() bool {
+	return query != nil && slices.ContainsFunc(query.Clauses, func(clause *QueryClause) bool {
+		return clause.Field == "" && !clause.Negated
+	})
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	query, err := ParseQuery(`owner:payments lifecycle:production "card vault" -deprecated updatedAt>2026-01-01`)
+	require.NoError(t, err)
+	assert.Equal(t, []*QueryClause{
+		{Field: "owner", Operator: QueryEquals, Value: "payments"},
+		{Field: "lifecycle", Operator: QueryEquals, Value: "production"},
+		{Value: "card vault", Phrase: true},
+		{Value: "deprecated", Negated: true},
+		{Field: "updatedAt", Operator: QueryGreater, Value: "2026-01-01"},
+	}, query.Clauses)
+
+	query, err = ParseQuery(` -labels.tier:1  createdBy:"alice"	version<=3 updatedAt>=2026-01-01T10:00:00+02:00 -"legacy api"`)
+	require.NoError(t, err)
+	assert.Equal(t, []*QueryClause{
+		{Field: "labels.tier", Operator: QueryEquals, Value: "1", Negated: true},
+		{Field: "createdBy", Operator: QueryEquals, Value: "alice"},
+		{Field: "version", Operator: QueryLessOrEqual, Value: "3"},
+		{Field: "updatedAt", Operator: QueryGreaterOrEqual, Value: "2026-01-01T10:00:00+02:00"},
+		{Value: "legacy api", Phrase: true, Negated: true},
+	}, query.Clauses)
+
+	query, err = ParseQuery("  ")
+	require.NoError(t, err)
+	assert.Empty(t, query.Clauses)
+}
+
+func TestParseQuery_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		q        string
+		position int
+		message  string
+	}{
+		{q: `owner:payments "card vault`, position: 16, message: "unterminated quote"},
+		{q: `team:payments`, position: 1, message: `unknown field "team"`},
+		{q: `owner: payments`, position: 7, message: "missing value for owner"},
+		{q: `:payments`, position: 1, message: "missing field before :"},
+		{q: `owner>payments`, position: 6, message: "owner can not be compared with >"},
+		{q: `updatedAt>2026-13-01`, position: 11, message: `invalid date "2026-13-01"`},
+		{q: `version>=two`, position: 10, message: `invalid number "two"`},
+		{q: `lifecycle:live`, position: 11, message: `unknown lifecycle "live"`},
+		{q: `labels.Tier:1`, position: 1, message: `invalid label key "Tier"`},
+		{q: `labels.tier<1`, position: 12, message: "labels can not be compared with <"},
+		{q: `card - vault`, position: 6, message: "expected a word, a phrase or a field after -"},
+		{q: `"card"vault`, position: 7, message: "expected white space after the closing quote"},
+		{q: `card"vault"`, position: 5, message: "unexpected quote"},
+		{q: `"  "`, position: 1, message: "empty quotes"},
+		{q: `zürich team:x`, position: 8, message: `unknown field "team"`},
+		{q: strings.Repeat("a", maxQueryLength+1), position: maxQueryLength + 1, message: "longer than"},
+	} {
+		_, err := ParseQuery(tc.q)
+		require.Error(t, err, tc.q)
+		assert.ErrorIs(t, err, InvalidQueryErr, tc.q)
+		syntaxErr := &QuerySyntaxError{}
+		require.True(t, errors.As(err, &syntaxErr), tc.q)
+		assert.Equal(t, tc.position, syntaxErr.Position, tc.q)
+		assert.Contains(t, syntaxErr.Message, tc.message, tc.q)
+	}
+}
+
+func TestQueryStringQuery(t *testing.T) {
+	query, err := ParseQuery(`updatedAt:2026-01-01 createdAt>2026-01-01 createdAt<=2026-01-01T10:00:00Z version>2 -lifecycle:production`)
+	require.NoError(t, err)
+	must, filter, mustNot := queryStringQuery(query, nil)
+	assert.Empty(t, must)
+	assert.Equal(t, []database.Query{
+		{Range: &database.RangeQuery{keyUpdatedAt: {Gte: "2026-01-01T00:00:00Z", Lt: "2026-01-02T00:00:00Z"}}},
+		{Range: &database.RangeQuery{keyCreatedAt: {Gte: "2026-01-02T00:00:00Z"}}},
+		{Range: &database.RangeQuery{keyCreatedAt: {Lte: "2026-01-01T10:00:00Z"}}},
+		{Range: &database.RangeQuery{keyVersion: {Gt: 2}}},
+	}, filter, "a day is compared as the whole of it")
+	lifecycle, _ := lifecycleQuery([]string{LifecycleProduction})
+	assert.Equal(t, lifecycle, mustNot)
+}
+
+func TestListAllServices_Query(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, _ := newTestService(t)
+
+	team, err := svc.CreateTeam(cctx, (&CreateTeamRequest{Name: "payments", CreatedBy: "alice"}).RequestStructToTeamStruct(cctx))
+	require.NoError(t, err)
+	for _, req := range []*CreateServiceCatalogueRequest{
+		{Name: "card vault", Description: "stores card numbers", Ownership: Ownership{OwnerTeam: team.TeamId}, Lifecycle: LifecycleProduction, CreatedBy: "alice"},
+		{Name: "vault", Description: "stores card secrets of the deprecated checkout", Ownership: Ownership{OwnerTeam: team.TeamId}, Lifecycle: LifecycleProduction, CreatedBy: "alice"},
+		{Name: "payouts", Description: "pays out card payments", Ownership: Ownership{OwnerTeam: team.TeamId}, Labels: map[string]string{"tier": "1"}, Lifecycle: LifecycleExperimental, CreatedBy: "bob"},
+		{Name: "ledger", Description: "double entry ledger", Lifecycle: LifecycleRetired, CreatedBy: "bob"},
+	} {
+		_, err := svc.CreateServiceCatalogue(cctx, req.RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+	}
+
+	list := func(q string) []string {
+		query, err := ParseQuery(q)
+		require.NoError(t, err)
+		listParams := &ListParameters{Query: query, Sort: []*database.SortField{{"name.keyword": database.Asc}}}
+		listParams.AddDefaultsIfEmpty()
+		require.NoError(t, listParams.Validate())
+		svcCats, _, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		names := []string{}
+		for _, svcCat := range svcCats {
+			names = append(names, svcCat.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, []string{"card vault"}, list(`owner:payments lifecycle:production "card vault" -deprecated`), "owners are found by team name")
+	assert.Equal(t, []string{"card vault", "payouts", "vault"}, list(`owner:`+team.TeamId))
+	assert.Equal(t, []string{"payments"}, list(`-owner:payments`))
+	assert.Empty(t, list(`owner:unknown`))
+	assert.Equal(t, []string{"card vault", "vault"}, list(`owner:`+team.TeamId+` lifecycle:production vault`))
+	assert.Equal(t, []string{"payouts"}, list(`labels.tier:1 createdBy:bob`))
+	assert.Equal(t, []string{"card vault", "payments", "vault"}, list(`-createdBy:bob`))
+	assert.Equal(t, []string{"ledger"}, list(`lifecycle:retired`), "retired services are listed when asked for")
+	assert.Equal(t, []string{"payments", "payouts"}, list(`card -lifecycle:production`))
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	assert.Equal(t, []string{"card vault", "payments", "payouts", "vault"}, list(`updatedAt:`+today))
+	assert.Empty(t, list(`updatedAt>`+today), "a day is compared as the whole of it")
+
+	// the dates are not held to the default time window of the last 30 days
+	ledger := &ServiceCatalogue{ServiceId: "ledger-2025", Name: "old ledger", Description: "double entry ledger of 2025", Lifecycle: LifecycleProduction,
+		Version: 1, CreatedAt: "2025-11-02T10:00:00Z", UpdatedAt: "2025-12-01T10:00:00Z", CreatedBy: "bob", UpdatedBy: "bob"}
+	ledgerBytes, err := json.Marshal(ledger)
+	require.NoError(t, err)
+	require.NoError(t, svc.repo.IndexDocument(cctx, ledgerBytes, database.ServiceCatalogueIndex, ledger.ServiceId))
+	assert.Equal(t, []string{"old ledger"}, list(`updatedAt<2026-01-01`))
+	assert.Equal(t, []string{"card vault", "old ledger", "payments", "payouts", "vault"}, list(`updatedAt>2025-11-01`))
+	assert.Equal(t, []string{"old ledger"}, list(`createdAt:2025-11-02`))
+	assert.NotContains(t, list(`lifecycle:production`), "old ledger", "without a date the default time window applies")
+
+	query, err := ParseQuery(`card vault`)
+	require.NoError(t, err)
+	listParams := &ListParameters{Query: query}
+	listParams.AddDefaultsIfEmpty()
+	assert.Equal(t, []*database.SortField{{keyScore: database.Desc}}, listParams.Sort, "words are sorted on relevance")
+}
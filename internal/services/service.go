@@ -8,16 +8,19 @@ import (
 )
 
 type Service struct {
-	esClient database.ESClient
+	repo       database.Repository
+	reconciler config.Reconciler
 }
 
+// NewService sets up the storage backend selected in the config and returns the service
 func NewService(ctx context.Context, cfg *config.Configuration) (*Service, error) {
-	esClient, err := database.InitESClient(cfg.ElasticSearch)
+	repo, err := database.NewRepository(cfg.Database)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup database: %w", err)
 	}
 
 	return &Service{
-		esClient: *esClient,
+		repo:       repo,
+		reconciler: cfg.Reconciler,
 	}, nil
 }
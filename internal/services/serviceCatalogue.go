@@ -2,10 +2,13 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"nikki-noceps/serviceCatalogue/pkg/context"
 	"nikki-noceps/serviceCatalogue/pkg/database"
 	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -13,26 +16,155 @@ import (
 var keyServiceId = "serviceId.keyword"
 var keyParentId = "parentId.keyword"
 var keyVersionId = "versionId.keyword"
+var keyVersion = "version"
+var keyDeleted = "deleted"
+var keyDecomissionedAt = "decomissionedAt"
+var keyOwnerTeam = "ownerTeam.keyword"
+
+// SortRelevance sorts the services on how well they match the search of a listing, the best match first
+// when descending
+var SortRelevance = "relevance"
 var NoDocumentFoundErr error = fmt.Errorf("DOCUMENT_NOT_FOUND")
 
-// ListAllServicees queries the elasticsearch for hits and returns back serviceCatalogue objects
+// VersionMismatchErr is returned when the expected version does not match the live version
+var VersionMismatchErr error = fmt.Errorf("VERSION_MISMATCH")
+
+// ServiceNotDeletedErr is returned when restoring a service which is still live
+var ServiceNotDeletedErr error = fmt.Errorf("SERVICE_NOT_DELETED")
+
+// ConcurrentModificationErr is returned when the live document changed while it was being modified
+var ConcurrentModificationErr error = fmt.Errorf("CONCURRENT_MODIFICATION")
+
+// ListAllServicees queries the elasticsearch for hits and returns back serviceCatalogue objects along with
+// the cursors to the pages around it, see searchPage.
+// With AsOf set the services are listed as they were at that point in time, see snapshotAt.
+// Owner, LabelSelector and Query narrow down the services listed, see listQuery. The words matching a search or the
+// words of the query are highlighted like FuzzySearchService.
 // Returns an error incase of any issues
-func (svc *Service) ListAllServices(cctx context.CustomContext, listParams *ListParameters) ([]*ServiceCatalogue, error) {
+func (svc *Service) ListAllServices(cctx context.CustomContext, listParams *ListParameters) ([]*ServiceCatalogue, *Page, error) {
+	attributes, err := svc.attributesQuery(cctx, listParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	owners, err := svc.queryOwners(cctx, listParams.Query)
+	if err != nil {
+		return nil, nil, err
+	}
 	body := &database.Body{
-		Query: &database.Query{
+		Query: listQuery(listParams, attributes, owners),
+		Sort:  listParams.Sort,
+		From:  *listParams.From,
+		Size:  listParams.Size,
+	}
+	if listParams.Search != "" || listParams.Query.fullText() {
+		body.Highlight = searchHighlight(listParams.PreTag, listParams.PostTag)
+	}
+
+	target := svc
+	if listParams.AsOf != "" {
+		snapshot, err := svc.snapshotAt(cctx, utcTimestamp(listParams.AsOf))
+		if err != nil {
+			return nil, nil, err
+		}
+		target = snapshot
+	}
+
+	base := Cursor{
+		TimeStampField: listParams.TimeStampField,
+		TimeWindow:     listParams.TimeWindow,
+		AsOf:           listParams.AsOf,
+	}
+	hits, page, err := target.searchPage(cctx, body, database.ServiceCatalogueIndex, keyServiceId, listParams.Cursor, listParams.Pit, base)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeServiceCatalogueHits(cctx, hits), page, nil
+}
+
+// ListFacets counts the services matching the filters of the listing, see Facets
+func (svc *Service) ListFacets(cctx context.CustomContext, listParams *ListParameters) (*Facets, error) {
+	target := svc
+	if listParams.AsOf != "" {
+		snapshot, err := svc.snapshotAt(cctx, utcTimestamp(listParams.AsOf))
+		if err != nil {
+			return nil, err
+		}
+		target = snapshot
+	}
+
+	attributes, err := svc.attributesQuery(cctx, listParams)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := svc.queryOwners(cctx, listParams.Query)
+	if err != nil {
+		return nil, err
+	}
+	return target.facets(cctx, listQuery(listParams, attributes, owners))
+}
+
+// listQuery fuzzy matches the search, which scores the services, and filters them on the time window, the owner team,
+// the labels, the lifecycle and the attributes, see attributesQuery. The filters do not change the relevance.
+// The clauses of the query string are added alongside with the owners resolved, see queryStringQuery.
+func listQuery(listParams *ListParameters, attributes []database.Query, owners map[string][]string) *database.Query {
+	filter := []database.Query{}
+	if listParams.TimeWindow != nil {
+		filter = append(filter, database.Query{
 			Range: &database.RangeQuery{
 				listParams.TimeStampField: {
 					Gte: listParams.TimeWindow.After,
 					Lte: listParams.TimeWindow.Before,
 				},
 			},
+		})
+	}
+	if listParams.Owner != "" {
+		filter = append(filter, database.Query{Term: &database.TermQuery{keyOwnerTeam: {Value: listParams.Owner}}})
+	}
+	labelsFilter, mustNot := labelSelectorQuery(listParams.LabelSelector)
+	filter = append(filter, labelsFilter...)
+	lifecycleFilter, lifecycleMustNot := lifecycleQuery(listParams.Lifecycle)
+	filter = append(filter, lifecycleFilter...)
+	if !listParams.Query.selectsLifecycle() {
+		mustNot = append(mustNot, lifecycleMustNot...)
+	}
+	filter = append(filter, attributes...)
+	filter = append(filter, linksQuery(listParams.LinkHost, listParams.LinkKind)...)
+
+	must := []database.Query{}
+	if listParams.Search != "" {
+		must = append(must, *searchQuery(listParams.Search))
+	}
+	queryMust, queryFilter, queryMustNot := queryStringQuery(listParams.Query, owners)
+	must = append(must, queryMust...)
+	filter = append(filter, queryFilter...)
+	mustNot = append(mustNot, queryMustNot...)
+	return &database.Query{
+		Bool: &database.BoolQuery{
+			Must:    must,
+			Filter:  filter,
+			MustNot: mustNot,
 		},
-		Sort: listParams.Sort,
-		From: *listParams.From,
-		Size: *listParams.Size,
 	}
+}
 
-	return svc.searchAndFetchServiceCatalogueList(cctx, body)
+// relevanceSort replaces SortRelevance in the sort with the relevance score of the hits
+func relevanceSort(sortFields []*database.SortField) []*database.SortField {
+	sorted := make([]*database.SortField, 0, len(sortFields))
+	for _, sortField := range sortFields {
+		if sortField == nil {
+			continue
+		}
+		replaced := database.SortField{}
+		for field, order := range *sortField {
+			if field == SortRelevance {
+				field = keyScore
+			}
+			replaced[field] = order
+		}
+		sorted = append(sorted, &replaced)
+	}
+	return sorted
 }
 
 func (svc *Service) FetchServiceById(cctx context.CustomContext, serviceId string) (*ServiceCatalogue, error) {
@@ -53,7 +185,10 @@ func (svc *Service) FetchServiceById(cctx context.CustomContext, serviceId strin
 	return svc.mapToServiceCatalogue(cctx, hitMap)
 }
 
-func (svc *Service) DeleteService(cctx context.CustomContext, serviceId string, userId string) error {
+// DeleteService archives the live version of the service and deletes the live document.
+// Both the steps are applied as a single change, see applyChange. The live version must be one of the
+// expectedVersions, when any are given. The delete fails if the service changed while being deleted.
+func (svc *Service) DeleteService(cctx context.CustomContext, serviceId string, userId string, expectedVersions []int) error {
 	body := &database.Body{
 		Query: &database.Query{
 			Term: &database.TermQuery{
@@ -62,6 +197,7 @@ func (svc *Service) DeleteService(cctx context.CustomContext, serviceId string,
 				},
 			},
 		},
+		SeqNoPrimaryTerm: true,
 	}
 
 	hitMap, err := svc.searchAndFetchServiceCatalogue(cctx, body)
@@ -73,60 +209,136 @@ func (svc *Service) DeleteService(cctx context.CustomContext, serviceId string,
 	if err != nil {
 		return err
 	}
+	if !versionExpected(expectedVersions, svcCat.Version) {
+		return VersionMismatchErr
+	}
+	revision, err := database.RevisionFromHit(hitMap)
+	if err != nil {
+		return fmt.Errorf("failed to read document revision: %w", err)
+	}
 
+	now := time.Now().UTC().Format(time.RFC3339)
 	svcCatVersion := &ServiceCatalogueVersion{
 		ParentId:        svcCat.ServiceId,
 		VersionId:       uuid.NewString(),
 		Name:            svcCat.Name,
 		Description:     svcCat.Description,
+		Ownership:       svcCat.Ownership,
+		Labels:          svcCat.Labels,
+		Attributes:      svcCat.Attributes,
+		DependsOn:       svcCat.DependsOn,
+		Links:           svcCat.Links,
+		Lifecycle:       svcCat.Lifecycle,
+		Deprecation:     svcCat.Deprecation,
 		Version:         svcCat.Version,
 		CreatedAt:       svcCat.UpdatedAt,
-		CreatedBy:       svcCat.CreatedBy,
+		CreatedBy:       svcCat.UpdatedBy,
+		DecomissionedAt: now,
 		DecomissionedBy: userId,
+		Deleted:         true,
+	}
+
+	change := &ServiceCatalogueChange{
+		ChangeId:   uuid.NewString(),
+		Operation:  changeOperationDelete,
+		ServiceId:  svcCat.ServiceId,
+		DocumentId: hitMap["_id"].(string),
+		Version:    svcCat.Version,
+		Archive:    svcCatVersion,
+		CreatedAt:  now,
+		Revision:   revision,
 	}
+	return concurrencyError(svc.applyChange(cctx, change), expectedVersions)
+}
 
-	_, err = svc.CreateServiceCatalogueVersion(cctx, svcCatVersion)
+// FuzzySearchService searches name and description sorted on relevance, pages through search_after like ListAllServices.
+// The words of the name and the description matching the search are highlighted, see searchHighlight.
+func (svc *Service) FuzzySearchService(cctx context.CustomContext, searchParams *SearchParameters) ([]*ServiceCatalogue, *Page, error) {
+	body := &database.Body{
+		Query:     searchQuery(searchParams.Search),
+		From:      *searchParams.From,
+		Size:      searchParams.Size,
+		Highlight: searchHighlight(searchParams.PreTag, searchParams.PostTag),
+	}
+
+	hits, page, err := svc.searchPage(cctx, body, database.ServiceCatalogueIndex, keyServiceId, searchParams.Cursor, searchParams.Pit, Cursor{})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	return decodeServiceCatalogueHits(cctx, hits), page, nil
+}
 
-	err = svc.deleteServiceCatalogue(cctx, hitMap["_id"].(string))
-	return err
+// SearchFacets counts the services matching the search, see Facets
+func (svc *Service) SearchFacets(cctx context.CustomContext, searchParams *SearchParameters) (*Facets, error) {
+	return svc.facets(cctx, searchQuery(searchParams.Search))
 }
 
-func (svc *Service) FuzzySearchService(cctx context.CustomContext, searchParams *SearchParameters) ([]*ServiceCatalogue, error) {
-	body := &database.Body{
-		Query: &database.Query{
-			MultiMatch: &database.MultiMatch{
-				Fields:    []string{database.NameField, database.DescriptionField},
-				Query:     searchParams.Search,
-				Fuzziness: "AUTO",
-			},
+// searchQuery fuzzy matches the search on the name and the description
+func searchQuery(search string) *database.Query {
+	return &database.Query{
+		MultiMatch: &database.MultiMatch{
+			Fields:    []string{database.NameField, database.DescriptionField},
+			Query:     search,
+			Fuzziness: "AUTO",
 		},
-		From: *searchParams.From,
-		Size: *searchParams.Size,
 	}
-
-	return svc.searchAndFetchServiceCatalogueList(cctx, body)
 }
 
+// CreateServiceCatalogue stores the service, the owner team and the services it depends on must exist and the
+// attributes must match the attributes schema. Dependency cycles the service closes are returned as warnings on the service.
 func (svc *Service) CreateServiceCatalogue(cctx context.CustomContext, input *ServiceCatalogue) (*ServiceCatalogue, error) {
+	if err := svc.validateOwnerTeam(cctx, input.Ownership); err != nil {
+		return nil, err
+	}
+	if err := svc.validateAttributes(cctx, input.Attributes); err != nil {
+		return nil, err
+	}
+	warnings, err := svc.validateDependencies(cctx, input.ServiceId, input.DependsOn)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.validateDeprecation(cctx, input.ServiceId, lifecycleOf(input), Deprecation{}, input.Deprecation); err != nil {
+		return nil, err
+	}
+	if input.Lifecycle == LifecycleDeprecated && input.DeprecatedAt == "" {
+		input.DeprecatedAt = input.CreatedAt
+	}
+	input.OrgId = orgOf(cctx)
+	input.Links = withLinkHosts(input.Links)
+	input.LabelPairs = labelPairs(input.Labels)
 	inputBytes, err := json.Marshal(input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse input: %w", err)
 	}
-	_, err = svc.esClient.CreateDocument(cctx, inputBytes, database.ServiceCatalogueIndex)
+	_, err = svc.repo.CreateDocument(cctx, inputBytes, database.ServiceCatalogueIndex)
 	if err != nil {
 		return nil, err
 	}
 
+	input.Warnings = warnings
 	return input, nil
 }
 
 // UpdateServiceCatalogue: updates fields in the service catalogue and creates new version in the service catalogue versions index
-// corresponding to the service being updated. Ideally we should use database transactions to ensure both the steps are collectively
-// atomic.
-func (svc *Service) UpdateServiceCatalogue(cctx context.CustomContext, input *ServiceCatalogue) (*ServiceCatalogue, error) {
+// corresponding to the service being updated. Both the steps are applied as a single change, see applyChange.
+// The live version must be one of the expectedVersions, when any are given. The update fails if the service changed while being updated
+// so concurrent updates can never overwrite each other. Dependency cycles are returned as warnings like on create.
+// A lifecycle change must be an allowed transition, it is recorded on the archived version. The returned service
+// is the document as stored by the update, see updatedServiceCatalogue. Attributes are validated when they are replaced.
+func (svc *Service) UpdateServiceCatalogue(cctx context.CustomContext, input *ServiceCatalogue, expectedVersions []int) (*ServiceCatalogue, error) {
+	if err := svc.validateOwnerTeam(cctx, input.Ownership); err != nil {
+		return nil, err
+	}
+	if input.Attributes != nil {
+		if err := svc.validateAttributes(cctx, input.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	warnings, err := svc.validateDependencies(cctx, input.ServiceId, input.DependsOn)
+	if err != nil {
+		return nil, err
+	}
+
 	body := &database.Body{
 		Query: &database.Query{
 			Term: &database.TermQuery{
@@ -135,6 +347,7 @@ func (svc *Service) UpdateServiceCatalogue(cctx context.CustomContext, input *Se
 				},
 			},
 		},
+		SeqNoPrimaryTerm: true,
 	}
 
 	hitMap, err := svc.searchAndFetchServiceCatalogue(cctx, body)
@@ -146,49 +359,231 @@ func (svc *Service) UpdateServiceCatalogue(cctx context.CustomContext, input *Se
 	if err != nil {
 		return nil, err
 	}
+	if !versionExpected(expectedVersions, svcCat.Version) {
+		return nil, VersionMismatchErr
+	}
+	transition, err := lifecycleTransition(svcCat, input.Lifecycle)
+	if err != nil {
+		return nil, err
+	}
+	lifecycle := lifecycleOf(svcCat)
+	if transition != nil {
+		lifecycle = transition.To
+	}
+	if err := svc.validateDeprecation(cctx, svcCat.ServiceId, lifecycle, svcCat.Deprecation, input.Deprecation); err != nil {
+		return nil, err
+	}
+	revision, err := database.RevisionFromHit(hitMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document revision: %w", err)
+	}
 
+	now := time.Now().UTC().Format(time.RFC3339)
 	// create version
 	svcVersion := &ServiceCatalogueVersion{
 		ParentId:        svcCat.ServiceId,
 		VersionId:       uuid.NewString(),
 		Name:            svcCat.Name,
 		Description:     svcCat.Description,
+		Ownership:       svcCat.Ownership,
+		Labels:          svcCat.Labels,
+		Attributes:      svcCat.Attributes,
+		DependsOn:       svcCat.DependsOn,
+		Links:           svcCat.Links,
+		Lifecycle:       svcCat.Lifecycle,
+		Deprecation:     svcCat.Deprecation,
 		Version:         svcCat.Version,
 		CreatedAt:       svcCat.UpdatedAt,
 		CreatedBy:       svcCat.UpdatedBy,
+		DecomissionedAt: now,
 		DecomissionedBy: input.UpdatedBy,
+		Transition:      transition,
+	}
+
+	// increment version
+	input.Version = svcCat.Version + 1
+	if input.UpdatedAt == "" {
+		input.UpdatedAt = now
 	}
 
-	svcVersionResp, err := svc.CreateServiceCatalogueVersion(cctx, svcVersion)
+	updateMap, err := structToMap(input)
+	if err != nil {
+		cctx.Logger().DEBUG("error struct to map", tag.NewErrorTag(err))
+		return nil, fmt.Errorf("failed to generate update doc: %w", err)
+	}
+	if input.Labels != nil {
+		updateMap[keyLabels] = labelsUpdate(svcCat.Labels, input.Labels)
+		updateMap[keyLabelPairs] = labelPairs(input.Labels)
+	}
+	if input.Attributes != nil {
+		updateMap[keyAttributes] = attributesUpdate(svcCat.Attributes, input.Attributes)
+	}
+	if input.DependsOn != nil {
+		updateMap[keyDependsOn] = input.DependsOn
+	}
+	if input.Links != nil {
+		updateMap[keyLinks] = withLinkHosts(input.Links)
+	}
+	deprecationUpdate(updateMap, transition, now)
+
+	change := &ServiceCatalogueChange{
+		ChangeId:   uuid.NewString(),
+		Operation:  changeOperationUpdate,
+		ServiceId:  svcCat.ServiceId,
+		DocumentId: hitMap["_id"].(string),
+		Version:    svcCat.Version,
+		Archive:    svcVersion,
+		Update:     updateMap,
+		UpdatedAt:  input.UpdatedAt,
+		CreatedAt:  now,
+		Revision:   revision,
+	}
+	if err := svc.applyChange(cctx, change); err != nil {
+		return nil, concurrencyError(err, expectedVersions)
+	}
+
+	updated, err := svc.updatedServiceCatalogue(cctx, hitMap, updateMap)
 	if err != nil {
 		return nil, err
 	}
+	updated.Warnings = warnings
+	return updated, nil
+}
 
-	cctx.Logger().DEBUG("version created", tag.NewAnyTag("svc_version", svcVersionResp))
+// ListDeletedServices lists the tombstones of deleted services, most recently deleted first
+func (svc *Service) ListDeletedServices(cctx context.CustomContext, listParams *ListDeletedParameters) ([]*ServiceCatalogueVersion, *Page, error) {
+	body := &database.Body{
+		Query: &database.Query{
+			Term: &database.TermQuery{
+				keyDeleted: {
+					Value: "true",
+				},
+			},
+		},
+		Sort: []*database.SortField{{keyDecomissionedAt: database.Desc}},
+		From: *listParams.From,
+		Size: listParams.Size,
+	}
 
-	// update document
-	id := hitMap["_id"].(string)
-	// increment version
-	svcCat.Version++
-	input.Version = svcCat.Version
+	hits, page, err := svc.searchPage(cctx, body, database.ServiceCatalogueVersionIndex, keyVersionId, listParams.Cursor, listParams.Pit, Cursor{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeServiceCatalogueVersionHits(cctx, hits), page, nil
+}
 
-	updateMap, err := structToMap(input)
+// RestoreDeletedService makes a deleted service live again with its original serviceId from the tombstone
+// archived on delete. The version history stays untouched and the service continues at the next version.
+// A non empty ownerTeam re-assigns the service, which is required when its owner team was deleted since.
+func (svc *Service) RestoreDeletedService(cctx context.CustomContext, serviceId string, userId string, ownerTeam string) (*ServiceCatalogue, error) {
+	_, err := svc.FetchServiceById(cctx, serviceId)
+	if err == nil {
+		return nil, ServiceNotDeletedErr
+	}
+	if !errors.Is(err, NoDocumentFoundErr) {
+		return nil, err
+	}
+
+	hitMap, err := svc.searchAndFetchServiceCatalogueVersion(cctx, &database.Body{
+		Query: &database.Query{
+			Bool: &database.BoolQuery{
+				Must: []database.Query{
+					{Term: &database.TermQuery{keyParentId: {Value: serviceId}}},
+					{Term: &database.TermQuery{keyDeleted: {Value: "true"}}},
+				},
+			},
+		},
+		Sort:             []*database.SortField{{keyVersion: database.Desc}},
+		Size:             database.HitsSize(1),
+		SeqNoPrimaryTerm: true,
+	})
 	if err != nil {
-		cctx.Logger().DEBUG("error struct to map", tag.NewErrorTag(err))
-		return nil, fmt.Errorf("failed to generate update doc: %w", err)
+		return nil, err
 	}
-	updateDoc := &database.UpdateBody{
-		Doc: updateMap,
+	tombstone, err := svc.mapToServiceCatalogueVersion(cctx, hitMap)
+	if err != nil {
+		return nil, err
 	}
-	updateBytes, err := json.Marshal(updateDoc)
+	ownership, err := svc.restoredOwnership(cctx, tombstone.Ownership, ownerTeam)
 	if err != nil {
-		cctx.Logger().DEBUG("failed to convert struct to map", tag.NewErrorTag(err))
-		return nil, fmt.Errorf("failed to parse input: %w", err)
+		return nil, err
 	}
+	revision, err := database.RevisionFromHit(hitMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document revision: %w", err)
+	}
+	tombstoneId := hitMap["_id"].(string)
 
-	_, err = svc.esClient.UpdateDocument(cctx, updateBytes, database.ServiceCatalogueIndex, id)
+	// the first version carries when and by whom the service was created
+	first, err := svc.fetchServiceCatalogueVersion(cctx, &database.Body{
+		Query: &database.Query{
+			Term: &database.TermQuery{
+				keyParentId: {
+					Value: serviceId,
+				},
+			},
+		},
+		Sort: []*database.SortField{{keyVersion: database.Asc}},
+		Size: database.HitsSize(1),
+	})
 	if err != nil {
 		return nil, err
 	}
+
+	// clearing the tombstone first makes concurrent restores of the same service conflict
+	if err := svc.markServiceCatalogueVersionDeleted(cctx, tombstoneId, false, revision); err != nil {
+		return nil, concurrencyError(err, nil)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	svcCat, err := svc.CreateServiceCatalogue(cctx, &ServiceCatalogue{
+		ServiceId:   serviceId,
+		Name:        tombstone.Name,
+		Description: tombstone.Description,
+		Ownership:   ownership,
+		Labels:      tombstone.Labels,
+		Attributes:  tombstone.Attributes,
+		DependsOn:   tombstone.DependsOn,
+		Links:       tombstone.Links,
+		Lifecycle:   tombstone.Lifecycle,
+		Deprecation: tombstone.Deprecation,
+		Version:     tombstone.Version + 1,
+		CreatedAt:   first.CreatedAt,
+		UpdatedAt:   now,
+		CreatedBy:   first.CreatedBy,
+		UpdatedBy:   userId,
+	})
+	if err != nil {
+		if err := svc.markServiceCatalogueVersionDeleted(cctx, tombstoneId, true, nil); err != nil {
+			cctx.Logger().ERROR("failed to rollback tombstone", tag.NewAnyTag("versionId", tombstone.VersionId), tag.NewErrorTag(err))
+		}
+		return nil, err
+	}
 	return svcCat, nil
 }
+
+func (svc *Service) markServiceCatalogueVersionDeleted(cctx context.CustomContext, docId string, deleted bool, revision *database.Revision) error {
+	updateBytes, err := json.Marshal(&database.UpdateBody{Doc: map[string]any{keyDeleted: deleted}})
+	if err != nil {
+		return fmt.Errorf("failed to parse input: %w", err)
+	}
+	return svc.repo.UpdateDocument(cctx, updateBytes, database.ServiceCatalogueVersionIndex, docId, revision)
+}
+
+// versionExpected tells if the live version is one of the versions the caller expects, any version is expected
+// when none are given
+func versionExpected(expectedVersions []int, version int) bool {
+	return len(expectedVersions) == 0 || slices.Contains(expectedVersions, version)
+}
+
+// concurrencyError translates a version conflict from the database. The caller asserting a version
+// gets VersionMismatchErr as the version it saw is no longer live.
+func concurrencyError(err error, expectedVersions []int) error {
+	if !errors.Is(err, database.VersionConflictErr) {
+		return err
+	}
+	if len(expectedVersions) != 0 {
+		return VersionMismatchErr
+	}
+	return ConcurrentModificationErr
+}
@@ -3,18 +3,32 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"nikki-noceps/serviceCatalogue/pkg/context"
 	"nikki-noceps/serviceCatalogue/pkg/database"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// maxServiceVersions caps the versions fetched for a single service
+var maxServiceVersions = 1000
+
+// CreateServiceCatalogueVersion archives a version using its versionId as the document id, so writing
+// the same version again does not create a duplicate.
 func (svc *Service) CreateServiceCatalogueVersion(cctx context.CustomContext, input *ServiceCatalogueVersion) (*ServiceCatalogueVersion, error) {
-	input.DecomissionedAt = time.Now().UTC().Format(time.RFC3339)
+	if input.VersionId == "" {
+		input.VersionId = uuid.NewString()
+	}
+	if input.DecomissionedAt == "" {
+		input.DecomissionedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	input.OrgId = orgOf(cctx)
 	inputBytes, err := json.Marshal(input)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse input: %w", err)
 	}
-	_, err = svc.esClient.CreateDocument(cctx, inputBytes, database.ServiceCatalogueVersionIndex)
+	err = svc.repo.IndexDocument(cctx, inputBytes, database.ServiceCatalogueVersionIndex, input.VersionId)
 	if err != nil {
 		return nil, err
 	}
@@ -22,6 +36,7 @@ func (svc *Service) CreateServiceCatalogueVersion(cctx context.CustomContext, in
 	return input, nil
 }
 
+// ListAllServiceVersions lists the archived versions of a service, oldest first
 func (svc *Service) ListAllServiceVersions(cctx context.CustomContext, parentId string) ([]*ServiceCatalogueVersion, error) {
 	body := &database.Body{
 		Query: &database.Query{
@@ -31,11 +46,35 @@ func (svc *Service) ListAllServiceVersions(cctx context.CustomContext, parentId
 				},
 			},
 		},
+		Sort: []*database.SortField{{keyVersion: database.Asc}},
+		Size: database.HitsSize(maxServiceVersions),
 	}
 
 	return svc.searchAndFetchServiceCatalogueVersions(cctx, body)
 }
 
+// ListServiceVersions lists a page of the archived versions of a service, oldest first
+func (svc *Service) ListServiceVersions(cctx context.CustomContext, parentId string, listParams *ListVersionsParameters) ([]*ServiceCatalogueVersion, *Page, error) {
+	body := &database.Body{
+		Query: &database.Query{
+			Term: &database.TermQuery{
+				keyParentId: {
+					Value: parentId,
+				},
+			},
+		},
+		Sort: []*database.SortField{{keyVersion: database.Asc}},
+		From: *listParams.From,
+		Size: listParams.Size,
+	}
+
+	hits, page, err := svc.searchPage(cctx, body, database.ServiceCatalogueVersionIndex, keyVersionId, listParams.Cursor, listParams.Pit, Cursor{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeServiceCatalogueVersionHits(cctx, hits), page, nil
+}
+
 func (svc *Service) FetchServiceCatalogueVersionById(cctx context.CustomContext, versionId string) (*ServiceCatalogueVersion, error) {
 	body := &database.Body{
 		Query: &database.Query{
@@ -49,3 +88,70 @@ func (svc *Service) FetchServiceCatalogueVersionById(cctx context.CustomContext,
 
 	return svc.fetchServiceCatalogueVersion(cctx, body)
 }
+
+// RestoreServiceCatalogueVersion makes an archived version of the service live again.
+// The restore is an update, so the current live version is archived and the restored one gets a new version.
+// The lifecycle is left as it is, moving back and forth through the lifecycle only happens through transitions.
+// A version owned by a team deleted since fails with OwnerTeamGoneErr unless it is re-assigned, see restoredOwnership.
+func (svc *Service) RestoreServiceCatalogueVersion(cctx context.CustomContext, input *RestoreServiceCatalogueVersionRequest, expectedVersions []int) (*ServiceCatalogue, error) {
+	body := &database.Body{
+		Query: &database.Query{
+			Bool: &database.BoolQuery{
+				Must: []database.Query{
+					{Term: &database.TermQuery{keyVersionId: {Value: input.VersionId}}},
+					{Term: &database.TermQuery{keyParentId: {Value: input.ServiceId}}},
+				},
+			},
+		},
+	}
+
+	svcVersion, err := svc.fetchServiceCatalogueVersion(cctx, body)
+	if err != nil {
+		return nil, err
+	}
+	ownership, err := svc.restoredOwnership(cctx, svcVersion.Ownership, input.OwnerTeam)
+	if err != nil {
+		return nil, err
+	}
+
+	// the labels and attributes are replaced as a whole, an empty map removes the ones added since the version
+	labels := map[string]string{}
+	maps.Copy(labels, svcVersion.Labels)
+	attributes := map[string]any{}
+	maps.Copy(attributes, svcVersion.Attributes)
+	dependsOn := append([]*Dependency{}, svcVersion.DependsOn...)
+	links := append([]*Link{}, svcVersion.Links...)
+	restored := &ServiceCatalogue{
+		ServiceId:   input.ServiceId,
+		Name:        svcVersion.Name,
+		Description: svcVersion.Description,
+		Ownership:   ownership,
+		Labels:      labels,
+		Attributes:  attributes,
+		DependsOn:   dependsOn,
+		Links:       links,
+		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+		UpdatedBy:   input.RestoredBy,
+	}
+	return svc.UpdateServiceCatalogue(cctx, restored, expectedVersions)
+}
+
+// toServiceCatalogue converts an archived version to the service as it was while the version was live
+func (v *ServiceCatalogueVersion) toServiceCatalogue() *ServiceCatalogue {
+	return &ServiceCatalogue{
+		ServiceId:   v.ParentId,
+		OrgId:       v.OrgId,
+		Name:        v.Name,
+		Description: v.Description,
+		Ownership:   v.Ownership,
+		Labels:      v.Labels,
+		Attributes:  v.Attributes,
+		DependsOn:   v.DependsOn,
+		Links:       v.Links,
+		Lifecycle:   v.Lifecycle,
+		Deprecation: v.Deprecation,
+		Version:     v.Version,
+		UpdatedAt:   v.CreatedAt,
+		UpdatedBy:   v.CreatedBy,
+	}
+}
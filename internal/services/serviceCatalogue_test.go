@@ -0,0 +1,245 @@
+package services
+
+import (
+	"encoding/json"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateServiceCatalogue_ExpectedVersion(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, svcCat := newTestService(t)
+
+	updated, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedBy: "bob"}, []int{svcCat.Version})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	// the version the client read is stale now
+	_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "issuing", UpdatedBy: "carol"}, []int{svcCat.Version})
+	assert.ErrorIs(t, err, VersionMismatchErr)
+	assert.ErrorIs(t, svc.DeleteService(cctx, svcCat.ServiceId, "carol", []int{svcCat.Version}), VersionMismatchErr)
+
+	live, err := svc.FetchServiceById(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	assert.Equal(t, "acquiring", live.Name)
+	// the update returns the service as stored
+	assert.Equal(t, live, updated)
+	// any of the expected versions matches
+	assert.NoError(t, svc.DeleteService(cctx, svcCat.ServiceId, "carol", []int{svcCat.Version, updated.Version}))
+}
+
+func TestRestoreServiceCatalogueVersion(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, svcCat := newTestService(t)
+
+	_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedBy: "bob"}, nil)
+	require.NoError(t, err)
+	versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	restored, err := svc.RestoreServiceCatalogueVersion(cctx, &RestoreServiceCatalogueVersionRequest{
+		ServiceId:  svcCat.ServiceId,
+		VersionId:  versions[0].VersionId,
+		RestoredBy: "carol",
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, restored.Version)
+
+	live, err := svc.FetchServiceById(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	assert.Equal(t, "payments", live.Name)
+	assert.Equal(t, "carol", live.UpdatedBy)
+	assert.Equal(t, 3, live.Version)
+
+	versions, err = svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	_, err = svc.RestoreServiceCatalogueVersion(cctx, &RestoreServiceCatalogueVersionRequest{
+		ServiceId:  "another-service",
+		VersionId:  versions[0].VersionId,
+		RestoredBy: "carol",
+	}, nil)
+	assert.ErrorIs(t, err, NoDocumentFoundErr)
+}
+
+func TestRestoreDeletedService(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, svcCat := newTestService(t)
+	listParams := &ListDeletedParameters{}
+	listParams.AddDefaultsIfEmpty()
+
+	_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Name: "acquiring", UpdatedBy: "bob"}, nil)
+	require.NoError(t, err)
+	_, err = svc.RestoreDeletedService(cctx, svcCat.ServiceId, "carol", "")
+	assert.ErrorIs(t, err, ServiceNotDeletedErr)
+
+	require.NoError(t, svc.DeleteService(cctx, svcCat.ServiceId, "bob", nil))
+	deleted, _, err := svc.ListDeletedServices(cctx, listParams)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, svcCat.ServiceId, deleted[0].ParentId)
+	assert.Equal(t, 2, deleted[0].Version)
+
+	restored, err := svc.RestoreDeletedService(cctx, svcCat.ServiceId, "carol", "")
+	require.NoError(t, err)
+	assert.Equal(t, "acquiring", restored.Name)
+	assert.Equal(t, 3, restored.Version)
+	assert.Equal(t, svcCat.CreatedAt, restored.CreatedAt)
+	assert.Equal(t, "alice", restored.CreatedBy)
+	assert.Equal(t, "carol", restored.UpdatedBy)
+
+	live, err := svc.FetchServiceById(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	assert.Equal(t, 3, live.Version)
+	versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+	deleted, _, err = svc.ListDeletedServices(cctx, listParams)
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+}
+
+func TestListAllServices_Search(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, _ := newTestService(t)
+
+	for _, req := range []*CreateServiceCatalogueRequest{
+		{Name: "ledger", Description: "double entry ledger for payments", CreatedBy: "alice"},
+		{Name: "notifications", Description: "sends email and sms notifications", CreatedBy: "alice"},
+		{Name: "payouts", Description: "pays out card payments", Lifecycle: LifecycleExperimental, CreatedBy: "alice"},
+	} {
+		_, err := svc.CreateServiceCatalogue(cctx, req.RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+	}
+
+	list := func(listParams *ListParameters) ([]string, *Page) {
+		listParams.AddDefaultsIfEmpty()
+		require.NoError(t, listParams.Validate())
+		svcCats, page, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		names := []string{}
+		for _, svcCat := range svcCats {
+			names = append(names, svcCat.Name)
+		}
+		return names, page
+	}
+
+	t.Run("sorted on relevance by default", func(t *testing.T) {
+		names, page := list(&ListParameters{Search: "payments ledger"})
+		require.Len(t, names, 3)
+		assert.Equal(t, "ledger", names[0], "matching both the terms is more relevant")
+		assert.ElementsMatch(t, []string{"payments", "payouts"}, names[1:])
+		assert.Equal(t, 3, page.Total)
+		assert.Equal(t, database.Desc, (*page.Sort[0])["_score"])
+	})
+
+	t.Run("combined with filters and sort", func(t *testing.T) {
+		names, _ := list(&ListParameters{
+			Search:    "paymnts",
+			Lifecycle: []string{LifecycleProposed},
+			Sort:      []*database.SortField{{"name.keyword": database.Desc}},
+		})
+		assert.Equal(t, []string{"payments", "ledger"}, names)
+
+		names, _ = list(&ListParameters{
+			Search: "payments",
+			Sort:   []*database.SortField{{SortRelevance: database.Asc}},
+			TimeWindow: &TimeWindow{
+				After:  time.Now().UTC().AddDate(0, 0, 1).Format(time.RFC3339),
+				Before: time.Now().UTC().AddDate(0, 0, 2).Format(time.RFC3339),
+			},
+		})
+		assert.Empty(t, names, "the time window applies to the search")
+	})
+
+	t.Run("not held to the default time window", func(t *testing.T) {
+		// a search looks through the whole catalogue like FuzzySearchService, not only the last 30 days
+		ledger := &ServiceCatalogue{ServiceId: "ledger-2025", Name: "old ledger", Description: "double entry ledger of 2025", Lifecycle: LifecycleProduction,
+			Version: 1, CreatedAt: "2025-11-02T10:00:00Z", UpdatedAt: "2025-12-01T10:00:00Z", CreatedBy: "bob", UpdatedBy: "bob"}
+		ledgerBytes, err := json.Marshal(ledger)
+		require.NoError(t, err)
+		require.NoError(t, svc.repo.IndexDocument(cctx, ledgerBytes, database.ServiceCatalogueIndex, ledger.ServiceId))
+
+		names, _ := list(&ListParameters{Search: "ledger", Sort: []*database.SortField{{"name.keyword": database.Asc}}})
+		assert.Equal(t, []string{"ledger", "old ledger"}, names)
+		names, _ = list(&ListParameters{Lifecycle: []string{LifecycleProduction}})
+		assert.Empty(t, names, "without a search the last 30 days are listed")
+	})
+
+	t.Run("highlighted", func(t *testing.T) {
+		listParams := &ListParameters{Search: "notifcations", PreTag: "<mark>", PostTag: "</mark>"}
+		listParams.AddDefaultsIfEmpty()
+		require.NoError(t, listParams.Validate())
+		svcCats, _, err := svc.ListAllServices(cctx, listParams)
+		require.NoError(t, err)
+		require.Len(t, svcCats, 1)
+		assert.Equal(t, map[string][]string{
+			"name":        {"<mark>notifications</mark>"},
+			"description": {"sends email and sms <mark>notifications</mark>"},
+		}, svcCats[0].Highlights)
+
+		svcCats, _, err = svc.ListAllServices(cctx, &ListParameters{From: listParams.From, Size: listParams.Size, Sort: listParams.Sort,
+			TimeStampField: listParams.TimeStampField, TimeWindow: &TimeWindow{After: "2000-01-01T00:00:00Z", Before: time.Now().UTC().Format(time.RFC3339)}})
+		require.NoError(t, err)
+		for _, svcCat := range svcCats {
+			assert.Nil(t, svcCat.Highlights, "a listing without a search is not highlighted")
+		}
+	})
+}
+
+func TestRestore_OwnerTeamGone(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, _ := newTestService(t)
+	payments := newTestTeam(t, svc, "payments")
+	acquiring := newTestTeam(t, svc, "acquiring")
+	svcCat, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+		Name: "ledger", Description: "double entry ledger", Ownership: Ownership{OwnerTeam: payments.TeamId}, CreatedBy: "alice",
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	t.Run("version", func(t *testing.T) {
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{ServiceId: svcCat.ServiceId, Ownership: Ownership{OwnerTeam: acquiring.TeamId}, UpdatedBy: "bob"}, nil)
+		require.NoError(t, err)
+		require.NoError(t, svc.DeleteTeam(cctx, payments.TeamId))
+		versions, err := svc.ListAllServiceVersions(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+
+		restoreReq := &RestoreServiceCatalogueVersionRequest{ServiceId: svcCat.ServiceId, VersionId: versions[0].VersionId, RestoredBy: "carol"}
+		_, err = svc.RestoreServiceCatalogueVersion(cctx, restoreReq, nil)
+		assert.ErrorIs(t, err, OwnerTeamGoneErr)
+
+		restoreReq.OwnerTeam = "missing"
+		_, err = svc.RestoreServiceCatalogueVersion(cctx, restoreReq, nil)
+		assert.ErrorIs(t, err, UnknownTeamErr)
+
+		restoreReq.OwnerTeam = acquiring.TeamId
+		restored, err := svc.RestoreServiceCatalogueVersion(cctx, restoreReq, nil)
+		require.NoError(t, err)
+		assert.Equal(t, acquiring.TeamId, restored.OwnerTeam)
+	})
+
+	t.Run("deleted service", func(t *testing.T) {
+		require.NoError(t, svc.DeleteService(cctx, svcCat.ServiceId, "bob", nil))
+		require.NoError(t, svc.DeleteTeam(cctx, acquiring.TeamId))
+
+		_, err := svc.RestoreDeletedService(cctx, svcCat.ServiceId, "carol", "")
+		assert.ErrorIs(t, err, OwnerTeamGoneErr)
+		assert.ErrorContains(t, err, "restore it with an `ownerTeam` to re-assign it")
+
+		storefront := newTestTeam(t, svc, "storefront")
+		restored, err := svc.RestoreDeletedService(cctx, svcCat.ServiceId, "carol", storefront.TeamId)
+		require.NoError(t, err)
+		assert.Equal(t, storefront.TeamId, restored.OwnerTeam)
+		live, err := svc.FetchServiceById(cctx, svcCat.ServiceId)
+		require.NoError(t, err)
+		assert.Equal(t, storefront.TeamId, live.OwnerTeam)
+	})
+}
@@ -4,53 +4,277 @@ import (
 	"fmt"
 	"nikki-noceps/serviceCatalogue/pkg/context"
 	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/jsonschema"
+	"regexp"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/google/uuid"
 )
 
+var (
+	diffVersionRegex  = regexp.MustCompile(`^([1-9][0-9]*|current)$`)
+	emailRegex        = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	slackChannelRegex = regexp.MustCompile(`^#[a-z0-9][a-z0-9._-]{0,79}$`)
+)
+
 type (
 	SortOrder string
 
+	// Ownership tells who owns a service and how to reach them. It is flattened into the
+	// catalogue and version documents.
+	Ownership struct {
+		OwnerTeam      string `json:"ownerTeam,omitempty" mapstructure:"ownerTeam,omitempty"`
+		TechnicalOwner string `json:"technicalOwner,omitempty" mapstructure:"technicalOwner,omitempty"`
+		OnCall         string `json:"onCall,omitempty" mapstructure:"onCall,omitempty"`
+		SlackChannel   string `json:"slackChannel,omitempty" mapstructure:"slackChannel,omitempty"`
+		Email          string `json:"email,omitempty" mapstructure:"email,omitempty"`
+	}
+
+	// Deprecation tells when a deprecated service was deprecated, when it goes away and which service
+	// replaces it. It is flattened into the catalogue and version documents like Ownership.
+	Deprecation struct {
+		DeprecatedAt string `json:"deprecatedAt,omitempty" mapstructure:"deprecatedAt,omitempty"`
+		SunsetAt     string `json:"sunsetAt,omitempty" mapstructure:"sunsetAt,omitempty"`
+		ReplacedBy   string `json:"replacedBy,omitempty" mapstructure:"replacedBy,omitempty"`
+	}
+
 	ServiceCatalogue struct {
 		ServiceId   string `json:"serviceId,omitempty" mapstructure:"serviceId,omitempty"`
+		OrgId       string `json:"orgId,omitempty" mapstructure:"orgId,omitempty"`
 		Name        string `json:"name,omitempty" mapstructure:"name,omitempty"`
 		Description string `json:"description,omitempty" mapstructure:"description,omitempty"`
+		Ownership   `mapstructure:",squash"`
+		Labels      map[string]string `json:"labels,omitempty" mapstructure:"labels,omitempty"`
+		// LabelPairs are the labels as `key=value` the labels are counted on, see labelPairs
+		LabelPairs  []string       `json:"labelPairs,omitempty" mapstructure:"labelPairs,omitempty"`
+		Attributes  map[string]any `json:"attributes,omitempty" mapstructure:"attributes,omitempty"`
+		DependsOn   []*Dependency  `json:"dependsOn,omitempty" mapstructure:"dependsOn,omitempty"`
+		Links       []*Link        `json:"links,omitempty" mapstructure:"links,omitempty"`
+		Lifecycle   string         `json:"lifecycle,omitempty" mapstructure:"lifecycle,omitempty"`
+		Deprecation `mapstructure:",squash"`
 		Version     int    `json:"version,omitempty" mapstructure:"version,omitempty"`
 		CreatedAt   string `json:"createdAt,omitempty" mapstructure:"createdAt,omitempty"`
 		UpdatedAt   string `json:"updatedAt,omitempty" mapstructure:"updatedAt,omitempty"`
 		CreatedBy   string `json:"createdBy,omitempty" mapstructure:"createdBy,omitempty"`
 		UpdatedBy   string `json:"updatedBy,omitempty" mapstructure:"updatedBy,omitempty"`
+		// Warnings about the service found while writing it, like dependency cycles. They are not stored.
+		Warnings []string `json:"-" mapstructure:"-"`
+		// Highlights are the fragments of the fields matching a search by field. They are not stored.
+		Highlights map[string][]string `json:"-" mapstructure:"-"`
+	}
+
+	LifecycleTransition struct {
+		From string `json:"from" mapstructure:"from"`
+		To   string `json:"to" mapstructure:"to"`
+	}
+
+	// Dependency is a service this service depends on and how it talks to it
+	Dependency struct {
+		ServiceId string `json:"serviceId" mapstructure:"serviceId"`
+		Type      string `json:"type" mapstructure:"type"`
+	}
+
+	// Link points to something of the service like its repository, a dashboard or the url of an environment.
+	// Host is derived from the url so services can be listed by the host of their links.
+	Link struct {
+		Kind        string `json:"kind" mapstructure:"kind"`
+		Title       string `json:"title,omitempty" mapstructure:"title,omitempty"`
+		Url         string `json:"url" mapstructure:"url"`
+		Environment string `json:"environment,omitempty" mapstructure:"environment,omitempty"`
+		Host        string `json:"host,omitempty" mapstructure:"host,omitempty"`
 	}
 
 	ServiceCatalogueVersion struct {
 		ParentId        string `json:"parentId,omitempty" mapstructure:"parentId,omitempty"`
 		VersionId       string `json:"versionId,omitempty" mapstructure:"versionId,omitempty"`
+		OrgId           string `json:"orgId,omitempty" mapstructure:"orgId,omitempty"`
 		Name            string `json:"name,omitempty" mapstructure:"name,omitempty"`
 		Description     string `json:"description,omitempty" mapstructure:"description,omitempty"`
+		Ownership       `mapstructure:",squash"`
+		Labels          map[string]string `json:"labels,omitempty" mapstructure:"labels,omitempty"`
+		Attributes      map[string]any    `json:"attributes,omitempty" mapstructure:"attributes,omitempty"`
+		DependsOn       []*Dependency     `json:"dependsOn,omitempty" mapstructure:"dependsOn,omitempty"`
+		Links           []*Link           `json:"links,omitempty" mapstructure:"links,omitempty"`
+		Lifecycle       string            `json:"lifecycle,omitempty" mapstructure:"lifecycle,omitempty"`
+		Deprecation     `mapstructure:",squash"`
 		Version         int    `json:"version,omitempty" mapstructure:"version,omitempty"`
 		CreatedAt       string `json:"createdAt,omitempty" mapstructure:"createdAt,omitempty"`
 		DecomissionedAt string `json:"decomissionedAt,omitempty" mapstructure:"decomissionedAt,omitempty"`
 		CreatedBy       string `json:"createdBy,omitempty" mapstructure:"createdBy,omitempty"`
 		DecomissionedBy string `json:"decomissionedBy,omitempty" mapstructure:"decomissionedBy,omitempty"`
+		// Transition is the lifecycle transition which ended this version
+		Transition *LifecycleTransition `json:"transition,omitempty" mapstructure:"transition,omitempty"`
+		// Deleted marks the version archived when the service was deleted, cleared once the service is restored
+		Deleted bool `json:"deleted,omitempty" mapstructure:"deleted,omitempty"`
+	}
+
+	// Team owns services, ServiceCatalogue.OwnerTeam references its TeamId. Teams form a hierarchy
+	// through ParentTeamId.
+	Team struct {
+		TeamId       string   `json:"teamId,omitempty" mapstructure:"teamId,omitempty"`
+		OrgId        string   `json:"orgId,omitempty" mapstructure:"orgId,omitempty"`
+		Name         string   `json:"name,omitempty" mapstructure:"name,omitempty"`
+		Description  string   `json:"description,omitempty" mapstructure:"description,omitempty"`
+		Members      []string `json:"members,omitempty" mapstructure:"members,omitempty"`
+		SlackChannel string   `json:"slackChannel,omitempty" mapstructure:"slackChannel,omitempty"`
+		Email        string   `json:"email,omitempty" mapstructure:"email,omitempty"`
+		ParentTeamId string   `json:"parentTeamId,omitempty" mapstructure:"parentTeamId,omitempty"`
+		CreatedAt    string   `json:"createdAt,omitempty" mapstructure:"createdAt,omitempty"`
+		UpdatedAt    string   `json:"updatedAt,omitempty" mapstructure:"updatedAt,omitempty"`
+		CreatedBy    string   `json:"createdBy,omitempty" mapstructure:"createdBy,omitempty"`
+		UpdatedBy    string   `json:"updatedBy,omitempty" mapstructure:"updatedBy,omitempty"`
 	}
 
+	// AttributesSchema is the JSON schema the attributes of services are validated against
+	AttributesSchema struct {
+		Schema    map[string]any `json:"schema" mapstructure:"schema"`
+		Version   int            `json:"version" mapstructure:"version"`
+		UpdatedAt string         `json:"updatedAt" mapstructure:"updatedAt"`
+		UpdatedBy string         `json:"updatedBy" mapstructure:"updatedBy"`
+		OrgId     string         `json:"orgId,omitempty" mapstructure:"orgId,omitempty"`
+
+		parsed *jsonschema.Schema
+	}
+
+	// ServiceCatalogueChange is an outbox entry for archiving the live version of a service and
+	// mutating the live document. It is kept until both the steps are applied or rolled back.
+	ServiceCatalogueChange struct {
+		ChangeId   string                   `json:"changeId,omitempty" mapstructure:"changeId,omitempty"`
+		Operation  string                   `json:"operation,omitempty" mapstructure:"operation,omitempty"`
+		ServiceId  string                   `json:"serviceId,omitempty" mapstructure:"serviceId,omitempty"`
+		OrgId      string                   `json:"orgId,omitempty" mapstructure:"orgId,omitempty"`
+		DocumentId string                   `json:"documentId,omitempty" mapstructure:"documentId,omitempty"`
+		Version    int                      `json:"version,omitempty" mapstructure:"version,omitempty"`
+		Archive    *ServiceCatalogueVersion `json:"archive,omitempty" mapstructure:"archive,omitempty"`
+		Update     map[string]any           `json:"update,omitempty" mapstructure:"update,omitempty"`
+		// UpdatedAt is written to the live document by an update, the version it produces is told apart from the
+		// versions of other writers by it, see isChangeApplied
+		UpdatedAt string `json:"updatedAt,omitempty" mapstructure:"updatedAt,omitempty"`
+		CreatedAt string `json:"createdAt,omitempty" mapstructure:"createdAt,omitempty"`
+		// Attempts counts the failed attempts to reconcile the change, it is not retried before RetryAt.
+		// DeadLetteredAt is set once it failed too often, the change is then left for an operator.
+		Attempts       int    `json:"attempts,omitempty" mapstructure:"attempts,omitempty"`
+		RetryAt        string `json:"retryAt,omitempty" mapstructure:"retryAt,omitempty"`
+		DeadLetteredAt string `json:"deadLetteredAt,omitempty" mapstructure:"deadLetteredAt,omitempty"`
+		// Revision of the live document the change was computed from, only used while applying it
+		Revision *database.Revision `json:"-" mapstructure:"-"`
+	}
+
+	// Query is a query string parsed by ParseQuery, all of the clauses have to match
 	Query struct {
+		Clauses []*QueryClause
+	}
+
+	// QueryClause is a single clause of a query string like `owner:payments`, `-deprecated` or `"card vault"`.
+	// Clauses without a field are free text words or phrases.
+	QueryClause struct {
+		Field    string
+		Operator QueryOperator
+		Value    string
+		Phrase   bool
+		Negated  bool
 	}
 
 	ListParameters struct {
-		From           *int                  `json:"from"`
-		Size           *int                  `json:"size"`
+		// Search fuzzy matches the name and the description of the services, see listQuery
+		Search string `json:"search"`
+		// Query lists only the services matching the query string, see ParseQuery
+		Query *Query `json:"-"`
+		From  *int   `json:"from"`
+		Size  *int   `json:"size"`
+		// Sort is on the fields of the services, or on SortRelevance to the search
 		Sort           []*database.SortField `json:"sort"`
 		TimeStampField string                `json:"timestampfield"`
 		TimeWindow     *TimeWindow           `json:"timewindow"`
+		// AsOf lists the catalogue as it was at this RFC3339 timestamp
+		AsOf string `json:"asOf"`
+		// Cursor continues the listing from a page returned before, Pit opens a point in time for the listing
+		Cursor *Cursor `json:"-"`
+		Pit    bool    `json:"pit"`
+		// Owner lists only the services owned by this team
+		Owner string `json:"owner"`
+		// LabelSelector lists only the services with matching labels
+		LabelSelector []*LabelRequirement `json:"-"`
+		// Facets counts the services matching the filters, see Facets
+		Facets bool `json:"facets"`
+		// Lifecycle lists only the services in these lifecycles, all but retired when empty
+		Lifecycle []string `json:"lifecycle"`
+		// Attributes lists only the services with any of the values of each attribute
+		Attributes map[string][]string `json:"-"`
+		// LinkHost lists only the services with a link on any of the hosts, LinkKind with a link of the kind.
+		// Both have to match on the same link.
+		LinkHost []string `json:"linkHost"`
+		LinkKind string   `json:"linkKind"`
+		// PreTag and PostTag wrap the words matching the search in the highlights of the name and the description
+		PreTag  string `json:"preTag"`
+		PostTag string `json:"postTag"`
 	}
 
 	SearchParameters struct {
-		Search string `json:"search"`
-		From   *int   `json:"from"`
-		Size   *int   `json:"size"`
+		Search string  `json:"search"`
+		From   *int    `json:"from"`
+		Size   *int    `json:"size"`
+		Cursor *Cursor `json:"-"`
+		Pit    bool    `json:"pit"`
+		// Facets counts the services matching the search, see Facets
+		Facets bool `json:"facets"`
+		// PreTag and PostTag wrap the words matching the search in the highlights of the name and the description
+		PreTag  string `json:"preTag"`
+		PostTag string `json:"postTag"`
+	}
+
+	// SuggestParameters complete the text typed in a search box, see Suggest
+	SuggestParameters struct {
+		Q    string `json:"q"`
+		Size *int   `json:"size"`
+	}
+
+	ListVersionsParameters struct {
+		From   *int    `json:"from"`
+		Size   *int    `json:"size"`
+		Cursor *Cursor `json:"-"`
+		Pit    bool    `json:"pit"`
+	}
+
+	ListDeprecationsParameters struct {
+		From   *int    `json:"from"`
+		Size   *int    `json:"size"`
+		Cursor *Cursor `json:"-"`
+		Pit    bool    `json:"pit"`
+		// PastSunset lists only the services past their sunset
+		PastSunset bool `json:"pastSunset"`
+	}
+
+	ListDeletedParameters struct {
+		From   *int    `json:"from"`
+		Size   *int    `json:"size"`
+		Cursor *Cursor `json:"-"`
+		Pit    bool    `json:"pit"`
+	}
+
+	// ListTeamsParameters pages through the teams and the services owned by a team
+	ListTeamsParameters struct {
+		From   *int    `json:"from"`
+		Size   *int    `json:"size"`
+		Cursor *Cursor `json:"-"`
+		Pit    bool    `json:"pit"`
+	}
+
+	// DependencyGraphParameters is how many hops the dependency graph is walked
+	DependencyGraphParameters struct {
+		Depth *int `json:"depth"`
+	}
+
+	// ImpactParameters is how many hops of dependents are followed and how the impact is rendered
+	ImpactParameters struct {
+		Depth  *int   `json:"depth"`
+		Format string `json:"format"`
+	}
+
+	DiffParameters struct {
+		From string `json:"from"`
+		To   string `json:"to"`
 	}
 
 	TimeWindow struct {
@@ -61,14 +285,71 @@ type (
 	CreateServiceCatalogueRequest struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
-		CreatedBy   string `json:"-"`
+		Ownership
+		Labels     map[string]string `json:"labels"`
+		Attributes map[string]any    `json:"attributes"`
+		DependsOn  []*Dependency     `json:"dependsOn"`
+		Links      []*Link           `json:"links"`
+		// Lifecycle defaults to proposed, services are created as proposed or experimental
+		Lifecycle string `json:"lifecycle"`
+		// SunsetAt and ReplacedBy can only be set on deprecated services
+		SunsetAt   string `json:"sunsetAt"`
+		ReplacedBy string `json:"replacedBy"`
+		CreatedBy  string `json:"-"`
 	}
 
+	// UpdateServiceCatalogueRequest changes the fields which are set, Labels, Attributes, DependsOn and Links
+	// replace all the labels, attributes, dependencies and links when present
 	UpdateServiceCatalogueRequest struct {
 		Name        string `json:"name"`
 		Description string `json:"description"`
-		UpdatedBy   string `json:"-"`
-		ServiceId   string `json:"-"`
+		Ownership
+		Labels     map[string]string `json:"labels"`
+		Attributes map[string]any    `json:"attributes"`
+		DependsOn  []*Dependency     `json:"dependsOn"`
+		Links      []*Link           `json:"links"`
+		// Lifecycle moves the service to another lifecycle, see lifecycleTransitions
+		Lifecycle string `json:"lifecycle"`
+		// SunsetAt is required when deprecating a service
+		SunsetAt   string `json:"sunsetAt"`
+		ReplacedBy string `json:"replacedBy"`
+		UpdatedBy  string `json:"-"`
+		ServiceId  string `json:"-"`
+	}
+
+	CreateTeamRequest struct {
+		Name         string   `json:"name"`
+		Description  string   `json:"description"`
+		Members      []string `json:"members"`
+		SlackChannel string   `json:"slackChannel"`
+		Email        string   `json:"email"`
+		ParentTeamId string   `json:"parentTeamId"`
+		CreatedBy    string   `json:"-"`
+	}
+
+	// UpdateTeamRequest changes the fields which are set, Members replaces the members when present
+	UpdateTeamRequest struct {
+		Name         string   `json:"name"`
+		Description  string   `json:"description"`
+		Members      []string `json:"members"`
+		SlackChannel string   `json:"slackChannel"`
+		Email        string   `json:"email"`
+		ParentTeamId string   `json:"parentTeamId"`
+		UpdatedBy    string   `json:"-"`
+		TeamId       string   `json:"-"`
+	}
+
+	RestoreDeletedServiceRequest struct {
+		// OwnerTeam re-assigns the restored service, required when the team owning it was deleted
+		OwnerTeam string `json:"ownerTeam"`
+	}
+
+	RestoreServiceCatalogueVersionRequest struct {
+		// OwnerTeam re-assigns the restored service, required when the team owning the version was deleted
+		OwnerTeam  string `json:"ownerTeam"`
+		ServiceId  string `json:"-"`
+		VersionId  string `json:"-"`
+		RestoredBy string `json:"-"`
 	}
 
 	CreateServiceCatalogueResponse struct {
@@ -83,37 +364,237 @@ type (
 	ListServiceCatalogueRequest struct {
 	}
 
+	TeamResponse struct {
+		TeamId       string   `json:"teamId"`
+		Name         string   `json:"name"`
+		Description  string   `json:"description"`
+		Members      []string `json:"members"`
+		SlackChannel string   `json:"slackChannel,omitempty"`
+		Email        string   `json:"email,omitempty"`
+		ParentTeamId string   `json:"parentTeamId,omitempty"`
+		CreatedAt    string   `json:"createdAt"`
+		UpdatedAt    string   `json:"updatedAt"`
+		CreatedBy    string   `json:"createdBy"`
+		UpdatedBy    string   `json:"updatedBy"`
+	}
+
+	AttributesSchemaResponse struct {
+		*AttributesSchema `json:"attributesSchema"`
+		TimeStamp         string `json:"timestamp"`
+	}
+
+	CreateTeamResponse struct {
+		*TeamResponse `json:"team"`
+		TimeStamp     string `json:"timestamp"`
+	}
+
+	UpdateTeamResponse struct {
+		*TeamResponse `json:"team"`
+		TimeStamp     string `json:"timestamp"`
+	}
+
+	// DeprecatedService is an entry of the deprecations report
+	DeprecatedService struct {
+		ServiceId string `json:"serviceId"`
+		Name      string `json:"name"`
+		OwnerTeam string `json:"ownerTeam,omitempty"`
+		Deprecation
+		PastSunset bool `json:"pastSunset"`
+		// Dependents are the services which are not retired and still depend on the service
+		Dependents []string `json:"dependents"`
+		// Overdue flags a service past its sunset which still has dependents
+		Overdue bool `json:"overdue"`
+	}
+
+	ListDeprecationsResponse struct {
+		Deprecations []*DeprecatedService `json:"deprecations"`
+		*Page
+		TimeStamp string `json:"timestamp"`
+	}
+
+	ListTeamsResponse struct {
+		Teams []*TeamResponse `json:"teams"`
+		*Page
+		TimeStamp string `json:"timestamp"`
+	}
+
 	ServiceCatalogueResponse struct {
 		ServiceId   string `json:"serviceId"`
 		Name        string `json:"name"`
 		Description string `json:"description"`
-		Version     int    `json:"version"`
-		CreatedAt   string `json:"createdAt"`
-		UpdatedAt   string `json:"updatedAt"`
-		CreatedBy   string `json:"createdBy"`
-		UpdatedBy   string `json:"updatedBy"`
+		Ownership
+		Labels     map[string]string `json:"labels"`
+		Attributes map[string]any    `json:"attributes,omitempty"`
+		DependsOn  []*Dependency     `json:"dependsOn"`
+		Links      []*Link           `json:"links"`
+		Lifecycle  string            `json:"lifecycle"`
+		Deprecation
+		Version   int      `json:"version"`
+		CreatedAt string   `json:"createdAt"`
+		UpdatedAt string   `json:"updatedAt"`
+		CreatedBy string   `json:"createdBy"`
+		UpdatedBy string   `json:"updatedBy"`
+		Warnings  []string `json:"warnings,omitempty"`
+		// Highlights are the fragments of the name and the description matching a search
+		Highlights map[string][]string `json:"highlights,omitempty"`
 	}
 
 	ServiceCatalogueVersionResponse struct {
-		ParentId        string `json:"parentId,omitempty"`
-		VersionId       string `json:"versionId,omitempty"`
-		Name            string `json:"name,omitempty"`
-		Description     string `json:"description,omitempty"`
-		Version         int    `json:"version,omitempty"`
-		CreatedAt       string `json:"createdAt,omitempty"`
-		DecomissionedAt string `json:"decomissionedAt,omitempty"`
-		CreatedBy       string `json:"createdBy,omitempty"`
-		DecomissionedBy string `json:"decomissionedBy,omitempty"`
+		ParentId    string `json:"parentId,omitempty"`
+		VersionId   string `json:"versionId,omitempty"`
+		Name        string `json:"name,omitempty"`
+		Description string `json:"description,omitempty"`
+		Ownership
+		Labels     map[string]string `json:"labels,omitempty"`
+		Attributes map[string]any    `json:"attributes,omitempty"`
+		DependsOn  []*Dependency     `json:"dependsOn,omitempty"`
+		Links      []*Link           `json:"links,omitempty"`
+		Lifecycle  string            `json:"lifecycle,omitempty"`
+		Deprecation
+		Transition      *LifecycleTransition `json:"transition,omitempty"`
+		Version         int                  `json:"version,omitempty"`
+		CreatedAt       string               `json:"createdAt,omitempty"`
+		DecomissionedAt string               `json:"decomissionedAt,omitempty"`
+		CreatedBy       string               `json:"createdBy,omitempty"`
+		DecomissionedBy string               `json:"decomissionedBy,omitempty"`
+		Deleted         bool                 `json:"deleted,omitempty"`
+	}
+
+	// Suggestion is a service whose name matches the typed text, Highlight is the name with the typed prefixes highlighted
+	Suggestion struct {
+		ServiceId string `json:"serviceId"`
+		Name      string `json:"name"`
+		Highlight string `json:"highlight"`
+	}
+
+	SuggestResponse struct {
+		Suggestions []*Suggestion `json:"suggestions"`
 	}
 
 	ListServiceCatalogueResponse struct {
 		ServiceList []*ServiceCatalogueResponse `json:"serviceList"`
-		TimeStamp   string                      `json:"timestamp"`
+		*Page
+		Facets    *Facets `json:"facets,omitempty"`
+		TimeStamp string  `json:"timestamp"`
+	}
+
+	// Facets counts the services matching the filters of a listing or a search
+	Facets struct {
+		// Labels counts the services per label key and value
+		Labels map[string]map[string]int `json:"labels"`
+		// Owners counts the services per owner team, services without an owner are not counted
+		Owners map[string]int `json:"owners"`
+		// Lifecycles counts the services per lifecycle
+		Lifecycles map[string]int `json:"lifecycles"`
+		// CreatedBy counts the services per user who created them
+		CreatedBy map[string]int `json:"createdBy"`
+		// CreatedPerMonth counts the services per month they were created in, oldest first
+		CreatedPerMonth []*MonthlyCount `json:"createdPerMonth"`
+	}
+
+	// MonthlyCount is the number of services of a month formatted as `2006-01`
+	MonthlyCount struct {
+		Month string `json:"month"`
+		Count int    `json:"count"`
+	}
+
+	// ServiceCatalogueRevision tells who made a version of the service and when
+	ServiceCatalogueRevision struct {
+		Version   int    `json:"version"`
+		ChangedAt string `json:"changedAt"`
+		ChangedBy string `json:"changedBy"`
+	}
+
+	FieldDiff struct {
+		Field string `json:"field"`
+		From  any    `json:"from"`
+		To    any    `json:"to"`
+	}
+
+	ServiceCatalogueDiff struct {
+		ServiceId string                      `json:"serviceId"`
+		From      *ServiceCatalogueRevision   `json:"from"`
+		To        *ServiceCatalogueRevision   `json:"to"`
+		Changes   []*FieldDiff                `json:"changes"`
+		Revisions []*ServiceCatalogueRevision `json:"revisions"`
+	}
+
+	// DependencyGraph is the part of the dependency graph reachable from a service within Depth hops,
+	// following the dependencies or the dependents of the service
+	DependencyGraph struct {
+		ServiceId string            `json:"serviceId"`
+		Direction string            `json:"direction"`
+		Depth     int               `json:"depth"`
+		Nodes     []*DependencyNode `json:"nodes"`
+		Edges     []*DependencyEdge `json:"edges"`
+	}
+
+	DependencyNode struct {
+		ServiceId string `json:"serviceId"`
+		Name      string `json:"name,omitempty"`
+		OwnerTeam string `json:"ownerTeam,omitempty"`
+		// OwnerTeamName is only resolved for the impact analysis
+		OwnerTeamName string `json:"ownerTeamName,omitempty"`
+		// Depth is the least number of hops from the root service
+		Depth int `json:"depth"`
+		// Via is the service one hop closer to the root the node was first reached from
+		Via string `json:"via,omitempty"`
+		// Missing marks a dependency on a service which does not exist anymore
+		Missing bool `json:"missing,omitempty"`
+	}
+
+	// DependencyEdge is a declared dependency of From on To
+	DependencyEdge struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+		Type string `json:"type"`
+	}
+
+	DependencyGraphResponse struct {
+		*DependencyGraph `json:"graph"`
+		TimeStamp        string `json:"timestamp"`
+	}
+
+	// Impact is the blast radius of a service: every service depending on it directly or transitively
+	Impact struct {
+		ServiceId string             `json:"serviceId"`
+		Depth     int                `json:"depth"`
+		Impacted  []*ImpactedService `json:"impacted"`
+		// Teams are the teams owning the impacted services
+		Teams []*ImpactedTeam  `json:"teams"`
+		Graph *DependencyGraph `json:"-"`
+	}
+
+	ImpactedService struct {
+		ServiceId     string `json:"serviceId"`
+		Name          string `json:"name"`
+		OwnerTeam     string `json:"ownerTeam,omitempty"`
+		OwnerTeamName string `json:"ownerTeamName,omitempty"`
+		Depth         int    `json:"depth"`
+		// Path is how an outage travels from the service to the impacted one, both included
+		Path []string `json:"path"`
+	}
+
+	// ImpactedTeam is a team owning impacted services, the name is empty when the team does not exist anymore
+	ImpactedTeam struct {
+		TeamId string `json:"teamId"`
+		Name   string `json:"name,omitempty"`
+	}
+
+	ImpactResponse struct {
+		*Impact   `json:"impact"`
+		TimeStamp string `json:"timestamp"`
+	}
+
+	ServiceCatalogueDiffResponse struct {
+		*ServiceCatalogueDiff `json:"diff"`
+		TimeStamp             string `json:"timestamp"`
 	}
 
 	ListServiceCatalogueVersionsResponse struct {
 		ServiceVersionsList []*ServiceCatalogueVersionResponse `json:"versions"`
-		TimeStamp           string                             `json:"timestamp"`
+		*Page
+		TimeStamp string `json:"timestamp"`
 	}
 )
 
@@ -123,7 +604,65 @@ func (l *ListParameters) Validate() error {
 		validation.Field(&l.Size, validation.NotNil, validation.Min(10), validation.Max(50)),
 		validation.Field(&l.Sort, validation.Required),
 		validation.Field(&l.TimeStampField, validation.Required, validation.In("createdAt", "updatedAt")),
-		validation.Field(&l.TimeWindow, validation.Required, validation.By(validateTimeDifference)),
+		validation.Field(&l.TimeWindow, validation.Required.When(l.defaultsTimeWindow()),
+			validation.When(l.TimeWindow != nil, validation.By(validateTimeDifference))),
+		validation.Field(&l.AsOf, validation.Date(time.RFC3339).Error("must be in RFC3339 format")),
+		validation.Field(&l.Lifecycle, validation.Each(validation.In(lifecycles...))),
+		validation.Field(&l.LinkHost, validation.Each(validation.Required, validation.Length(1, 253))),
+		validation.Field(&l.LinkKind, validation.In(linkKinds...)),
+		validation.Field(&l.PreTag, validation.RuneLength(0, maxHighlightTagLength)),
+		validation.Field(&l.PostTag, validation.RuneLength(0, maxHighlightTagLength)),
+	)
+}
+
+func (l *ListVersionsParameters) Validate() error {
+	return validation.ValidateStruct(l,
+		validation.Field(&l.From, validation.NotNil, validation.Min(0), validation.Max(1000)),
+		validation.Field(&l.Size, validation.NotNil, validation.Min(10), validation.Max(50)),
+	)
+}
+
+// Parses the struct and adds default values if empty
+func (l *ListVersionsParameters) AddDefaultsIfEmpty() {
+	if l.From == nil {
+		from := 0
+		l.From = &from
+	}
+	if l.Size == nil {
+		size := 10
+		l.Size = &size
+	}
+}
+
+// Parses the struct and adds default values if empty
+func (s *SearchParameters) AddDefaultsIfEmpty() {
+	if s.From == nil {
+		from := 0
+		s.From = &from
+	}
+	if s.Size == nil {
+		size := 20
+		s.Size = &size
+	}
+	if s.PreTag == "" {
+		s.PreTag = defaultHighlightPreTag
+	}
+	if s.PostTag == "" {
+		s.PostTag = defaultHighlightPostTag
+	}
+}
+
+func (s *SuggestParameters) AddDefaultsIfEmpty() {
+	if s.Size == nil {
+		size := defaultSuggestions
+		s.Size = &size
+	}
+}
+
+func (s *SuggestParameters) Validate() error {
+	return validation.ValidateStruct(s,
+		validation.Field(&s.Q, validation.Required, validation.RuneLength(1, maxSuggestLength)),
+		validation.Field(&s.Size, validation.NotNil, validation.Min(1), validation.Max(maxSuggestions)),
 	)
 }
 
@@ -132,18 +671,164 @@ func (s *SearchParameters) Validate() error {
 		validation.Field(&s.From, validation.NotNil, validation.Min(0), validation.Max(1000)),
 		validation.Field(&s.Size, validation.NotNil, validation.Min(10), validation.Max(50)),
 		validation.Field(&s.Search, validation.Required),
+		validation.Field(&s.PreTag, validation.RuneLength(0, maxHighlightTagLength)),
+		validation.Field(&s.PostTag, validation.RuneLength(0, maxHighlightTagLength)),
+	)
+}
+
+func (l *ListDeprecationsParameters) Validate() error {
+	return validation.ValidateStruct(l,
+		validation.Field(&l.From, validation.NotNil, validation.Min(0), validation.Max(1000)),
+		validation.Field(&l.Size, validation.NotNil, validation.Min(10), validation.Max(50)),
+	)
+}
+
+// Parses the struct and adds default values if empty
+func (l *ListDeprecationsParameters) AddDefaultsIfEmpty() {
+	if l.From == nil {
+		from := 0
+		l.From = &from
+	}
+	if l.Size == nil {
+		size := 10
+		l.Size = &size
+	}
+}
+
+func (l *ListDeletedParameters) Validate() error {
+	return validation.ValidateStruct(l,
+		validation.Field(&l.From, validation.NotNil, validation.Min(0), validation.Max(1000)),
+		validation.Field(&l.Size, validation.NotNil, validation.Min(10), validation.Max(50)),
+	)
+}
+
+func (l *ListTeamsParameters) Validate() error {
+	return validation.ValidateStruct(l,
+		validation.Field(&l.From, validation.NotNil, validation.Min(0), validation.Max(1000)),
+		validation.Field(&l.Size, validation.NotNil, validation.Min(10), validation.Max(50)),
+	)
+}
+
+// Parses the struct and adds default values if empty
+func (l *ListTeamsParameters) AddDefaultsIfEmpty() {
+	if l.From == nil {
+		from := 0
+		l.From = &from
+	}
+	if l.Size == nil {
+		size := 10
+		l.Size = &size
+	}
+}
+
+func (d *Dependency) Validate() error {
+	return validation.ValidateStruct(d,
+		validation.Field(&d.ServiceId, validation.Required),
+		validation.Field(&d.Type, validation.Required, validation.In(DependencyHTTP, DependencyGRPC, DependencyQueue, DependencyDatabase)),
+	)
+}
+
+func (l *Link) Validate() error {
+	return validation.ValidateStruct(l,
+		validation.Field(&l.Kind, validation.Required, validation.In(linkKinds...)),
+		validation.Field(&l.Title, validation.Length(0, 64)),
+		validation.Field(&l.Url, validation.Required, validation.Length(0, 2048), validation.By(validateLinkUrl)),
+		validation.Field(&l.Environment, validation.Match(environmentRegex).Error("must be a lower case environment name like `production`")),
+	)
+}
+
+func (d *DependencyGraphParameters) Validate() error {
+	return validation.ValidateStruct(d,
+		validation.Field(&d.Depth, validation.NotNil, validation.Min(1), validation.Max(maxDependencyDepth)),
+	)
+}
+
+// Parses the struct and adds default values if empty
+func (d *DependencyGraphParameters) AddDefaultsIfEmpty() {
+	if d.Depth == nil {
+		depth := 1
+		d.Depth = &depth
+	}
+}
+
+func (i *ImpactParameters) Validate() error {
+	return validation.ValidateStruct(i,
+		validation.Field(&i.Depth, validation.NotNil, validation.Min(1), validation.Max(maxDependencyDepth)),
+		validation.Field(&i.Format, validation.Required, validation.In(ImpactFormatJSON, ImpactFormatDOT, ImpactFormatMermaid)),
+	)
+}
+
+// Parses the struct and adds default values if empty, the impact follows all the dependents by default
+func (i *ImpactParameters) AddDefaultsIfEmpty() {
+	if i.Depth == nil {
+		depth := maxDependencyDepth
+		i.Depth = &depth
+	}
+	if i.Format == "" {
+		i.Format = ImpactFormatJSON
+	}
+}
+
+func (d *DiffParameters) Validate() error {
+	return validation.ValidateStruct(d,
+		validation.Field(&d.From, validation.Required, validation.Match(diffVersionRegex).Error("must be a version number or `current`")),
+		validation.Field(&d.To, validation.Required, validation.Match(diffVersionRegex).Error("must be a version number or `current`")),
 	)
 }
 
 // Parses the struct and adds default values if empty
+func (d *DiffParameters) AddDefaultsIfEmpty() {
+	if d.To == "" {
+		d.To = CurrentVersion
+	}
+}
+
+// Parses the struct and adds default values if empty
+func (l *ListDeletedParameters) AddDefaultsIfEmpty() {
+	if l.From == nil {
+		from := 0
+		l.From = &from
+	}
+	if l.Size == nil {
+		size := 10
+		l.Size = &size
+	}
+}
+
+// Parses the struct and adds default values if empty. A cursor brings back the sort and the
+// time window of the first page, see defaultsTimeWindow.
 func (l *ListParameters) AddDefaultsIfEmpty() {
+	if l.Cursor != nil {
+		l.Sort = l.Cursor.Sort
+		l.TimeStampField = l.Cursor.TimeStampField
+		l.TimeWindow = l.Cursor.TimeWindow
+		l.AsOf = l.Cursor.AsOf
+	}
+	if l.From == nil {
+		from := 0
+		l.From = &from
+	}
+	if l.Size == nil {
+		size := 10
+		l.Size = &size
+	}
+	if len(l.Sort) == 0 && (l.Search != "" || l.Query.fullText()) {
+		l.Sort = append(l.Sort, &database.SortField{
+			SortRelevance: database.Desc,
+		})
+	}
 	if len(l.Sort) == 0 {
 		l.Sort = append(l.Sort, &database.SortField{
 			"updatedAt": database.Desc,
 		})
 	}
-	if l.TimeWindow == nil {
+	l.Sort = relevanceSort(l.Sort)
+	if l.TimeWindow == nil && l.defaultsTimeWindow() {
 		now := time.Now().UTC()
+		// the default window ends at the point in time being listed
+		if asOf, err := time.Parse(time.RFC3339, l.AsOf); err == nil {
+			now = asOf.UTC()
+		}
 		l.TimeWindow = &TimeWindow{
 			Before: now.Format(time.RFC3339),
 			After:  now.AddDate(0, 0, -30).Format(time.RFC3339),
@@ -152,6 +837,19 @@ func (l *ListParameters) AddDefaultsIfEmpty() {
 	if l.TimeStampField == "" {
 		l.TimeStampField = "updatedAt"
 	}
+	if l.PreTag == "" {
+		l.PreTag = defaultHighlightPreTag
+	}
+	if l.PostTag == "" {
+		l.PostTag = defaultHighlightPostTag
+	}
+}
+
+// defaultsTimeWindow tells whether the listing is held to the last 30 days when no time window is given. A search
+// looks through the whole catalogue like FuzzySearchService, and so does a query filtering on the dates, which the
+// default window would otherwise cut short.
+func (l *ListParameters) defaultsTimeWindow() bool {
+	return l.Search == "" && !l.Query.filtersDates()
 }
 
 // Filter on time slice should not be greater than 30 days
@@ -172,28 +870,95 @@ func validateTimeDifference(times any) error {
 }
 
 func (c *CreateServiceCatalogueRequest) Validate() error {
-	return validation.ValidateStruct(c,
+	return validation.ValidateStruct(c, append(c.Ownership.fieldRules(true),
 		validation.Field(&c.Name, validation.Required, validation.Length(4, 20)),
 		validation.Field(&c.Description, validation.Required, validation.Length(20, 200)),
+		validation.Field(&c.Labels, validation.By(validateLabels)),
+		validation.Field(&c.DependsOn, validation.By(validateUniqueDependencies)),
+		validation.Field(&c.Links, validation.By(validateLinks)),
+		validation.Field(&c.Lifecycle, validation.In(initialLifecycles...).Error("must be proposed or experimental")),
+		validation.Field(&c.SunsetAt, validation.Date(time.RFC3339).Error("must be in RFC3339 format")),
 		validation.Field(&c.CreatedBy, validation.Required.Error("missing `x-user-id` header")),
-	)
+	)...)
 }
 
 func (c *UpdateServiceCatalogueRequest) Validate() error {
-	return validation.ValidateStruct(c,
-		validation.Field(&c.Name, validation.When(c.Description == "", validation.Required)),
-		validation.Field(&c.Description, validation.When(c.Name == "", validation.Required)),
+	changesOthers := c.Ownership != Ownership{} || c.Labels != nil || c.Attributes != nil || c.DependsOn != nil || c.Links != nil ||
+		c.Lifecycle != "" || c.SunsetAt != "" || c.ReplacedBy != ""
+	return validation.ValidateStruct(c, append(c.Ownership.fieldRules(false),
+		validation.Field(&c.Name, validation.When(c.Description == "" && !changesOthers, validation.Required)),
+		validation.Field(&c.Description, validation.When(c.Name == "" && !changesOthers, validation.Required)),
+		validation.Field(&c.Labels, validation.By(validateLabels)),
+		validation.Field(&c.DependsOn, validation.By(validateUniqueDependencies)),
+		validation.Field(&c.Links, validation.By(validateLinks)),
+		validation.Field(&c.Lifecycle, validation.In(lifecycles...)),
+		validation.Field(&c.SunsetAt, validation.Date(time.RFC3339).Error("must be in RFC3339 format")),
 		validation.Field(&c.UpdatedBy, validation.Required.Error("missing `x-user-id` header")),
 		validation.Field(&c.ServiceId, validation.Required),
+	)...)
+}
+
+// fieldRules validates the ownership fields of the request embedding it, the owner team is
+// required when creating a service
+func (o *Ownership) fieldRules(requireOwner bool) []*validation.FieldRules {
+	return []*validation.FieldRules{
+		validation.Field(&o.OwnerTeam, validation.When(requireOwner, validation.Required), validation.Length(2, 64)),
+		validation.Field(&o.TechnicalOwner, validation.Length(2, 64)),
+		validation.Field(&o.OnCall, validation.Length(2, 64)),
+		validation.Field(&o.SlackChannel, validation.Match(slackChannelRegex).Error("must be a slack channel like `#payments-oncall`")),
+		validation.Field(&o.Email, validation.Match(emailRegex).Error("must be a valid email address")),
+	}
+}
+
+func (c *CreateTeamRequest) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Name, validation.Required, validation.Length(2, 64)),
+		validation.Field(&c.Description, validation.Length(0, 200)),
+		validation.Field(&c.Members, validation.Each(validation.Required, validation.Length(2, 64))),
+		validation.Field(&c.SlackChannel, validation.Match(slackChannelRegex).Error("must be a slack channel like `#payments-oncall`")),
+		validation.Field(&c.Email, validation.Match(emailRegex).Error("must be a valid email address")),
+		validation.Field(&c.CreatedBy, validation.Required.Error("missing `x-user-id` header")),
+	)
+}
+
+func (c *UpdateTeamRequest) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Name, validation.Length(2, 64)),
+		validation.Field(&c.Description, validation.Length(0, 200)),
+		validation.Field(&c.Members, validation.Each(validation.Required, validation.Length(2, 64))),
+		validation.Field(&c.SlackChannel, validation.Match(slackChannelRegex).Error("must be a slack channel like `#payments-oncall`")),
+		validation.Field(&c.Email, validation.Match(emailRegex).Error("must be a valid email address")),
+		validation.Field(&c.ParentTeamId, validation.NotIn(c.TeamId).Error("a team can not be its own parent")),
+		validation.Field(&c.UpdatedBy, validation.Required.Error("missing `x-user-id` header")),
+		validation.Field(&c.TeamId, validation.Required),
+	)
+}
+
+func (r *RestoreServiceCatalogueVersionRequest) Validate() error {
+	return validation.ValidateStruct(r,
+		validation.Field(&r.ServiceId, validation.Required),
+		validation.Field(&r.VersionId, validation.Required),
+		validation.Field(&r.RestoredBy, validation.Required.Error("missing `x-user-id` header")),
 	)
 }
 
 func (svcReq *CreateServiceCatalogueRequest) RequestStructToServiceStruct(cctx context.CustomContext) *ServiceCatalogue {
 	now := time.Now().UTC().Format(time.RFC3339)
+	lifecycle := svcReq.Lifecycle
+	if lifecycle == "" {
+		lifecycle = LifecycleProposed
+	}
 	return &ServiceCatalogue{
 		ServiceId:   uuid.NewString(),
 		Name:        svcReq.Name,
 		Description: svcReq.Description,
+		Ownership:   svcReq.Ownership,
+		Labels:      svcReq.Labels,
+		Attributes:  svcReq.Attributes,
+		DependsOn:   svcReq.DependsOn,
+		Links:       svcReq.Links,
+		Lifecycle:   lifecycle,
+		Deprecation: Deprecation{SunsetAt: utcTimestamp(svcReq.SunsetAt), ReplacedBy: svcReq.ReplacedBy},
 		Version:     1,
 		CreatedAt:   now,
 		UpdatedAt:   now,
@@ -208,7 +973,46 @@ func (svcReq *UpdateServiceCatalogueRequest) RequestStructToServiceStruct(cctx c
 		ServiceId:   svcReq.ServiceId,
 		Name:        svcReq.Name,
 		Description: svcReq.Description,
+		Ownership:   svcReq.Ownership,
+		Labels:      svcReq.Labels,
+		Attributes:  svcReq.Attributes,
+		DependsOn:   svcReq.DependsOn,
+		Links:       svcReq.Links,
+		Lifecycle:   svcReq.Lifecycle,
+		Deprecation: Deprecation{SunsetAt: utcTimestamp(svcReq.SunsetAt), ReplacedBy: svcReq.ReplacedBy},
 		UpdatedAt:   now,
 		UpdatedBy:   svcReq.UpdatedBy,
 	}
 }
+
+func (teamReq *CreateTeamRequest) RequestStructToTeamStruct(cctx context.CustomContext) *Team {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return &Team{
+		TeamId:       uuid.NewString(),
+		Name:         teamReq.Name,
+		Description:  teamReq.Description,
+		Members:      teamReq.Members,
+		SlackChannel: teamReq.SlackChannel,
+		Email:        teamReq.Email,
+		ParentTeamId: teamReq.ParentTeamId,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		CreatedBy:    teamReq.CreatedBy,
+		UpdatedBy:    teamReq.CreatedBy,
+	}
+}
+
+func (teamReq *UpdateTeamRequest) RequestStructToTeamStruct(cctx context.CustomContext) *Team {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return &Team{
+		TeamId:       teamReq.TeamId,
+		Name:         teamReq.Name,
+		Description:  teamReq.Description,
+		Members:      teamReq.Members,
+		SlackChannel: teamReq.SlackChannel,
+		Email:        teamReq.Email,
+		ParentTeamId: teamReq.ParentTeamId,
+		UpdatedAt:    now,
+		UpdatedBy:    teamReq.UpdatedBy,
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"html"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"strings"
+	"unicode"
+)
+
+var (
+	suggestSource      = []string{"serviceId", database.NameField}
+	defaultSuggestions = 5
+	maxSuggestions     = 10
+	maxSuggestLength   = 100
+)
+
+// Suggest completes the names of the services as they are typed, the best matches first. Every typed word but the
+// last has to be a word of the name and the last one the start of a word, matched on the search_as_you_type sub
+// field of the name. Only the id and the name of the services are fetched and retired services are left out.
+func (svc *Service) Suggest(cctx context.CustomContext, suggestParams *SuggestParameters) ([]*Suggestion, error) {
+	_, mustNot := lifecycleQuery(nil)
+	result, err := svc.search(cctx, &database.Body{
+		Query: &database.Query{
+			Bool: &database.BoolQuery{
+				Must: []database.Query{{
+					MultiMatch: &database.MultiMatch{
+						Fields:   database.NameSuggestFields,
+						Query:    suggestParams.Q,
+						Type:     database.MultiMatchBoolPrefix,
+						Operator: database.OperatorAnd,
+					},
+				}},
+				MustNot: mustNot,
+			},
+		},
+		Size:   suggestParams.Size,
+		Source: suggestSource,
+	}, database.ServiceCatalogueIndex)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return nil, err
+	}
+
+	terms := suggestTerms(suggestParams.Q)
+	suggestions := []*Suggestion{}
+	for _, svcCat := range decodeServiceCatalogueHits(cctx, result.Hits) {
+		suggestions = append(suggestions, &Suggestion{
+			ServiceId: svcCat.ServiceId,
+			Name:      svcCat.Name,
+			Highlight: highlightPrefixes(svcCat.Name, terms),
+		})
+	}
+	return suggestions, nil
+}
+
+// suggestTerms splits the typed text in lower cased words like the standard analyzer
+func suggestTerms(typed string) []string {
+	return strings.FieldsFunc(strings.ToLower(typed), isWordSeparator)
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// highlightPrefixes wraps the start of every word of the name matching a typed word in defaultHighlightPreTag and
+// defaultHighlightPostTag, e.g. `<em>pay</em>ments api` for `pay`. The longest matching typed word is highlighted.
+// The highlight is rendered as html, so the name is html escaped around the tags.
+func highlightPrefixes(name string, terms []string) string {
+	var highlighted strings.Builder
+	runes := []rune(name)
+	for start := 0; start < len(runes); {
+		if isWordSeparator(runes[start]) {
+			highlighted.WriteString(html.EscapeString(string(runes[start])))
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && !isWordSeparator(runes[end]) {
+			end++
+		}
+		word := runes[start:end]
+		matched := 0
+		for _, term := range terms {
+			termRunes := []rune(term)
+			if len(termRunes) > matched && len(termRunes) <= len(word) && strings.ToLower(string(word[:len(termRunes)])) == term {
+				matched = len(termRunes)
+			}
+		}
+		if matched > 0 {
+			highlighted.WriteString(defaultHighlightPreTag + html.EscapeString(string(word[:matched])) + defaultHighlightPostTag)
+		}
+		highlighted.WriteString(html.EscapeString(string(word[matched:])))
+		start = end
+	}
+	return highlighted.String()
+}
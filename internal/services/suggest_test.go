@@ -0,0 +1,54 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHighlightPrefixes(t *testing.T) {
+	assert.Equal(t, "<em>Pay</em>ments gateway", highlightPrefixes("Payments gateway", []string{"pay"}))
+	assert.Equal(t, "<em>payments</em>-<em>gate</em>way", highlightPrefixes("payments-gateway", []string{"pay", "payments", "gate"}))
+	assert.Equal(t, "<em>Zür</em>ich ledger", highlightPrefixes("Zürich ledger", []string{"zür"}))
+	assert.Equal(t, "ledger", highlightPrefixes("ledger", []string{"ledgers"}))
+	assert.Equal(t, `&lt;<em>script</em>&gt;alert(&#34;x&#34;)&lt;/<em>script</em>&gt; &amp; <em>co</em>`,
+		highlightPrefixes(`<script>alert("x")</script> & co`, []string{"script", "co"}), "the name is escaped but the tags are not")
+}
+
+func TestSuggest(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, _ := newTestService(t)
+
+	for _, name := range []string{"payments gateway", "payouts", "ledger"} {
+		_, err := svc.CreateServiceCatalogue(cctx, (&CreateServiceCatalogueRequest{
+			Name: name, Description: "a service to suggest", CreatedBy: "alice",
+		}).RequestStructToServiceStruct(cctx))
+		require.NoError(t, err)
+	}
+
+	suggest := func(q string) []*Suggestion {
+		suggestParams := &SuggestParameters{Q: q}
+		suggestParams.AddDefaultsIfEmpty()
+		require.NoError(t, suggestParams.Validate())
+		suggestions, err := svc.Suggest(cctx, suggestParams)
+		require.NoError(t, err)
+		return suggestions
+	}
+
+	highlights := []string{}
+	for _, suggestion := range suggest("pa") {
+		assert.NotEmpty(t, suggestion.ServiceId)
+		highlights = append(highlights, suggestion.Highlight)
+	}
+	assert.ElementsMatch(t, []string{"<em>pa</em>yments", "<em>pa</em>yments gateway", "<em>pa</em>youts"}, highlights)
+
+	suggestions := suggest("payments ga")
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "payments gateway", suggestions[0].Name)
+	assert.Equal(t, "<em>payments</em> <em>ga</em>teway", suggestions[0].Highlight)
+
+	assert.Empty(t, suggest("ayments"), "only the start of the words is completed")
+	assert.Error(t, (&SuggestParameters{Q: "", Size: &maxSuggestions}).Validate())
+}
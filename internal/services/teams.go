@@ -0,0 +1,267 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/database"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+var keyTeamId = "teamId.keyword"
+var keyParentTeamId = "parentTeamId.keyword"
+var keyName = "name.keyword"
+
+// UnknownTeamErr is returned when a service is owned by a team which does not exist
+var UnknownTeamErr error = fmt.Errorf("UNKNOWN_TEAM")
+
+// OwnerTeamGoneErr is returned when a service is restored to an owner team which was deleted since
+var OwnerTeamGoneErr error = fmt.Errorf("OWNER_TEAM_GONE")
+
+// TeamInUseErr is returned when deleting a team which still owns services or has child teams
+var TeamInUseErr error = fmt.Errorf("TEAM_IN_USE")
+
+// TeamHierarchyCycleErr is returned when a parent team would make a team its own ancestor
+var TeamHierarchyCycleErr error = fmt.Errorf("TEAM_HIERARCHY_CYCLE")
+
+// CreateTeam stores the team using its teamId as document id. The parent team must exist.
+func (svc *Service) CreateTeam(cctx context.CustomContext, team *Team) (*Team, error) {
+	if err := svc.validateParentTeam(cctx, team.TeamId, team.ParentTeamId); err != nil {
+		return nil, err
+	}
+
+	team.OrgId = orgOf(cctx)
+	teamBytes, err := json.Marshal(team)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input: %w", err)
+	}
+	if err := svc.repo.IndexDocument(cctx, teamBytes, database.TeamIndex, team.TeamId); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (svc *Service) FetchTeamById(cctx context.CustomContext, teamId string) (*Team, error) {
+	body := &database.Body{
+		Query: &database.Query{
+			Term: &database.TermQuery{
+				keyTeamId: {
+					Value: teamId,
+				},
+			},
+		},
+	}
+
+	result, err := svc.search(cctx, body, database.TeamIndex)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	teams := decodeTeamHits(cctx, result.Hits)
+	if len(teams) == 0 {
+		return nil, NoDocumentFoundErr
+	}
+	return teams[0], nil
+}
+
+// ListTeams lists the teams sorted by name
+func (svc *Service) ListTeams(cctx context.CustomContext, listParams *ListTeamsParameters) ([]*Team, *Page, error) {
+	body := &database.Body{
+		Sort: []*database.SortField{{keyName: database.Asc}},
+		From: *listParams.From,
+		Size: listParams.Size,
+	}
+
+	hits, page, err := svc.searchPage(cctx, body, database.TeamIndex, keyTeamId, listParams.Cursor, listParams.Pit, Cursor{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeTeamHits(cctx, hits), page, nil
+}
+
+// ListTeamServices lists the services owned by the team sorted by name
+func (svc *Service) ListTeamServices(cctx context.CustomContext, teamId string, listParams *ListTeamsParameters) ([]*ServiceCatalogue, *Page, error) {
+	if _, err := svc.FetchTeamById(cctx, teamId); err != nil {
+		return nil, nil, err
+	}
+
+	body := &database.Body{
+		Query: &database.Query{
+			Term: &database.TermQuery{
+				keyOwnerTeam: {
+					Value: teamId,
+				},
+			},
+		},
+		Sort: []*database.SortField{{keyName: database.Asc}},
+		From: *listParams.From,
+		Size: listParams.Size,
+	}
+
+	hits, page, err := svc.searchPage(cctx, body, database.ServiceCatalogueIndex, keyServiceId, listParams.Cursor, listParams.Pit, Cursor{})
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeServiceCatalogueHits(cctx, hits), page, nil
+}
+
+// UpdateTeam changes the fields set in the input and returns the updated team. Members are replaced
+// when present. The new parent team must exist and must not be the team itself or one of its children.
+func (svc *Service) UpdateTeam(cctx context.CustomContext, input *Team) (*Team, error) {
+	team, err := svc.FetchTeamById(cctx, input.TeamId)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.validateParentTeam(cctx, input.TeamId, input.ParentTeamId); err != nil {
+		return nil, err
+	}
+
+	updateMap, err := structToMap(input)
+	if err != nil {
+		cctx.Logger().DEBUG("error struct to map", tag.NewErrorTag(err))
+		return nil, fmt.Errorf("failed to generate update doc: %w", err)
+	}
+	if input.Members != nil {
+		updateMap["members"] = input.Members
+	}
+	updateBytes, err := json.Marshal(&database.UpdateBody{Doc: updateMap})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input: %w", err)
+	}
+	if err := svc.repo.UpdateDocument(cctx, updateBytes, database.TeamIndex, input.TeamId, nil); err != nil {
+		return nil, err
+	}
+
+	if err := mapstructure.Decode(updateMap, team); err != nil {
+		return nil, fmt.Errorf("failed to decode update: %w", err)
+	}
+	// decoding merges slices element by element, members are replaced as a whole
+	if input.Members != nil {
+		team.Members = input.Members
+	}
+	return team, nil
+}
+
+// DeleteTeam deletes a team which neither owns services nor has child teams
+func (svc *Service) DeleteTeam(cctx context.CustomContext, teamId string) error {
+	if _, err := svc.FetchTeamById(cctx, teamId); err != nil {
+		return err
+	}
+
+	inUse := []struct {
+		index string
+		field string
+	}{
+		{database.ServiceCatalogueIndex, keyOwnerTeam},
+		{database.TeamIndex, keyParentTeamId},
+	}
+	for _, ref := range inUse {
+		result, err := svc.search(cctx, &database.Body{
+			Query: &database.Query{Term: &database.TermQuery{ref.field: {Value: teamId}}},
+			Size:  database.HitsSize(1),
+		}, ref.index)
+		if err != nil {
+			cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+			return fmt.Errorf("failed to search: %w", err)
+		}
+		if len(result.Hits) > 0 {
+			return fmt.Errorf("%w: referenced in %s", TeamInUseErr, ref.index)
+		}
+	}
+
+	return svc.repo.DeleteDocument(cctx, database.TeamIndex, teamId, nil)
+}
+
+// validateOwnerTeam checks the team owning a service exists
+func (svc *Service) validateOwnerTeam(cctx context.CustomContext, ownership Ownership) error {
+	if ownership.OwnerTeam == "" {
+		return nil
+	}
+	_, err := svc.FetchTeamById(cctx, ownership.OwnerTeam)
+	if errors.Is(err, NoDocumentFoundErr) {
+		return fmt.Errorf("%w: %s", UnknownTeamErr, ownership.OwnerTeam)
+	}
+	return err
+}
+
+// restoredOwnership is the ownership a service is restored with. The service is re-assigned to the owner team when
+// one is given, otherwise the team owning the service must still exist.
+func (svc *Service) restoredOwnership(cctx context.CustomContext, ownership Ownership, ownerTeam string) (Ownership, error) {
+	if ownerTeam != "" {
+		ownership.OwnerTeam = ownerTeam
+		return ownership, nil
+	}
+	err := svc.validateOwnerTeam(cctx, ownership)
+	if errors.Is(err, UnknownTeamErr) {
+		return ownership, fmt.Errorf("%w: team %s owning the service was deleted, restore it with an `ownerTeam` to re-assign it", OwnerTeamGoneErr, ownership.OwnerTeam)
+	}
+	return ownership, err
+}
+
+// validateParentTeam checks the parent team exists and walks up its ancestors to make sure the team
+// does not become its own ancestor
+func (svc *Service) validateParentTeam(cctx context.CustomContext, teamId string, parentTeamId string) error {
+	seen := map[string]bool{}
+	for parentTeamId != "" {
+		if parentTeamId == teamId || seen[parentTeamId] {
+			return TeamHierarchyCycleErr
+		}
+		seen[parentTeamId] = true
+
+		parent, err := svc.FetchTeamById(cctx, parentTeamId)
+		if errors.Is(err, NoDocumentFoundErr) {
+			return fmt.Errorf("%w: %s", UnknownTeamErr, parentTeamId)
+		}
+		if err != nil {
+			return err
+		}
+		parentTeamId = parent.ParentTeamId
+	}
+	return nil
+}
+
+// teamNames resolves the ids of the teams to their names, teams which do not exist anymore are left out
+func (svc *Service) teamNames(cctx context.CustomContext, teamIds []string) (map[string]string, error) {
+	names := map[string]string{}
+	if len(teamIds) == 0 {
+		return names, nil
+	}
+	values := make([]any, 0, len(teamIds))
+	for _, teamId := range teamIds {
+		values = append(values, teamId)
+	}
+	result, err := svc.search(cctx, &database.Body{
+		Query: &database.Query{Terms: &database.TermsQuery{keyTeamId: values}},
+		Size:  database.HitsSize(len(teamIds)),
+	}, database.TeamIndex)
+	if err != nil {
+		cctx.Logger().DEBUG("failed to search", tag.NewErrorTag(err))
+		return nil, err
+	}
+	for _, team := range decodeTeamHits(cctx, result.Hits) {
+		names[team.TeamId] = team.Name
+	}
+	return names, nil
+}
+
+// decodeTeamHits fetches _source from hits and tranforms to team list
+func decodeTeamHits(cctx context.CustomContext, hits []any) []*Team {
+	teams := []*Team{}
+	for _, hit := range hits {
+		hitMap, ok := hit.(map[string]any)
+		if !ok {
+			cctx.Logger().DEBUG("failed to parse hit", tag.NewAnyTag("hit", hit))
+			continue
+		}
+		var team Team
+		if err := mapstructure.Decode(hitMap["_source"], &team); err != nil {
+			cctx.Logger().DEBUG("failed to decode hit", tag.NewErrorTag(err))
+			continue
+		}
+		teams = append(teams, &team)
+	}
+	return teams
+}
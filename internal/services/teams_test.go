@@ -0,0 +1,83 @@
+package services
+
+import (
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeams(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	svc, _, svcCat := newTestService(t)
+	platform := newTestTeam(t, svc, "platform")
+
+	payments, err := svc.CreateTeam(cctx, (&CreateTeamRequest{
+		Name: "payments", Members: []string{"alice", "bob"}, ParentTeamId: platform.TeamId, CreatedBy: "alice",
+	}).RequestStructToTeamStruct(cctx))
+	require.NoError(t, err)
+
+	t.Run("parent team must exist", func(t *testing.T) {
+		_, err := svc.CreateTeam(cctx, (&CreateTeamRequest{Name: "orphans", ParentTeamId: "missing", CreatedBy: "alice"}).RequestStructToTeamStruct(cctx))
+		assert.ErrorIs(t, err, UnknownTeamErr)
+	})
+
+	t.Run("a team can not become its own ancestor", func(t *testing.T) {
+		_, err := svc.UpdateTeam(cctx, &Team{TeamId: platform.TeamId, ParentTeamId: payments.TeamId, UpdatedBy: "bob"})
+		assert.ErrorIs(t, err, TeamHierarchyCycleErr)
+	})
+
+	t.Run("update replaces members and keeps the other fields", func(t *testing.T) {
+		updated, err := svc.UpdateTeam(cctx, &Team{TeamId: payments.TeamId, Members: []string{"carol"}, UpdatedBy: "bob"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"carol"}, updated.Members)
+		assert.Equal(t, "payments", updated.Name)
+
+		fetched, err := svc.FetchTeamById(cctx, payments.TeamId)
+		require.NoError(t, err)
+		assert.Equal(t, updated, fetched)
+	})
+
+	t.Run("services must be owned by an existing team", func(t *testing.T) {
+		_, err := svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: svcCat.ServiceId, Ownership: Ownership{OwnerTeam: "missing"}, UpdatedBy: "bob",
+		}, nil)
+		assert.ErrorIs(t, err, UnknownTeamErr)
+
+		_, err = svc.UpdateServiceCatalogue(cctx, &ServiceCatalogue{
+			ServiceId: svcCat.ServiceId, Ownership: Ownership{OwnerTeam: payments.TeamId}, UpdatedBy: "bob",
+		}, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("lists the services of a team", func(t *testing.T) {
+		listParams := &ListTeamsParameters{}
+		listParams.AddDefaultsIfEmpty()
+		svcCats, page, err := svc.ListTeamServices(cctx, payments.TeamId, listParams)
+		require.NoError(t, err)
+		require.Len(t, svcCats, 1)
+		assert.Equal(t, svcCat.ServiceId, svcCats[0].ServiceId)
+		assert.Equal(t, 1, page.Total)
+
+		_, _, err = svc.ListTeamServices(cctx, "missing", listParams)
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+
+		teams, _, err := svc.ListTeams(cctx, listParams)
+		require.NoError(t, err)
+		require.Len(t, teams, 2)
+		assert.Equal(t, "payments", teams[0].Name)
+		assert.Equal(t, "platform", teams[1].Name)
+	})
+
+	t.Run("teams in use can not be deleted", func(t *testing.T) {
+		assert.ErrorIs(t, svc.DeleteTeam(cctx, platform.TeamId), TeamInUseErr)
+		assert.ErrorIs(t, svc.DeleteTeam(cctx, payments.TeamId), TeamInUseErr)
+
+		require.NoError(t, svc.DeleteService(cctx, svcCat.ServiceId, "bob", nil))
+		require.NoError(t, svc.DeleteTeam(cctx, payments.TeamId))
+		require.NoError(t, svc.DeleteTeam(cctx, platform.TeamId))
+		_, err := svc.FetchTeamById(cctx, payments.TeamId)
+		assert.ErrorIs(t, err, NoDocumentFoundErr)
+	})
+}
@@ -3,6 +3,7 @@ package context
 import (
 	"nikki-noceps/serviceCatalogue/pkg/logger"
 	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"slices"
 	"sync"
 	"time"
 
@@ -13,6 +14,8 @@ import (
 const (
 	keyRequestID = "requestID"
 	keyUserID    = "userID"
+	keyOrgID     = "orgID"
+	keyRoles     = "roles"
 	keyTraceID   = "traceID"
 	keyLogger    = "logger"
 )
@@ -23,6 +26,8 @@ const (
 type CustomContextConfig struct {
 	RequestID string
 	TraceID   string
+	OrgID     string
+	Roles     []string
 	Logger    logger.Logger
 	Ctx       context.Context
 }
@@ -71,6 +76,12 @@ func NewCustomContext(cfg *CustomContextConfig) CustomContext {
 	if cfg.RequestID != "" {
 		rctx = context.WithValue(rctx, keyRequestID, cfg.RequestID)
 	}
+	if cfg.OrgID != "" {
+		rctx = context.WithValue(rctx, keyOrgID, cfg.OrgID)
+	}
+	if len(cfg.Roles) > 0 {
+		rctx = context.WithValue(rctx, keyRoles, cfg.Roles)
+	}
 
 	cctx := CustomContext{
 		ctx:  rctx,
@@ -87,6 +98,23 @@ func (b CustomContext) UserID() string {
 	return id
 }
 
+// OrgID returns the organization of the authenticated principal otherwise returns "".
+func (b CustomContext) OrgID() string {
+	id, _ := b.ctx.Value(keyOrgID).(string)
+	return id
+}
+
+// Roles returns the roles of the authenticated principal otherwise returns nil.
+func (b CustomContext) Roles() []string {
+	roles, _ := b.ctx.Value(keyRoles).([]string)
+	return roles
+}
+
+// HasRole tells whether the authenticated principal has the role.
+func (b CustomContext) HasRole(role string) bool {
+	return slices.Contains(b.Roles(), role)
+}
+
 // RequestID returns RequestID associated with the request otherwise returns "".
 func (b CustomContext) RequestID() string {
 	id, _ := b.ctx.Value(keyRequestID).(string)
@@ -139,6 +167,8 @@ func (b CustomContext) WithContext(ctx context.Context) CustomContext {
 	return NewCustomContext(&CustomContextConfig{
 		RequestID: b.RequestID(),
 		TraceID:   b.TraceID(),
+		OrgID:     b.OrgID(),
+		Roles:     b.Roles(),
 		Logger:    b.Logger(),
 		Ctx:       ctx,
 	})
@@ -222,3 +252,13 @@ func WithTimeout(parent CustomContext, timeout time.Duration) (CustomContext, co
 	parent.ctx = ctx
 	return parent, cancelFunc
 }
+
+// WithOrgID returns a copy of parent scoped to the organization
+func WithOrgID(parent CustomContext, orgID string) CustomContext {
+	return WithValue(parent, keyOrgID, orgID)
+}
+
+// WithRoles returns a copy of parent with the roles of the authenticated principal
+func WithRoles(parent CustomContext, roles []string) CustomContext {
+	return WithValue(parent, keyRoles, roles)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"nikki-noceps/serviceCatalogue/config"
 	"nikki-noceps/serviceCatalogue/pkg/context"
 	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
@@ -53,6 +54,10 @@ func (es *ESClient) searchRequest(cctx context.CustomContext, query *Body, index
 		Index: []string{index},
 		Body:  bytes.NewReader(queryBytes),
 	}
+	// a point in time already targets the index it was opened on
+	if query.Pit != nil {
+		req.Index = nil
+	}
 	res, err := req.Do(cctx, es.client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute es request: %w", err)
@@ -60,7 +65,7 @@ func (es *ESClient) searchRequest(cctx context.CustomContext, query *Body, index
 	return res, nil
 }
 
-func (es *ESClient) handleSearchResponse(cctx context.CustomContext, res *esapi.Response) ([]any, error) {
+func (es *ESClient) handleSearchResponse(cctx context.CustomContext, res *esapi.Response) (*SearchResult, error) {
 	defer res.Body.Close()
 
 	if res.IsError() {
@@ -77,30 +82,102 @@ func (es *ESClient) handleSearchResponse(cctx context.CustomContext, res *esapi.
 		return nil, fmt.Errorf("error parsing the response body: %w", err)
 	}
 
-	hits, ok := r["hits"].(map[string]interface{})["hits"].([]any)
+	hitsMap, ok := r["hits"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to parse hits in es response")
+	}
+	hits, ok := hitsMap["hits"].([]any)
 	if !ok {
 		return nil, fmt.Errorf("failed to parse hits in es response")
 	}
 
-	return hits, nil
+	result := &SearchResult{Hits: hits, TotalRelation: TotalRelationEqual}
+	if total, ok := hitsMap["total"].(map[string]any); ok {
+		if value, ok := toFloat(total["value"]); ok {
+			result.Total = int(value)
+		}
+		if relation, ok := total["relation"].(string); ok {
+			result.TotalRelation = relation
+		}
+	}
+	if aggregations, ok := r["aggregations"]; ok {
+		encoded, err := json.Marshal(aggregations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse aggregations in es response: %w", err)
+		}
+		if err := json.Unmarshal(encoded, &result.Aggregations); err != nil {
+			return nil, fmt.Errorf("failed to parse aggregations in es response: %w", err)
+		}
+	}
+	return result, nil
 }
 
-func (es *ESClient) SearchAndGetHits(cctx context.CustomContext, query *Body, index string) ([]any, error) {
+func (es *ESClient) SearchAndGetHits(cctx context.CustomContext, query *Body, index string) (*SearchResult, error) {
 	res, err := es.searchRequest(cctx, query, index)
 	if err != nil {
 		return nil, err
 	}
 
-	hits, err := es.handleSearchResponse(cctx, res)
+	result, err := es.handleSearchResponse(cctx, res)
 	if err != nil {
 		return nil, err
 	}
-	return hits, nil
+	return result, nil
+}
+
+// OpenPointInTime opens a point in time on the index so paging through search_after sees consistent data
+func (es *ESClient) OpenPointInTime(cctx context.CustomContext, index string, keepAlive string) (string, error) {
+	req := esapi.OpenPointInTimeRequest{
+		Index:     []string{index},
+		KeepAlive: keepAlive,
+	}
+	res, err := req.Do(cctx, es.client)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute es request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		var e map[string]any
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return "", fmt.Errorf("error parsing the response body: %w", err)
+		}
+		return "", fmt.Errorf("open point in time failed, got [%s] status code %v", res.Status(), e)
+	}
+
+	var r map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("error parsing the response body: %w", err)
+	}
+	pitId, _ := r["id"].(string)
+	return pitId, nil
+}
+
+// PutMapping adds the field mappings of the body to the index. Changing the type of a mapped field fails.
+func (es *ESClient) PutMapping(cctx context.CustomContext, index string, mapping []byte) error {
+	req := esapi.IndicesPutMappingRequest{
+		Index: []string{index},
+		Body:  bytes.NewReader(mapping),
+	}
+	res, err := req.Do(cctx, es.client)
+	if err != nil {
+		return fmt.Errorf("failed to execute es request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		var e map[string]any
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return fmt.Errorf("error parsing the response body: %w", err)
+		}
+		return fmt.Errorf("put mapping failed, got [%s] status code %v", res.Status(), e)
+	}
+	return nil
 }
 
 // CreateDocument takes in bytes of body to create document in index provided
-// Returns create respones and error if any
-func (es *ESClient) CreateDocument(cctx context.CustomContext, docBytes []byte, index string) (*esapi.Response, error) {
+// Returns the generated document id and error if any
+func (es *ESClient) CreateDocument(cctx context.CustomContext, docBytes []byte, index string) (string, error) {
 	req := esapi.IndexRequest{
 		Index: index,
 		Body:  bytes.NewReader(docBytes),
@@ -109,48 +186,96 @@ func (es *ESClient) CreateDocument(cctx context.CustomContext, docBytes []byte,
 	// Execute the request
 	res, err := req.Do(cctx, es.client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute es request: %w", err)
+		return "", fmt.Errorf("failed to execute es request: %w", err)
 	}
 
+	defer res.Body.Close()
+
 	// Handle the response
 	if res.IsError() {
 		var e map[string]any
 		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			return nil, fmt.Errorf("error parsing the response body: %w", err)
+			return "", fmt.Errorf("error parsing the response body: %w", err)
 		}
-		return nil, fmt.Errorf("create failed, got [%s] status code %v", res.Status(), e)
+		return "", fmt.Errorf("create failed, got [%s] status code %v", res.Status(), e)
 	}
 
+	var r map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("error parsing the response body: %w", err)
+	}
+	docId, _ := r["_id"].(string)
+
+	return docId, nil
+}
+
+// IndexDocument creates or replaces the document with the id provided. Writing the same document
+// again is a no-op which makes it safe to retry.
+func (es *ESClient) IndexDocument(cctx context.CustomContext, docBytes []byte, index string, docId string) error {
+	req := esapi.IndexRequest{
+		Index:      index,
+		DocumentID: docId,
+		Body:       bytes.NewReader(docBytes),
+	}
+
+	res, err := req.Do(cctx, es.client)
+	if err != nil {
+		return fmt.Errorf("failed to execute es request: %w", err)
+	}
 	defer res.Body.Close()
 
-	return res, nil
+	if res.IsError() {
+		var e map[string]any
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return fmt.Errorf("error parsing the response body: %w", err)
+		}
+		return fmt.Errorf("index failed, got [%s] status code %v", res.Status(), e)
+	}
+	return nil
 }
 
 // UpdateDocument takes in bytes to be replaced for the documentId provided. It only updates parts of the document given in inputs
-// Does not update rest of the fields which are not provided.
-func (es *ESClient) UpdateDocument(cctx context.CustomContext, docBytes []byte, index string, docId string) (*esapi.Response, error) {
-	res, err := es.client.Update(index, docId, bytes.NewReader(docBytes), es.client.Update.WithContext(cctx))
+// Does not update rest of the fields which are not provided. When a revision is provided the update only goes through if the
+// document is still at that revision, otherwise VersionConflictErr is returned.
+func (es *ESClient) UpdateDocument(cctx context.CustomContext, docBytes []byte, index string, docId string, revision *Revision) error {
+	opts := []func(*esapi.UpdateRequest){es.client.Update.WithContext(cctx)}
+	if revision != nil {
+		opts = append(opts, es.client.Update.WithIfSeqNo(revision.SeqNo), es.client.Update.WithIfPrimaryTerm(revision.PrimaryTerm))
+	}
+	res, err := es.client.Update(index, docId, bytes.NewReader(docBytes), opts...)
 	if err != nil {
 		cctx.Logger().DEBUG("failed to update document", tag.NewErrorTag(err))
-		return nil, fmt.Errorf("failed to update document: %w", err)
+		return fmt.Errorf("failed to update document: %w", err)
 	}
 
 	defer res.Body.Close()
 
 	if res.IsError() {
 		cctx.Logger().DEBUG("updated failed", tag.NewAnyTag("res", res.String()))
+		if res.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("update failed, document [%s] missing in index [%s]: %w", docId, index, DocumentMissingErr)
+		}
+		if res.StatusCode == http.StatusConflict {
+			return fmt.Errorf("update failed, document [%s] changed in index [%s]: %w", docId, index, VersionConflictErr)
+		}
 		var e map[string]any
 		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			return nil, fmt.Errorf("error parsing the response body: %w", err)
+			return fmt.Errorf("error parsing the response body: %w", err)
 		}
-		return nil, fmt.Errorf("update failed, got [%s] status code %v", res.Status(), e)
+		return fmt.Errorf("update failed, got [%s] status code %v", res.Status(), e)
 	}
 
-	return res, nil
+	return nil
 }
 
-func (es *ESClient) DeleteDocument(cctx context.CustomContext, index string, docId string) error {
-	res, err := es.client.Delete(index, docId)
+// DeleteDocument deletes the documentId provided. When a revision is provided the delete only goes through if the
+// document is still at that revision, otherwise VersionConflictErr is returned.
+func (es *ESClient) DeleteDocument(cctx context.CustomContext, index string, docId string, revision *Revision) error {
+	opts := []func(*esapi.DeleteRequest){es.client.Delete.WithContext(cctx)}
+	if revision != nil {
+		opts = append(opts, es.client.Delete.WithIfSeqNo(revision.SeqNo), es.client.Delete.WithIfPrimaryTerm(revision.PrimaryTerm))
+	}
+	res, err := es.client.Delete(index, docId, opts...)
 	if err != nil {
 		cctx.Logger().DEBUG("failed to delete document", tag.NewErrorTag(err))
 		return fmt.Errorf("failed to delete document: %w", err)
@@ -158,6 +283,12 @@ func (es *ESClient) DeleteDocument(cctx context.CustomContext, index string, doc
 	defer res.Body.Close()
 	if res.IsError() {
 		cctx.Logger().DEBUG("delete failed", tag.NewAnyTag("res", res.String()))
+		if res.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("delete failed, document [%s] missing in index [%s]: %w", docId, index, DocumentMissingErr)
+		}
+		if res.StatusCode == http.StatusConflict {
+			return fmt.Errorf("delete failed, document [%s] changed in index [%s]: %w", docId, index, VersionConflictErr)
+		}
 		var e map[string]any
 		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
 			return fmt.Errorf("error parsing the response body: %w", err)
@@ -0,0 +1,947 @@
+package database
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// defaultSize mirrors elasticsearch which returns 10 hits when size is not set
+const defaultSize = 10
+
+// the defaults of the elasticsearch highlighter
+const (
+	defaultPreTag            = "<em>"
+	defaultPostTag           = "</em>"
+	defaultFragmentSize      = 100
+	defaultNumberOfFragments = 5
+)
+
+// document is a stored _source along with its id. seq keeps the insertion order which is
+// used as the final tie breaker while sorting, similar to _doc order in elasticsearch.
+type document struct {
+	id          string
+	seq         int64
+	seqNo       int
+	primaryTerm int
+	source      map[string]any
+	score       float64
+}
+
+// fullTextScorer scores a multi_match query for a document, zero meaning the document does not match.
+// Backends with their own full text index plug it in instead of the default fuzzy matching.
+type fullTextScorer func(mm *MultiMatch, doc *document) float64
+
+// fuzzyMultiMatch is the default fullTextScorer
+func fuzzyMultiMatch(mm *MultiMatch, doc *document) float64 {
+	switch mm.Type {
+	case MultiMatchBoolPrefix:
+		return boolPrefixScore(mm, doc.source)
+	case MultiMatchPhrase:
+		return phraseScore(mm, doc.source)
+	}
+	return fuzzyScore(mm.Fields, mm.Query, mm.Fuzziness, doc.source)
+}
+
+// searchDocuments evaluates the Body against docs in go. It is the query engine for backends
+// which do not understand the elasticsearch dsl natively. Supports filtering, sorting, fuzzy
+// search, from/size and search_after pagination. Returns hits in the elasticsearch shape.
+func searchDocuments(index string, docs []*document, body *Body, scorer fullTextScorer) (*SearchResult, error) {
+	if body == nil {
+		body = &Body{}
+	}
+	if scorer == nil {
+		scorer = fuzzyMultiMatch
+	}
+
+	matched := []*document{}
+	for _, doc := range docs {
+		ok, score, err := evaluateQuery(body.Query, doc, scorer)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			doc.score = score
+			matched = append(matched, doc)
+		}
+	}
+
+	keys := sortKeys(body.Sort)
+	sortDocuments(matched, keys)
+	// like elasticsearch the total and the aggregations ignore pagination
+	total := len(matched)
+	aggregations, err := evaluateAggregations(body.Aggs, matched)
+	if err != nil {
+		return nil, err
+	}
+	if len(body.SearchAfter) > 0 {
+		after := []*document{}
+		for _, doc := range matched {
+			if compareSortKeys(sortValues(doc, keys), body.SearchAfter, keys) > 0 {
+				after = append(after, doc)
+			}
+		}
+		matched = after
+	}
+
+	size := defaultSize
+	if body.Size != nil {
+		size = *body.Size
+	}
+	from := min(body.From, len(matched))
+	to := min(from+size, len(matched))
+
+	hits := []any{}
+	for _, doc := range matched[from:to] {
+		hit := map[string]any{
+			"_index":  index,
+			"_id":     doc.id,
+			"_score":  doc.score,
+			"_source": filterSource(doc.source, body.Source),
+		}
+		if body.SeqNoPrimaryTerm {
+			hit["_seq_no"] = doc.seqNo
+			hit["_primary_term"] = doc.primaryTerm
+		}
+		if len(body.Sort) > 0 {
+			hit["sort"] = sortValues(doc, keys)
+		}
+		if body.Highlight != nil {
+			if highlighted := highlightDocument(body.Highlight, body.Query, doc.source); len(highlighted) > 0 {
+				hit["highlight"] = highlighted
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return &SearchResult{Hits: hits, Total: total, TotalRelation: TotalRelationEqual, Aggregations: aggregations}, nil
+}
+
+// highlightDocument highlights the words of the fields matching a full text query on them, in fragments of
+// about the fragment size. Like elasticsearch only the fragments and the fields with a match are returned.
+func highlightDocument(highlight *Highlight, query *Query, source map[string]any) map[string]any {
+	encode := func(text string) string { return text }
+	if strings.EqualFold(highlight.Encoder, HighlightEncoderHTML) {
+		encode = html.EscapeString
+	}
+	preTag, postTag := defaultPreTag, defaultPostTag
+	if len(highlight.PreTags) > 0 {
+		preTag = highlight.PreTags[0]
+	}
+	if len(highlight.PostTags) > 0 {
+		postTag = highlight.PostTags[0]
+	}
+
+	highlighted := map[string]any{}
+	for field, options := range highlight.Fields {
+		matches := highlightMatcher(query, field)
+		if matches == nil {
+			continue
+		}
+		fragmentSize, numberOfFragments := defaultFragmentSize, defaultNumberOfFragments
+		if options != nil && options.FragmentSize > 0 {
+			fragmentSize = options.FragmentSize
+		}
+		if options != nil && options.NumberOfFragments > 0 {
+			numberOfFragments = options.NumberOfFragments
+		}
+
+		fragments := []any{}
+		for _, value := range fieldValues(source, field) {
+			text, ok := value.(string)
+			if !ok {
+				continue
+			}
+			for _, fragment := range highlightFragments(text, matches, encode, preTag, postTag, fragmentSize) {
+				if len(fragments) < numberOfFragments {
+					fragments = append(fragments, fragment)
+				}
+			}
+		}
+		if len(fragments) > 0 {
+			highlighted[field] = fragments
+		}
+	}
+	return highlighted
+}
+
+// highlightMatcher reports whether an analyzed word matches a term of the multi_match queries on the field,
+// it is nil when no query is on the field
+func highlightMatcher(query *Query, field string) func(word string) bool {
+	matchers := []func(word string) bool{}
+	walkQuery(query, func(q *Query) {
+		mm := q.MultiMatch
+		if mm == nil || !slices.ContainsFunc(mm.Fields, func(f string) bool { return sourceField(f) == sourceField(field) }) {
+			return
+		}
+		terms := analyze(mm.Query)
+		for i, term := range terms {
+			prefix := mm.Type == MultiMatchBoolPrefix && i == len(terms)-1
+			matchers = append(matchers, func(word string) bool {
+				if prefix {
+					return strings.HasPrefix(word, term)
+				}
+				return fuzzyMatch(term, word, mm.Fuzziness)
+			})
+		}
+	})
+	if len(matchers) == 0 {
+		return nil
+	}
+	return func(word string) bool {
+		return slices.ContainsFunc(matchers, func(matches func(string) bool) bool { return matches(word) })
+	}
+}
+
+// highlightFragments cuts the text in fragments of about the fragment size at word boundaries and wraps the
+// matching words in the tags. The text is encoded, the tags are not. Only the fragments with a match are returned.
+func highlightFragments(text string, matches func(word string) bool, encode func(string) string, preTag, postTag string, fragmentSize int) []string {
+	fragments := []string{}
+	var fragment strings.Builder
+	length, highlighted := 0, false
+	flush := func() {
+		if highlighted {
+			fragments = append(fragments, strings.TrimSpace(fragment.String()))
+		}
+		fragment.Reset()
+		length, highlighted = 0, false
+	}
+
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		isWord := !isSeparator(runes[start])
+		for isWord && end < len(runes) && !isSeparator(runes[end]) {
+			end++
+		}
+		token := string(runes[start:end])
+		if isWord && length >= fragmentSize {
+			flush()
+		}
+		if isWord && matches(strings.ToLower(token)) {
+			fragment.WriteString(preTag + encode(token) + postTag)
+			highlighted = true
+		} else {
+			fragment.WriteString(encode(token))
+		}
+		length += end - start
+		start = end
+	}
+	flush()
+	return fragments
+}
+
+// filterSource keeps the fields of the source, all of them when there are none
+func filterSource(source map[string]any, fields []string) map[string]any {
+	if len(fields) == 0 {
+		return source
+	}
+	filtered := map[string]any{}
+	for _, field := range fields {
+		if value, ok := source[field]; ok {
+			filtered[field] = value
+		}
+	}
+	return filtered
+}
+
+// evaluateAggregations computes the aggregations over the matched documents
+func evaluateAggregations(aggs map[string]*Aggregation, docs []*document) (map[string]*AggregationResult, error) {
+	if len(aggs) == 0 {
+		return nil, nil
+	}
+	results := map[string]*AggregationResult{}
+	for name, agg := range aggs {
+		switch {
+		case agg != nil && agg.Terms != nil:
+			results[name] = evaluateTermsAggregation(agg.Terms, docs)
+		case agg != nil && agg.DateHistogram != nil:
+			result, err := evaluateDateHistogram(agg.DateHistogram, docs)
+			if err != nil {
+				return nil, err
+			}
+			results[name] = result
+		default:
+			return nil, fmt.Errorf("aggregation %q is not supported by this backend", name)
+		}
+	}
+	return results, nil
+}
+
+// evaluateTermsAggregation counts every document once per distinct value of the field. Like elasticsearch
+// the buckets are sorted on the count, ties broken on the value.
+func evaluateTermsAggregation(terms *TermsAggregation, docs []*document) *AggregationResult {
+	counts := map[string]int{}
+	for _, doc := range docs {
+		seen := map[string]bool{}
+		for _, value := range fieldValues(doc.source, terms.Field) {
+			seen[fmt.Sprint(value)] = true
+		}
+		if len(seen) == 0 && terms.Missing != "" {
+			seen[terms.Missing] = true
+		}
+		for key := range seen {
+			counts[key]++
+		}
+	}
+
+	buckets := []*Bucket{}
+	for key, count := range counts {
+		buckets = append(buckets, &Bucket{Key: key, DocCount: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].DocCount != buckets[j].DocCount {
+			return buckets[i].DocCount > buckets[j].DocCount
+		}
+		return buckets[i].Key.(string) < buckets[j].Key.(string)
+	})
+	size := terms.Size
+	if size == 0 {
+		size = defaultSize
+	}
+	return &AggregationResult{Buckets: buckets[:min(size, len(buckets))]}
+}
+
+// evaluateDateHistogram counts the documents per calendar interval of the RFC3339 timestamps of the field.
+// Like elasticsearch empty intervals between the first and the last one are returned with a zero count.
+func evaluateDateHistogram(histogram *DateHistogramAggregation, docs []*document) (*AggregationResult, error) {
+	var truncate func(t time.Time) time.Time
+	var next func(t time.Time) time.Time
+	switch histogram.CalendarInterval {
+	case CalendarDay:
+		truncate = func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC) }
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case CalendarMonth:
+		truncate = func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC) }
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	case CalendarYear:
+		truncate = func(t time.Time) time.Time { return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC) }
+		next = func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+	default:
+		return nil, fmt.Errorf("calendar interval %q is not supported by this backend", histogram.CalendarInterval)
+	}
+
+	counts := map[time.Time]int{}
+	var first, last time.Time
+	for _, doc := range docs {
+		seen := map[time.Time]bool{}
+		for _, value := range fieldValues(doc.source, histogram.Field) {
+			s, _ := value.(string)
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				continue
+			}
+			start := truncate(t.UTC())
+			if seen[start] {
+				continue
+			}
+			seen[start] = true
+			if len(counts) == 0 || start.Before(first) {
+				first = start
+			}
+			if len(counts) == 0 || start.After(last) {
+				last = start
+			}
+			counts[start]++
+		}
+	}
+
+	buckets := []*Bucket{}
+	if len(counts) == 0 {
+		return &AggregationResult{Buckets: buckets}, nil
+	}
+	for start := first; !start.After(last); start = next(start) {
+		buckets = append(buckets, &Bucket{
+			Key:         float64(start.UnixMilli()),
+			KeyAsString: start.Format("2006-01-02T15:04:05.000Z"),
+			DocCount:    counts[start],
+		})
+	}
+	return &AggregationResult{Buckets: buckets}, nil
+}
+
+// evaluateQuery reports whether the source matches the query along with a relevance score.
+// A nil or empty query matches every document like match_all.
+func evaluateQuery(q *Query, doc *document, scorer fullTextScorer) (bool, float64, error) {
+	source := doc.source
+	if q == nil {
+		return true, 1, nil
+	}
+
+	matched, score := true, 0.0
+	clauses := 0
+	apply := func(ok bool, s float64) {
+		clauses++
+		matched = matched && ok
+		score += s
+	}
+
+	if q.Script != nil {
+		return false, 0, fmt.Errorf("script queries are not supported by this backend")
+	}
+	if q.Term != nil {
+		apply(evaluateTerm(*q.Term, source), 1)
+	}
+	if q.Terms != nil {
+		apply(evaluateTerms(*q.Terms, source), 1)
+	}
+	if q.Exists != nil {
+		apply(len(fieldValues(source, q.Exists.Field)) > 0, 1)
+	}
+	if q.Range != nil {
+		apply(evaluateRange(*q.Range, source), 1)
+	}
+	if q.Match != nil {
+		s := fuzzyScore([]string{q.Match.Field}, q.Match.Value, "", source)
+		apply(s > 0, s)
+	}
+	if q.MultiMatch != nil {
+		s := scorer(q.MultiMatch, doc)
+		apply(s > 0, s)
+	}
+	if q.Bool != nil {
+		ok, s, err := evaluateBool(q.Bool, doc, scorer)
+		if err != nil {
+			return false, 0, err
+		}
+		apply(ok, s)
+	}
+	if q.Nested != nil {
+		ok, s, err := evaluateNested(q.Nested, doc, scorer)
+		if err != nil {
+			return false, 0, err
+		}
+		apply(ok, s)
+	}
+	if q.FunctionScore != nil {
+		ok, s, err := evaluateQuery(&q.FunctionScore.Query, doc, scorer)
+		if err != nil {
+			return false, 0, err
+		}
+		for _, fn := range q.FunctionScore.Functions {
+			fnOk, _, err := evaluateQuery(&fn.Filter, doc, scorer)
+			if err != nil {
+				return false, 0, err
+			}
+			if fnOk {
+				s *= fn.Weight
+			}
+		}
+		apply(ok, s)
+	}
+
+	if clauses == 0 {
+		return true, 1, nil
+	}
+	if !matched {
+		return false, 0, nil
+	}
+	return true, score, nil
+}
+
+func evaluateBool(b *BoolQuery, doc *document, scorer fullTextScorer) (bool, float64, error) {
+	score := 0.0
+	for i := range b.Must {
+		ok, s, err := evaluateQuery(&b.Must[i], doc, scorer)
+		if err != nil || !ok {
+			return false, 0, err
+		}
+		score += s
+	}
+	for i := range b.Filter {
+		ok, _, err := evaluateQuery(&b.Filter[i], doc, scorer)
+		if err != nil || !ok {
+			return false, 0, err
+		}
+	}
+	for i := range b.MustNot {
+		ok, _, err := evaluateQuery(&b.MustNot[i], doc, scorer)
+		if err != nil || ok {
+			return false, 0, err
+		}
+	}
+	shouldMatched := 0
+	for i := range b.Should {
+		ok, s, err := evaluateQuery(&b.Should[i], doc, scorer)
+		if err != nil {
+			return false, 0, err
+		}
+		if ok {
+			shouldMatched++
+			score += s
+		}
+	}
+	// without must or filter clauses atleast one should clause has to match
+	if len(b.Must) == 0 && len(b.Filter) == 0 && len(b.Should) > 0 && shouldMatched == 0 {
+		return false, 0, nil
+	}
+	if score == 0 {
+		score = 1
+	}
+	return true, score, nil
+}
+
+func evaluateTerm(term TermQuery, source map[string]any) bool {
+	for field, fv := range term {
+		if fv == nil {
+			return false
+		}
+		found := false
+		for _, value := range fieldValues(source, field) {
+			if equalValues(value, fv.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func evaluateTerms(terms TermsQuery, source map[string]any) bool {
+	for field, candidates := range terms {
+		found := false
+		for _, value := range fieldValues(source, field) {
+			for _, candidate := range candidates {
+				if equalValues(value, candidate) {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func evaluateRange(rangeQuery RangeQuery, source map[string]any) bool {
+	for field, bounds := range rangeQuery {
+		found := false
+		for _, value := range fieldValues(source, field) {
+			if !isEmptyBound(bounds.Gt) {
+				if c, ok := compareValues(value, bounds.Gt); !ok || c <= 0 {
+					continue
+				}
+			}
+			if !isEmptyBound(bounds.Gte) {
+				if c, ok := compareValues(value, bounds.Gte); !ok || c < 0 {
+					continue
+				}
+			}
+			if !isEmptyBound(bounds.Lt) {
+				if c, ok := compareValues(value, bounds.Lt); !ok || c >= 0 {
+					continue
+				}
+			}
+			if !isEmptyBound(bounds.Lte) {
+				if c, ok := compareValues(value, bounds.Lte); !ok || c > 0 {
+					continue
+				}
+			}
+			found = true
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// empty bounds are dropped by omitempty before reaching elasticsearch, hence unbounded
+func isEmptyBound(bound any) bool {
+	if bound == nil {
+		return true
+	}
+	s, ok := bound.(string)
+	return ok && s == ""
+}
+
+// fuzzyScore counts the query terms matching atleast one term of the fields. Fuzziness follows
+// elasticsearch semantics where AUTO allows 0, 1 or 2 edits depending on the term length.
+func fuzzyScore(fields []string, query string, fuzziness string, source map[string]any) float64 {
+	queryTerms := analyze(query)
+	best := 0.0
+	for _, field := range fields {
+		fieldTerms := analyzeField(source, field)
+		score := 0.0
+		for _, qt := range queryTerms {
+			for _, ft := range fieldTerms {
+				if fuzzyMatch(qt, ft, fuzziness) {
+					score++
+					break
+				}
+			}
+		}
+		best = math.Max(best, score)
+	}
+	return best
+}
+
+// boolPrefixScore counts the query terms matching atleast one term of the fields like a search_as_you_type
+// field does, the last query term matches as a prefix while typing. With OperatorAnd every query term has to match.
+func boolPrefixScore(mm *MultiMatch, source map[string]any) float64 {
+	queryTerms := analyze(mm.Query)
+	best := 0.0
+	for _, field := range mm.Fields {
+		fieldTerms := analyzeField(source, field)
+		score := 0.0
+		for i, qt := range queryTerms {
+			for _, ft := range fieldTerms {
+				if qt == ft || (i == len(queryTerms)-1 && strings.HasPrefix(ft, qt)) {
+					score++
+					break
+				}
+			}
+		}
+		if strings.EqualFold(mm.Operator, OperatorAnd) && score < float64(len(queryTerms)) {
+			score = 0
+		}
+		best = math.Max(best, score)
+	}
+	return best
+}
+
+// phraseScore is the number of query terms when a field has all of them next to each other and in order, zero otherwise
+func phraseScore(mm *MultiMatch, source map[string]any) float64 {
+	queryTerms := analyze(mm.Query)
+	if len(queryTerms) == 0 {
+		return 0
+	}
+	for _, field := range mm.Fields {
+		for _, value := range fieldValues(source, field) {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			fieldTerms := analyze(s)
+			for start := 0; start+len(queryTerms) <= len(fieldTerms); start++ {
+				if slices.Equal(fieldTerms[start:start+len(queryTerms)], queryTerms) {
+					return float64(len(queryTerms))
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// analyzeField analyzes the text values of the field
+func analyzeField(source map[string]any, field string) []string {
+	terms := []string{}
+	for _, value := range fieldValues(source, field) {
+		if s, ok := value.(string); ok {
+			terms = append(terms, analyze(s)...)
+		}
+	}
+	return terms
+}
+
+func fuzzyMatch(queryTerm string, fieldTerm string, fuzziness string) bool {
+	if queryTerm == fieldTerm {
+		return true
+	}
+	edits := 0
+	switch strings.ToUpper(fuzziness) {
+	case "":
+		edits = 0
+	case "AUTO":
+		switch n := len([]rune(queryTerm)); {
+		case n <= 2:
+			edits = 0
+		case n <= 5:
+			edits = 1
+		default:
+			edits = 2
+		}
+	default:
+		edits, _ = strconv.Atoi(fuzziness)
+	}
+	return edits > 0 && levenshtein(queryTerm, fieldTerm) <= edits
+}
+
+// analyze mimics the standard analyzer by lower casing and splitting on non alphanumerics
+func analyze(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// evaluateNested evaluates the query against every object of the nested field as if it was the only
+// one, scoring the document with the best matching object
+func evaluateNested(n *NestedQuery, doc *document, scorer fullTextScorer) (bool, float64, error) {
+	matched, best := false, 0.0
+	for _, value := range fieldValues(doc.source, n.Path) {
+		object, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		nested := &document{id: doc.id, seq: doc.seq, source: map[string]any{n.Path: object}}
+		ok, s, err := evaluateQuery(&n.Query, nested, scorer)
+		if err != nil {
+			return false, 0, err
+		}
+		if ok {
+			matched, best = true, max(best, s)
+		}
+	}
+	return matched, best, nil
+}
+
+// subFields are the sub fields of the mappings, they resolve to the field they are indexed from
+var subFields = []string{".keyword", ".suggest._2gram", ".suggest._3gram", ".suggest._index_prefix", ".suggest"}
+
+// fieldValues resolves a dotted field path in the source. Sub fields like `.keyword` resolve to
+// the field itself and arrays are flattened, same as elasticsearch does while indexing.
+func fieldValues(source map[string]any, field string) []any {
+	return collectValues(source, strings.Split(sourceField(field), "."))
+}
+
+// sourceField is the field of the source a field of the mappings is indexed from
+func sourceField(field string) string {
+	for _, subField := range subFields {
+		if trimmed, ok := strings.CutSuffix(field, subField); ok {
+			return trimmed
+		}
+	}
+	return field
+}
+
+func collectValues(value any, path []string) []any {
+	switch v := value.(type) {
+	case []any:
+		values := []any{}
+		for _, item := range v {
+			values = append(values, collectValues(item, path)...)
+		}
+		return values
+	case map[string]any:
+		if len(path) == 0 {
+			return []any{v}
+		}
+		// keys may contain dots themselves, prefer the longest key present
+		for i := len(path); i > 0; i-- {
+			if child, ok := v[strings.Join(path[:i], ".")]; ok {
+				return collectValues(child, path[i:])
+			}
+		}
+		return nil
+	case nil:
+		return nil
+	default:
+		if len(path) != 0 {
+			return nil
+		}
+		return []any{v}
+	}
+}
+
+func equalValues(a, b any) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// compareValues compares numbers numerically, RFC3339 timestamps chronologically and falls back
+// to string comparison. The boolean is false when the values are not comparable.
+func compareValues(a, b any) (int, bool) {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1, true
+			case fa > fb:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	if !okA || !okB {
+		if ba, ok := a.(bool); ok {
+			if bb, ok := b.(bool); ok {
+				return compareBools(ba, bb), true
+			}
+		}
+		return 0, false
+	}
+	if ta, err := time.Parse(time.RFC3339, sa); err == nil {
+		if tb, err := time.Parse(time.RFC3339, sb); err == nil {
+			return ta.Compare(tb), true
+		}
+	}
+	return strings.Compare(sa, sb), true
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	}
+	return 1
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// sortKey is a single field to sort on
+type sortKey struct {
+	field string
+	order SortOrder
+}
+
+// sortKeys flattens the sort fields in order of precedence, sorting on relevance when empty
+func sortKeys(sortFields []*SortField) []sortKey {
+	keys := []sortKey{}
+	for _, sf := range sortFields {
+		if sf == nil {
+			continue
+		}
+		fields := make([]string, 0, len(*sf))
+		for field := range *sf {
+			fields = append(fields, field)
+		}
+		// json encoding of a map orders keys, keep the same precedence
+		sort.Strings(fields)
+		for _, field := range fields {
+			keys = append(keys, sortKey{field: field, order: (*sf)[field]})
+		}
+	}
+	if len(keys) == 0 {
+		keys = append(keys, sortKey{field: "_score", order: Desc})
+	}
+	return keys
+}
+
+// sortDocuments sorts on the sort keys provided. Documents missing the sort field are placed last
+// irrespective of the order like elasticsearch does.
+func sortDocuments(docs []*document, keys []sortKey) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		c := compareSortKeys(sortValues(docs[i], keys), sortValues(docs[j], keys), keys)
+		if c != 0 {
+			return c < 0
+		}
+		return docs[i].seq < docs[j].seq
+	})
+}
+
+// compareSortKeys compares the sort values of two documents, negative when a sorts before b
+func compareSortKeys(a, b []any, keys []sortKey) int {
+	for i, key := range keys {
+		var va, vb any
+		if i < len(a) {
+			va = a[i]
+		}
+		if i < len(b) {
+			vb = b[i]
+		}
+		c := compareSortValues(va, vb)
+		switch {
+		case c == 0:
+			continue
+		case c == missingLast || c == -missingLast:
+			// missing values stay last irrespective of the order
+			return c
+		case strings.EqualFold(string(key.order), string(Desc)):
+			return -c
+		default:
+			return c
+		}
+	}
+	return 0
+}
+
+func sortValues(doc *document, keys []sortKey) []any {
+	values := make([]any, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, sortValue(doc, key.field, key.order))
+	}
+	return values
+}
+
+// missingLast is returned by compareSortValues when exactly one of the values is missing
+const missingLast = 2
+
+// sortValue picks the value used for sorting, the min for ascending and max for descending
+// order when the field holds multiple values.
+func sortValue(doc *document, field string, order SortOrder) any {
+	if field == "_score" {
+		return doc.score
+	}
+	var picked any
+	for _, value := range fieldValues(doc.source, field) {
+		if picked == nil {
+			picked = value
+			continue
+		}
+		c, _ := compareValues(value, picked)
+		if (strings.EqualFold(string(order), string(Desc)) && c > 0) || (!strings.EqualFold(string(order), string(Desc)) && c < 0) {
+			picked = value
+		}
+	}
+	return picked
+}
+
+func compareSortValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return missingLast
+	case b == nil:
+		return -missingLast
+	}
+	c, ok := compareValues(a, b)
+	if !ok {
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+	return c
+}
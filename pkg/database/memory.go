@@ -0,0 +1,200 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MemoryClient is an in-memory Repository. Documents are kept as json per index and the
+// elasticsearch dsl is evaluated in go, which makes it a drop in replacement for running the
+// catalogue locally or in CI without an elasticsearch cluster. Data is lost on restart.
+type MemoryClient struct {
+	mu      *sync.RWMutex
+	seq     int64
+	seqNo   int
+	indices map[string]map[string]*memoryDocument
+}
+
+// memoryDocument keeps the insertion order in seq and bumps seqNo on every write, which
+// stands in for `_seq_no` of elasticsearch. The primary term is always memoryPrimaryTerm.
+type memoryDocument struct {
+	seq    int64
+	seqNo  int
+	source []byte
+}
+
+const memoryPrimaryTerm = 1
+
+// NewMemoryClient returns an empty in-memory repository
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{
+		mu:      &sync.RWMutex{},
+		indices: map[string]map[string]*memoryDocument{},
+	}
+}
+
+// SearchAndGetHits evaluates the query on all documents of the index.
+// An index which was never written to returns no hits.
+func (m *MemoryClient) SearchAndGetHits(cctx context.CustomContext, query *Body, index string) (*SearchResult, error) {
+	docs, err := m.documents(index)
+	if err != nil {
+		return nil, err
+	}
+	return searchDocuments(index, docs, query, nil)
+}
+
+// CreateDocument stores the document with a generated id in the index provided
+func (m *MemoryClient) CreateDocument(cctx context.CustomContext, docBytes []byte, index string) (string, error) {
+	if !json.Valid(docBytes) {
+		return "", fmt.Errorf("create failed, document is not valid json")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.indices[index]; !ok {
+		m.indices[index] = map[string]*memoryDocument{}
+	}
+	m.seq++
+	m.seqNo++
+	docId := uuid.NewString()
+	m.indices[index][docId] = &memoryDocument{
+		seq:    m.seq,
+		seqNo:  m.seqNo,
+		source: docBytes,
+	}
+	return docId, nil
+}
+
+// IndexDocument creates or replaces the document with the id provided
+func (m *MemoryClient) IndexDocument(cctx context.CustomContext, docBytes []byte, index string, docId string) error {
+	if !json.Valid(docBytes) {
+		return fmt.Errorf("index failed, document is not valid json")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.indices[index]; !ok {
+		m.indices[index] = map[string]*memoryDocument{}
+	}
+	m.seqNo++
+	if stored, ok := m.indices[index][docId]; ok {
+		stored.seqNo = m.seqNo
+		stored.source = docBytes
+		return nil
+	}
+	m.seq++
+	m.indices[index][docId] = &memoryDocument{
+		seq:    m.seq,
+		seqNo:  m.seqNo,
+		source: docBytes,
+	}
+	return nil
+}
+
+// UpdateDocument merges the `doc` of the UpdateBody into the stored document. Nested objects
+// are merged recursively while rest of the fields are left untouched.
+func (m *MemoryClient) UpdateDocument(cctx context.CustomContext, docBytes []byte, index string, docId string, revision *Revision) error {
+	update := &UpdateBody{}
+	if err := json.Unmarshal(docBytes, update); err != nil {
+		return fmt.Errorf("failed to parse update body: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.indices[index][docId]
+	if !ok {
+		return fmt.Errorf("update failed, document [%s] missing in index [%s]: %w", docId, index, DocumentMissingErr)
+	}
+	if !stored.isAt(revision) {
+		return fmt.Errorf("update failed, document [%s] changed in index [%s]: %w", docId, index, VersionConflictErr)
+	}
+	source := map[string]any{}
+	if err := json.Unmarshal(stored.source, &source); err != nil {
+		return fmt.Errorf("failed to parse stored document: %w", err)
+	}
+	MergeDocuments(source, update.Doc)
+
+	sourceBytes, err := json.Marshal(source)
+	if err != nil {
+		return fmt.Errorf("failed to marshal updated document: %w", err)
+	}
+	m.seqNo++
+	stored.seqNo = m.seqNo
+	stored.source = sourceBytes
+	return nil
+}
+
+// DeleteDocument removes the document from the index
+func (m *MemoryClient) DeleteDocument(cctx context.CustomContext, index string, docId string, revision *Revision) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.indices[index][docId]
+	if !ok {
+		return fmt.Errorf("delete failed, document [%s] missing in index [%s]: %w", docId, index, DocumentMissingErr)
+	}
+	if !stored.isAt(revision) {
+		return fmt.Errorf("delete failed, document [%s] changed in index [%s]: %w", docId, index, VersionConflictErr)
+	}
+	delete(m.indices[index], docId)
+	return nil
+}
+
+// OpenPointInTime is not supported in memory, searches always see the latest data
+func (m *MemoryClient) OpenPointInTime(cctx context.CustomContext, index string, keepAlive string) (string, error) {
+	return "", nil
+}
+
+// PutMapping has nothing to do in memory, documents are schemaless
+func (m *MemoryClient) PutMapping(cctx context.CustomContext, index string, mapping []byte) error {
+	return nil
+}
+
+// documents decodes a fresh copy of every document in the index so callers can not
+// mutate the stored state
+func (m *MemoryClient) documents(index string) ([]*document, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	docs := make([]*document, 0, len(m.indices[index]))
+	for docId, stored := range m.indices[index] {
+		source := map[string]any{}
+		if err := json.Unmarshal(stored.source, &source); err != nil {
+			return nil, fmt.Errorf("failed to parse stored document: %w", err)
+		}
+		docs = append(docs, &document{
+			id:          docId,
+			seq:         stored.seq,
+			seqNo:       stored.seqNo,
+			primaryTerm: memoryPrimaryTerm,
+			source:      source,
+		})
+	}
+	return docs, nil
+}
+
+// isAt reports whether the document is at the revision, a nil revision always matches
+func (d *memoryDocument) isAt(revision *Revision) bool {
+	return revision == nil || (revision.SeqNo == d.seqNo && revision.PrimaryTerm == memoryPrimaryTerm)
+}
+
+// MergeDocuments applies patch on top of source the way elasticsearch partial updates do, nested objects are
+// merged recursively and the rest of the fields are replaced
+func MergeDocuments(source map[string]any, patch map[string]any) {
+	for key, value := range patch {
+		patchMap, isMap := value.(map[string]any)
+		sourceMap, sourceIsMap := source[key].(map[string]any)
+		if isMap && sourceIsMap {
+			MergeDocuments(sourceMap, patchMap)
+			continue
+		}
+		source[key] = value
+	}
+}
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"encoding/json"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedMemoryClient(t *testing.T) (*MemoryClient, map[string]string) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	client := NewMemoryClient()
+	docs := []map[string]any{
+		{"serviceId": "a", "name": "payments", "description": "processes card payments for checkout", "updatedAt": "2024-08-01T10:00:00Z", "version": 1},
+		{"serviceId": "b", "name": "notifications", "description": "sends email and sms notifications", "updatedAt": "2024-08-03T10:00:00Z", "version": 3},
+		{"serviceId": "c", "name": "ledger", "description": "double entry ledger for payments", "updatedAt": "2024-08-05T10:00:00Z", "version": 2},
+	}
+	ids := map[string]string{}
+	for _, doc := range docs {
+		docBytes, err := json.Marshal(doc)
+		require.NoError(t, err)
+		id, err := client.CreateDocument(cctx, docBytes, ServiceCatalogueIndex)
+		require.NoError(t, err)
+		ids[doc["serviceId"].(string)] = id
+	}
+	return client, ids
+}
+
+func serviceIds(t *testing.T, hits []any) []string {
+	ids := []string{}
+	for _, hit := range hits {
+		source := hit.(map[string]any)["_source"].(map[string]any)
+		ids = append(ids, source["serviceId"].(string))
+	}
+	return ids
+}
+
+func searchHits(t *testing.T, repo Repository, body *Body, index string) []any {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	result, err := repo.SearchAndGetHits(cctx, body, index)
+	require.NoError(t, err)
+	return result.Hits
+}
+
+func TestMemoryClient_Search(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	client, _ := seedMemoryClient(t)
+
+	t.Run("term on keyword field", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{Term: &TermQuery{"serviceId.keyword": {Value: "b"}}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"b"}, serviceIds(t, hits))
+	})
+
+	t.Run("range filter with sort", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{Range: &RangeQuery{"updatedAt": {Gte: "2024-08-02T00:00:00Z", Lte: "2024-08-30T00:00:00Z"}}},
+			Sort:  []*SortField{{"updatedAt": Desc}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"c", "b"}, serviceIds(t, hits))
+	})
+
+	t.Run("fuzzy search tolerates typos", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{MultiMatch: &MultiMatch{
+				Fields:    []string{NameField, DescriptionField},
+				Query:     "paymnts",
+				Fuzziness: "AUTO",
+			}},
+			Sort: []*SortField{{"version": Asc}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"a", "c"}, serviceIds(t, hits))
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		result, err := client.SearchAndGetHits(cctx, &Body{
+			Sort: []*SortField{{"version": Asc}},
+			From: 1,
+			Size: HitsSize(1),
+		}, ServiceCatalogueIndex)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c"}, serviceIds(t, result.Hits))
+		assert.Equal(t, 3, result.Total)
+	})
+
+	t.Run("search after", func(t *testing.T) {
+		body := &Body{
+			Sort: []*SortField{{"version": Desc}, {"serviceId.keyword": Asc}},
+			Size: HitsSize(2),
+		}
+		hits := searchHits(t, client, body, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"b", "c"}, serviceIds(t, hits))
+
+		body.SearchAfter = hits[1].(map[string]any)["sort"].([]any)
+		hits = searchHits(t, client, body, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"a"}, serviceIds(t, hits))
+	})
+
+	t.Run("terms and exists", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{Terms: &TermsQuery{"serviceId.keyword": {"a", "c", "x"}}},
+			Sort:  []*SortField{{"version": Asc}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"a", "c"}, serviceIds(t, hits))
+
+		hits = searchHits(t, client, &Body{Query: &Query{Exists: &ExistsQuery{Field: "labels.tier"}}}, ServiceCatalogueIndex)
+		assert.Empty(t, hits)
+		hits = searchHits(t, client, &Body{Query: &Query{Exists: &ExistsQuery{Field: "description"}}}, ServiceCatalogueIndex)
+		assert.Len(t, hits, 3)
+	})
+
+	t.Run("bool query", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{Bool: &BoolQuery{
+				MustNot: []Query{{Term: &TermQuery{"serviceId.keyword": {Value: "a"}}}},
+			}},
+			Sort: []*SortField{{"name.keyword": Asc}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"c", "b"}, serviceIds(t, hits))
+	})
+
+	t.Run("filter clauses do not score", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{Bool: &BoolQuery{
+				Should: []Query{{MultiMatch: &MultiMatch{Fields: []string{DescriptionField}, Query: "ledger payments"}}},
+				Filter: []Query{{Range: &RangeQuery{"version": {Gte: 2}}}},
+			}},
+			Sort: []*SortField{{"_score": Desc}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"c", "b"}, serviceIds(t, hits), "should clauses are optional along with filter clauses")
+		assert.Greater(t, hits[0].(map[string]any)["_score"], hits[1].(map[string]any)["_score"])
+	})
+
+	t.Run("strict range bounds", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{Range: &RangeQuery{"version": {Gt: 1, Lt: 3}}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"c"}, serviceIds(t, hits))
+	})
+
+	t.Run("phrase matches the terms in order", func(t *testing.T) {
+		phrase := func(query string) []any {
+			return searchHits(t, client, &Body{
+				Query: &Query{MultiMatch: &MultiMatch{Fields: []string{NameField, DescriptionField}, Query: query, Type: MultiMatchPhrase}},
+			}, ServiceCatalogueIndex)
+		}
+		assert.Equal(t, []string{"a"}, serviceIds(t, phrase("Card Payments")))
+		assert.Empty(t, phrase("payments card"))
+		assert.Empty(t, phrase("card checkout"))
+	})
+
+	t.Run("highlight", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{MultiMatch: &MultiMatch{
+				Fields:    []string{NameField, DescriptionField},
+				Query:     "paymnts",
+				Fuzziness: "AUTO",
+			}},
+			Sort: []*SortField{{"version": Asc}},
+			Highlight: &Highlight{
+				Fields:   map[string]*HighlightField{NameField: {}, DescriptionField: {FragmentSize: 10}},
+				PreTags:  []string{"<b>"},
+				PostTags: []string{"</b>"},
+			},
+		}, ServiceCatalogueIndex)
+		require.Len(t, hits, 2)
+		assert.Equal(t, map[string]any{
+			NameField:        []any{"<b>payments</b>"},
+			DescriptionField: []any{"card <b>payments</b>"},
+		}, hits[0].(map[string]any)["highlight"])
+		assert.Equal(t, map[string]any{
+			DescriptionField: []any{"<b>payments</b>"},
+		}, hits[1].(map[string]any)["highlight"], "fields without a match are left out")
+	})
+
+	t.Run("highlight encoded as html", func(t *testing.T) {
+		client := NewMemoryClient()
+		_, err := client.CreateDocument(cctx, []byte(`{"serviceId": "x", "description": "ledger <img src=x onerror=alert(1)> & co"}`), ServiceCatalogueIndex)
+		require.NoError(t, err)
+		hits := searchHits(t, client, &Body{
+			Query:     &Query{MultiMatch: &MultiMatch{Fields: []string{DescriptionField}, Query: "ledger"}},
+			Highlight: &Highlight{Fields: map[string]*HighlightField{DescriptionField: {}}, Encoder: HighlightEncoderHTML},
+		}, ServiceCatalogueIndex)
+		require.Len(t, hits, 1)
+		assert.Equal(t, map[string]any{
+			DescriptionField: []any{"<em>ledger</em> &lt;img src=x onerror=alert(1)&gt; &amp; co"},
+		}, hits[0].(map[string]any)["highlight"], "the text is escaped but the tags are not")
+	})
+
+	t.Run("bool prefix matches while typing", func(t *testing.T) {
+		suggest := func(typed string) []any {
+			return searchHits(t, client, &Body{
+				Query: &Query{MultiMatch: &MultiMatch{
+					Fields:   NameSuggestFields,
+					Query:    typed,
+					Type:     MultiMatchBoolPrefix,
+					Operator: OperatorAnd,
+				}},
+				Source: []string{"serviceId", NameField},
+			}, ServiceCatalogueIndex)
+		}
+		hits := suggest("notif")
+		assert.Equal(t, []string{"b"}, serviceIds(t, hits))
+		assert.Equal(t, map[string]any{"serviceId": "b", "name": "notifications"}, hits[0].(map[string]any)["_source"])
+		assert.Empty(t, suggest("otif"), "only prefixes match")
+		assert.Empty(t, suggest("led pay"), "every term but the last has to match exactly")
+	})
+
+	t.Run("aggregations ignore pagination", func(t *testing.T) {
+		result, err := client.SearchAndGetHits(cctx, &Body{
+			Query: &Query{Range: &RangeQuery{"updatedAt": {Gte: "2024-08-02T00:00:00Z"}}},
+			Size:  HitsSize(0),
+			Aggs: map[string]*Aggregation{
+				"names":   {Terms: &TermsAggregation{Field: "name.keyword"}},
+				"owners":  {Terms: &TermsAggregation{Field: "ownerTeam.keyword", Missing: "none"}},
+				"updated": {DateHistogram: &DateHistogramAggregation{Field: "updatedAt", CalendarInterval: CalendarDay}},
+			},
+		}, ServiceCatalogueIndex)
+		require.NoError(t, err)
+		assert.Empty(t, result.Hits, "a size of zero asks for none of the hits")
+		assert.Equal(t, 2, result.Total)
+		assert.Equal(t, []*Bucket{{Key: "ledger", DocCount: 1}, {Key: "notifications", DocCount: 1}}, result.Aggregations["names"].Buckets)
+		assert.Equal(t, []*Bucket{{Key: "none", DocCount: 2}}, result.Aggregations["owners"].Buckets)
+		days := []string{}
+		for _, bucket := range result.Aggregations["updated"].Buckets {
+			days = append(days, bucket.KeyAsString[:10])
+		}
+		assert.Equal(t, []string{"2024-08-03", "2024-08-04", "2024-08-05"}, days, "empty intervals are filled")
+		assert.Equal(t, 0, result.Aggregations["updated"].Buckets[1].DocCount)
+	})
+
+	t.Run("nested query matches a single object", func(t *testing.T) {
+		docBytes := []byte(`{"serviceId": "d", "links": [{"kind": "dashboard", "host": "github.com"}, {"kind": "repository", "host": "gitlab.com"}]}`)
+		_, err := client.CreateDocument(cctx, docBytes, ServiceCatalogueIndex)
+		require.NoError(t, err)
+
+		nested := func(kind, host string) []any {
+			return searchHits(t, client, &Body{Query: &Query{Nested: &NestedQuery{
+				Path: "links",
+				Query: Query{Bool: &BoolQuery{Must: []Query{
+					{Term: &TermQuery{"links.kind": {Value: kind}}},
+					{Term: &TermQuery{"links.host": {Value: host}}},
+				}}},
+			}}}, ServiceCatalogueIndex)
+		}
+		assert.Equal(t, []string{"d"}, serviceIds(t, nested("repository", "gitlab.com")))
+		assert.Empty(t, nested("repository", "github.com"))
+	})
+}
+
+func TestMemoryClient_UpdateAndDelete(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	client, ids := seedMemoryClient(t)
+
+	update, err := json.Marshal(&UpdateBody{Doc: map[string]any{"name": "payments-v2", "version": 2}})
+	require.NoError(t, err)
+	require.NoError(t, client.UpdateDocument(cctx, update, ServiceCatalogueIndex, ids["a"], nil))
+
+	hits := searchHits(t, client, &Body{
+		Query: &Query{Term: &TermQuery{"serviceId.keyword": {Value: "a"}}},
+	}, ServiceCatalogueIndex)
+	require.Len(t, hits, 1)
+	source := hits[0].(map[string]any)["_source"].(map[string]any)
+	assert.Equal(t, "payments-v2", source["name"])
+	assert.Equal(t, "processes card payments for checkout", source["description"])
+	assert.EqualValues(t, 2, source["version"])
+
+	require.NoError(t, client.DeleteDocument(cctx, ServiceCatalogueIndex, ids["a"], nil))
+	assert.Error(t, client.DeleteDocument(cctx, ServiceCatalogueIndex, ids["a"], nil))
+	assert.Error(t, client.UpdateDocument(cctx, update, ServiceCatalogueIndex, ids["a"], nil))
+}
+
+func TestMemoryClient_ConditionalWrites(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	client, ids := seedMemoryClient(t)
+	fetch := &Body{
+		Query:            &Query{Term: &TermQuery{"serviceId.keyword": {Value: "a"}}},
+		SeqNoPrimaryTerm: true,
+	}
+
+	hits := searchHits(t, client, fetch, ServiceCatalogueIndex)
+	revision, err := RevisionFromHit(hits[0].(map[string]any))
+	require.NoError(t, err)
+
+	update, err := json.Marshal(&UpdateBody{Doc: map[string]any{"version": 2}})
+	require.NoError(t, err)
+	require.NoError(t, client.UpdateDocument(cctx, update, ServiceCatalogueIndex, ids["a"], revision))
+
+	// the revision read before the update is stale now
+	err = client.UpdateDocument(cctx, update, ServiceCatalogueIndex, ids["a"], revision)
+	assert.ErrorIs(t, err, VersionConflictErr)
+	err = client.DeleteDocument(cctx, ServiceCatalogueIndex, ids["a"], revision)
+	assert.ErrorIs(t, err, VersionConflictErr)
+
+	hits = searchHits(t, client, fetch, ServiceCatalogueIndex)
+	revision, err = RevisionFromHit(hits[0].(map[string]any))
+	require.NoError(t, err)
+	assert.NoError(t, client.DeleteDocument(cctx, ServiceCatalogueIndex, ids["a"], revision))
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"fmt"
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+)
+
+// Repository is the storage abstraction the service layer talks to. Queries are expressed
+// with the elasticsearch flavoured Body so every backend understands the same dsl and hits
+// are returned in the elasticsearch shape i.e. each hit carries `_id` and `_source`.
+type Repository interface {
+	// SearchAndGetHits runs the query on the index and returns the hits of the page along with the total
+	SearchAndGetHits(cctx context.CustomContext, query *Body, index string) (*SearchResult, error)
+	// CreateDocument stores the document in the index and returns the generated document id
+	CreateDocument(cctx context.CustomContext, docBytes []byte, index string) (string, error)
+	// IndexDocument creates or replaces the document with the id provided
+	IndexDocument(cctx context.CustomContext, docBytes []byte, index string, docId string) error
+	// UpdateDocument partially updates the document with the `doc` provided in an UpdateBody.
+	// A non nil revision makes the update conditional on the document not having changed since.
+	UpdateDocument(cctx context.CustomContext, docBytes []byte, index string, docId string, revision *Revision) error
+	// DeleteDocument removes the document from the index.
+	// A non nil revision makes the delete conditional on the document not having changed since.
+	DeleteDocument(cctx context.CustomContext, index string, docId string, revision *Revision) error
+	// OpenPointInTime opens a point in time on the index which is kept alive for keepAlive between searches.
+	// Backends which can not search a point in time return an empty id and searches see the latest data.
+	OpenPointInTime(cctx context.CustomContext, index string, keepAlive string) (string, error)
+	// PutMapping adds the field mappings to the index. Mapped fields can not change their type.
+	// Schemaless backends have nothing to map and ignore it.
+	PutMapping(cctx context.CustomContext, index string, mapping []byte) error
+}
+
+// TotalRelationEqual and TotalRelationAtLeast tell if SearchResult.Total is exact or a lower bound
+var (
+	TotalRelationEqual   = "eq"
+	TotalRelationAtLeast = "gte"
+)
+
+var (
+	// DocumentMissingErr is wrapped by update and delete when the document id does not exist
+	DocumentMissingErr = fmt.Errorf("DOCUMENT_MISSING")
+	// VersionConflictErr is wrapped by conditional writes when the document changed since the revision
+	VersionConflictErr = fmt.Errorf("VERSION_CONFLICT")
+)
+
+var (
+	_ Repository = (*ESClient)(nil)
+	_ Repository = (*MemoryClient)(nil)
+	_ Repository = (*SQLiteClient)(nil)
+)
+
+// NewRepository creates the storage backend selected by cfg.Driver.
+// Returns a wrapped error in case of any issues
+func NewRepository(cfg config.Database) (Repository, error) {
+	switch cfg.Driver {
+	case config.DriverElasticSearch:
+		esClient, err := InitESClient(cfg.ElasticSearch)
+		if err != nil {
+			return nil, err
+		}
+		return esClient, nil
+	case config.DriverMemory:
+		return NewMemoryClient(), nil
+	case config.DriverSQLite:
+		sqliteClient, err := InitSQLiteClient(cfg.SQLite)
+		if err != nil {
+			return nil, err
+		}
+		return sqliteClient, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %q", cfg.Driver)
+	}
+}
+
+// RevisionFromHit reads the revision of a hit searched with SeqNoPrimaryTerm set
+func RevisionFromHit(hit map[string]any) (*Revision, error) {
+	seqNo, ok := toFloat(hit["_seq_no"])
+	if !ok {
+		return nil, fmt.Errorf("hit is missing _seq_no")
+	}
+	primaryTerm, ok := toFloat(hit["_primary_term"])
+	if !ok {
+		return nil, fmt.Errorf("hit is missing _primary_term")
+	}
+	return &Revision{
+		SeqNo:       int(seqNo),
+		PrimaryTerm: int(primaryTerm),
+	}, nil
+}
@@ -0,0 +1,457 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema stores documents of every index as json in a single table. The fts5 table indexes
+// name and description with the same rowid as the document for full text search.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS documents (
+		seq    INTEGER PRIMARY KEY AUTOINCREMENT,
+		idx    TEXT NOT NULL,
+		id     TEXT NOT NULL,
+		source TEXT NOT NULL,
+		seq_no INTEGER NOT NULL DEFAULT 1,
+		UNIQUE (idx, id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS documents_service_id ON documents (idx, json_extract(source, '$.serviceId'))`,
+	`CREATE INDEX IF NOT EXISTS documents_parent_id ON documents (idx, json_extract(source, '$.parentId'))`,
+	`CREATE INDEX IF NOT EXISTS documents_version_id ON documents (idx, json_extract(source, '$.versionId'))`,
+	`CREATE INDEX IF NOT EXISTS documents_team_id ON documents (idx, json_extract(source, '$.teamId'))`,
+	`CREATE INDEX IF NOT EXISTS documents_owner_team ON documents (idx, json_extract(source, '$.ownerTeam'))`,
+	`CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts USING fts5 (name, description)`,
+}
+
+// sqlitePrimaryTerm is the primary term of every revision, seq_no alone tracks changes of a document
+const sqlitePrimaryTerm = 1
+
+// ftsColumns are the fields indexed in documents_fts
+var ftsColumns = []string{NameField, DescriptionField}
+
+// SQLiteClient is an embedded Repository for single node deployments. Candidate documents are
+// narrowed down in sqlite using json indexes and fts5, the query is then evaluated in go with the
+// same engine as the in-memory backend so list, search and version semantics stay identical.
+type SQLiteClient struct {
+	db *sql.DB
+}
+
+// Opens the sqlite database at the configured path and creates the schema if missing.
+// Returns a wrapped error in case of any issues
+func InitSQLiteClient(cfg config.SQLite) (*SQLiteClient, error) {
+	db, err := sql.Open("sqlite", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// sqlite allows a single writer, serialise access instead of retrying on SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	statements := append([]string{
+		`PRAGMA journal_mode = WAL`,
+		`PRAGMA busy_timeout = 5000`,
+	}, sqliteSchema...)
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to setup sqlite schema: %w", err)
+		}
+	}
+	if err := upgradeSQLiteSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteClient{
+		db: db,
+	}, nil
+}
+
+// SearchAndGetHits narrows down the candidates in sqlite and evaluates the query on them.
+// multi_match queries on name and description are answered by fts5, where every term also
+// matches as a prefix and fuzziness loosens the prefix to tolerate typos towards the end.
+func (s *SQLiteClient) SearchAndGetHits(cctx context.CustomContext, query *Body, index string) (*SearchResult, error) {
+	if query == nil {
+		query = &Body{}
+	}
+
+	ftsScores := map[*MultiMatch]map[int64]float64{}
+	var err error
+	walkQuery(query.Query, func(q *Query) {
+		if err != nil || q.MultiMatch == nil || !isFullTextIndexed(q.MultiMatch.Fields) {
+			return
+		}
+		ftsScores[q.MultiMatch], err = s.fullTextSearch(cctx, q.MultiMatch, index)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"idx = ?"}
+	args := []any{index}
+	for _, q := range requiredClauses(query.Query) {
+		if q.Term != nil {
+			for field, fv := range *q.Term {
+				path, ok := keywordPath(index, field)
+				if !ok || fv == nil {
+					continue
+				}
+				if _, isString := fv.Value.(string); !isString {
+					continue
+				}
+				// only text values are compared here, arrays and numbers are matched in go
+				conditions = append(conditions, "(json_type(source, ?) != 'text' OR json_extract(source, ?) = ?)")
+				args = append(args, path, path, fv.Value)
+			}
+		}
+		if q.MultiMatch != nil {
+			if scores, ok := ftsScores[q.MultiMatch]; ok {
+				seqs := make([]string, 0, len(scores))
+				for seq := range scores {
+					seqs = append(seqs, fmt.Sprint(seq))
+				}
+				conditions = append(conditions, fmt.Sprintf("seq IN (%s)", strings.Join(seqs, ",")))
+			}
+		}
+	}
+
+	rows, err := s.db.QueryContext(cctx, fmt.Sprintf("SELECT seq, seq_no, id, source FROM documents WHERE %s", strings.Join(conditions, " AND ")), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sqlite: %w", err)
+	}
+	defer rows.Close()
+
+	docs := []*document{}
+	for rows.Next() {
+		doc := &document{primaryTerm: sqlitePrimaryTerm}
+		var source string
+		if err := rows.Scan(&doc.seq, &doc.seqNo, &doc.id, &source); err != nil {
+			return nil, fmt.Errorf("failed to scan document: %w", err)
+		}
+		if err := json.Unmarshal([]byte(source), &doc.source); err != nil {
+			return nil, fmt.Errorf("failed to parse stored document: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read documents: %w", err)
+	}
+
+	return searchDocuments(index, docs, query, func(mm *MultiMatch, doc *document) float64 {
+		scores, ok := ftsScores[mm]
+		if !ok {
+			return fuzzyMultiMatch(mm, doc)
+		}
+		return scores[doc.seq]
+	})
+}
+
+// CreateDocument stores the document with a generated id and indexes it for full text search
+func (s *SQLiteClient) CreateDocument(cctx context.CustomContext, docBytes []byte, index string) (string, error) {
+	source := map[string]any{}
+	if err := json.Unmarshal(docBytes, &source); err != nil {
+		return "", fmt.Errorf("create failed, document is not valid json: %w", err)
+	}
+
+	docId := uuid.NewString()
+	err := s.withTx(cctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(cctx, `INSERT INTO documents (idx, id, source) VALUES (?, ?, ?)`, index, docId, string(docBytes))
+		if err != nil {
+			return fmt.Errorf("create failed: %w", err)
+		}
+		seq, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("create failed: %w", err)
+		}
+		return indexFullText(cctx, tx, seq, source)
+	})
+	if err != nil {
+		return "", err
+	}
+	return docId, nil
+}
+
+// IndexDocument creates or replaces the document with the id provided and reindexes it
+func (s *SQLiteClient) IndexDocument(cctx context.CustomContext, docBytes []byte, index string, docId string) error {
+	source := map[string]any{}
+	if err := json.Unmarshal(docBytes, &source); err != nil {
+		return fmt.Errorf("index failed, document is not valid json: %w", err)
+	}
+
+	return s.withTx(cctx, func(tx *sql.Tx) error {
+		var seq int64
+		err := tx.QueryRowContext(cctx, `
+			INSERT INTO documents (idx, id, source) VALUES (?, ?, ?)
+			ON CONFLICT (idx, id) DO UPDATE SET source = excluded.source, seq_no = seq_no + 1
+			RETURNING seq`, index, docId, string(docBytes)).Scan(&seq)
+		if err != nil {
+			return fmt.Errorf("index failed: %w", err)
+		}
+		if _, err := tx.ExecContext(cctx, `DELETE FROM documents_fts WHERE rowid = ?`, seq); err != nil {
+			return fmt.Errorf("index failed: %w", err)
+		}
+		return indexFullText(cctx, tx, seq, source)
+	})
+}
+
+// UpdateDocument merges the `doc` of the UpdateBody into the stored document and reindexes it
+func (s *SQLiteClient) UpdateDocument(cctx context.CustomContext, docBytes []byte, index string, docId string, revision *Revision) error {
+	update := &UpdateBody{}
+	if err := json.Unmarshal(docBytes, update); err != nil {
+		return fmt.Errorf("failed to parse update body: %w", err)
+	}
+
+	return s.withTx(cctx, func(tx *sql.Tx) error {
+		var seq int64
+		var seqNo int
+		var stored string
+		err := tx.QueryRowContext(cctx, `SELECT seq, seq_no, source FROM documents WHERE idx = ? AND id = ?`, index, docId).Scan(&seq, &seqNo, &stored)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("update failed, document [%s] missing in index [%s]: %w", docId, index, DocumentMissingErr)
+		}
+		if err != nil {
+			return fmt.Errorf("update failed: %w", err)
+		}
+		if !isAtRevision(seqNo, revision) {
+			return fmt.Errorf("update failed, document [%s] changed in index [%s]: %w", docId, index, VersionConflictErr)
+		}
+
+		source := map[string]any{}
+		if err := json.Unmarshal([]byte(stored), &source); err != nil {
+			return fmt.Errorf("failed to parse stored document: %w", err)
+		}
+		MergeDocuments(source, update.Doc)
+		sourceBytes, err := json.Marshal(source)
+		if err != nil {
+			return fmt.Errorf("failed to marshal updated document: %w", err)
+		}
+
+		if _, err := tx.ExecContext(cctx, `UPDATE documents SET source = ?, seq_no = seq_no + 1 WHERE seq = ?`, string(sourceBytes), seq); err != nil {
+			return fmt.Errorf("update failed: %w", err)
+		}
+		if _, err := tx.ExecContext(cctx, `DELETE FROM documents_fts WHERE rowid = ?`, seq); err != nil {
+			return fmt.Errorf("update failed: %w", err)
+		}
+		return indexFullText(cctx, tx, seq, source)
+	})
+}
+
+// DeleteDocument removes the document and its full text entry
+func (s *SQLiteClient) DeleteDocument(cctx context.CustomContext, index string, docId string, revision *Revision) error {
+	return s.withTx(cctx, func(tx *sql.Tx) error {
+		var seq int64
+		var seqNo int
+		err := tx.QueryRowContext(cctx, `SELECT seq, seq_no FROM documents WHERE idx = ? AND id = ?`, index, docId).Scan(&seq, &seqNo)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("delete failed, document [%s] missing in index [%s]: %w", docId, index, DocumentMissingErr)
+		}
+		if err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+		if !isAtRevision(seqNo, revision) {
+			return fmt.Errorf("delete failed, document [%s] changed in index [%s]: %w", docId, index, VersionConflictErr)
+		}
+		if _, err := tx.ExecContext(cctx, `DELETE FROM documents WHERE seq = ?`, seq); err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+		if _, err := tx.ExecContext(cctx, `DELETE FROM documents_fts WHERE rowid = ?`, seq); err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+		return nil
+	})
+}
+
+// OpenPointInTime is not supported by sqlite, searches always see the latest data
+func (s *SQLiteClient) OpenPointInTime(cctx context.CustomContext, index string, keepAlive string) (string, error) {
+	return "", nil
+}
+
+// PutMapping has nothing to do in sqlite, documents are stored as schemaless json
+func (s *SQLiteClient) PutMapping(cctx context.CustomContext, index string, mapping []byte) error {
+	return nil
+}
+
+// Close closes the underlying database
+func (s *SQLiteClient) Close() error {
+	return s.db.Close()
+}
+
+// upgradeSQLiteSchema adds columns introduced after the database file was created
+func upgradeSQLiteSchema(db *sql.DB) error {
+	var hasSeqNo bool
+	err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('documents') WHERE name = 'seq_no'`).Scan(&hasSeqNo)
+	if err != nil {
+		return fmt.Errorf("failed to inspect sqlite schema: %w", err)
+	}
+	if !hasSeqNo {
+		if _, err := db.Exec(`ALTER TABLE documents ADD COLUMN seq_no INTEGER NOT NULL DEFAULT 1`); err != nil {
+			return fmt.Errorf("failed to upgrade sqlite schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// isAtRevision reports whether seqNo is at the revision, a nil revision always matches
+func isAtRevision(seqNo int, revision *Revision) bool {
+	return revision == nil || (revision.SeqNo == seqNo && revision.PrimaryTerm == sqlitePrimaryTerm)
+}
+
+func (s *SQLiteClient) withTx(cctx context.CustomContext, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(cctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// fullTextSearch returns the bm25 score of every document of the index matching the multi_match
+func (s *SQLiteClient) fullTextSearch(cctx context.CustomContext, mm *MultiMatch, index string) (map[int64]float64, error) {
+	scores := map[int64]float64{}
+	expression := ftsExpression(mm)
+	if expression == "" {
+		return scores, nil
+	}
+
+	rows, err := s.db.QueryContext(cctx, `
+		SELECT documents.seq, bm25(documents_fts)
+		FROM documents_fts JOIN documents ON documents.seq = documents_fts.rowid
+		WHERE documents_fts MATCH ? AND documents.idx = ?`, expression, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run full text search: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seq int64
+		var rank float64
+		if err := rows.Scan(&seq, &rank); err != nil {
+			return nil, fmt.Errorf("failed to scan full text result: %w", err)
+		}
+		// bm25 is negative with better matches being lower
+		scores[seq] = -rank
+	}
+	return scores, rows.Err()
+}
+
+// ftsExpression translates a multi_match into an fts5 query. Terms are OR'ed like the default
+// best_fields multi_match and every term is a prefix query. With fuzziness the prefix is
+// shortened to half the term so that typos towards the end of longer terms still match. A phrase
+// is an fts5 phrase of all the terms.
+func ftsExpression(mm *MultiMatch) string {
+	terms := analyze(mm.Query)
+	clauses := []string{}
+	if mm.Type == MultiMatchPhrase && len(terms) > 0 {
+		clauses = append(clauses, fmt.Sprintf(`"%s"`, strings.Join(terms, " ")))
+		terms = nil
+	}
+	for _, term := range terms {
+		clause := fmt.Sprintf(`"%s"*`, term)
+		if runes := []rune(term); mm.Fuzziness != "" && len(runes) > 4 {
+			clause = fmt.Sprintf(`(%s OR "%s"*)`, clause, string(runes[:max(3, (len(runes)+1)/2)]))
+		}
+		clauses = append(clauses, clause)
+	}
+	if len(clauses) == 0 {
+		return ""
+	}
+
+	columns := []string{}
+	for _, field := range mm.Fields {
+		columns = append(columns, strings.TrimSuffix(field, ".keyword"))
+	}
+	return fmt.Sprintf("{%s} : (%s)", strings.Join(columns, " "), strings.Join(clauses, " OR "))
+}
+
+func indexFullText(cctx context.CustomContext, tx *sql.Tx, seq int64, source map[string]any) error {
+	name, _ := source[NameField].(string)
+	description, _ := source[DescriptionField].(string)
+	_, err := tx.ExecContext(cctx, `INSERT INTO documents_fts (rowid, name, description) VALUES (?, ?, ?)`, seq, name, description)
+	if err != nil {
+		return fmt.Errorf("failed to index document for full text search: %w", err)
+	}
+	return nil
+}
+
+func isFullTextIndexed(fields []string) bool {
+	if len(fields) == 0 {
+		return false
+	}
+	for _, field := range fields {
+		if !slices.Contains(ftsColumns, strings.TrimSuffix(field, ".keyword")) {
+			return false
+		}
+	}
+	return true
+}
+
+// keywordFields are the top level fields of the indices mapped as keyword instead of text with a keyword sub field
+var keywordFields = map[string][]string{
+	ServiceCatalogueChangeIndex: {"changeId", "operation", "orgId", "serviceId", "documentId"},
+}
+
+// keywordPath converts a top level keyword field or `.keyword` sub field to a sqlite json path. Keywords
+// compare as the exact value like sql does. Terms on text, dates and nested fields are not pushed down,
+// evaluateTerm matches them.
+func keywordPath(index string, field string) (string, bool) {
+	field, isKeyword := strings.CutSuffix(field, ".keyword")
+	if !isKeyword && !slices.Contains(keywordFields[index], field) {
+		return "", false
+	}
+	if field == "" || strings.ContainsAny(field, `."$[]`) {
+		return "", false
+	}
+	return "$." + field, true
+}
+
+// requiredClauses returns the query and the must and filter clauses of a root bool query, all of which
+// every hit has to satisfy, making them safe to push down as sql conditions.
+func requiredClauses(q *Query) []*Query {
+	if q == nil {
+		return nil
+	}
+	clauses := []*Query{q}
+	if q.Bool != nil {
+		for _, required := range [][]Query{q.Bool.Must, q.Bool.Filter} {
+			for i := range required {
+				clauses = append(clauses, requiredClauses(&required[i])...)
+			}
+		}
+	}
+	return clauses
+}
+
+// walkQuery calls fn for the query and every query nested in it
+func walkQuery(q *Query, fn func(q *Query)) {
+	if q == nil {
+		return
+	}
+	fn(q)
+	if q.Bool != nil {
+		for _, clauses := range [][]Query{q.Bool.Must, q.Bool.Filter, q.Bool.Should, q.Bool.MustNot} {
+			for i := range clauses {
+				walkQuery(&clauses[i], fn)
+			}
+		}
+	}
+	if q.FunctionScore != nil {
+		walkQuery(&q.FunctionScore.Query, fn)
+		for i := range q.FunctionScore.Functions {
+			walkQuery(&q.FunctionScore.Functions[i].Filter, fn)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"encoding/json"
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedSQLiteClient(t *testing.T) (*SQLiteClient, map[string]string) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	client, err := InitSQLiteClient(config.SQLite{Path: filepath.Join(t.TempDir(), "catalogue.db")})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	docs := []map[string]any{
+		{"serviceId": "a", "name": "payments", "description": "processes card payments for checkout", "updatedAt": "2024-08-01T10:00:00Z", "version": 1},
+		{"serviceId": "b", "name": "notifications", "description": "sends email and sms notifications", "updatedAt": "2024-08-03T10:00:00Z", "version": 3},
+		{"serviceId": "c", "name": "ledger", "description": "double entry ledger for payments", "updatedAt": "2024-08-05T10:00:00Z", "version": 2},
+	}
+	ids := map[string]string{}
+	for _, doc := range docs {
+		docBytes, err := json.Marshal(doc)
+		require.NoError(t, err)
+		id, err := client.CreateDocument(cctx, docBytes, ServiceCatalogueIndex)
+		require.NoError(t, err)
+		ids[doc["serviceId"].(string)] = id
+	}
+	return client, ids
+}
+
+func TestSQLiteClient_Search(t *testing.T) {
+	client, _ := seedSQLiteClient(t)
+
+	t.Run("term on keyword field", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{Term: &TermQuery{"serviceId.keyword": {Value: "b"}}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"b"}, serviceIds(t, hits))
+	})
+
+	t.Run("range filter with sort", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{Range: &RangeQuery{"updatedAt": {Gte: "2024-08-02T00:00:00Z", Lte: "2024-08-30T00:00:00Z"}}},
+			Sort:  []*SortField{{"updatedAt": Desc}},
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"c", "b"}, serviceIds(t, hits))
+	})
+
+	t.Run("full text search matches prefixes and typos", func(t *testing.T) {
+		for _, search := range []string{"pay", "paymnts"} {
+			hits := searchHits(t, client, &Body{
+				Query: &Query{MultiMatch: &MultiMatch{
+					Fields:    []string{NameField, DescriptionField},
+					Query:     search,
+					Fuzziness: "AUTO",
+				}},
+				Sort: []*SortField{{"version": Asc}},
+			}, ServiceCatalogueIndex)
+			assert.Equal(t, []string{"a", "c"}, serviceIds(t, hits), search)
+		}
+	})
+
+	t.Run("full text search with filters sorted on relevance", func(t *testing.T) {
+		result, err := client.SearchAndGetHits(context.NewCustomContext(&context.CustomContextConfig{}), &Body{
+			Query: &Query{Bool: &BoolQuery{
+				Must: []Query{{MultiMatch: &MultiMatch{
+					Fields:    []string{NameField, DescriptionField},
+					Query:     "payments",
+					Fuzziness: "AUTO",
+				}}},
+				Filter: []Query{{Range: &RangeQuery{"updatedAt": {Gte: "2024-08-02T00:00:00Z"}}}},
+			}},
+			Sort: []*SortField{{"_score": Desc}},
+		}, ServiceCatalogueIndex)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c"}, serviceIds(t, result.Hits))
+		assert.Equal(t, 1, result.Total)
+	})
+
+	t.Run("full text search of a phrase", func(t *testing.T) {
+		phrase := func(query string) []any {
+			return searchHits(t, client, &Body{
+				Query: &Query{Bool: &BoolQuery{
+					MustNot: []Query{{MultiMatch: &MultiMatch{Fields: []string{NameField, DescriptionField}, Query: "double entry", Type: MultiMatchPhrase}}},
+					Must:    []Query{{MultiMatch: &MultiMatch{Fields: []string{NameField, DescriptionField}, Query: query, Type: MultiMatchPhrase}}},
+				}},
+			}, ServiceCatalogueIndex)
+		}
+		assert.Equal(t, []string{"a"}, serviceIds(t, phrase("card payments")))
+		assert.Empty(t, phrase("payments card"))
+		assert.Empty(t, phrase("ledger for payments"), "must not phrases leave documents out")
+	})
+
+	t.Run("full text search is scoped to the index", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Query: &Query{MultiMatch: &MultiMatch{Fields: []string{NameField}, Query: "ledger"}},
+		}, ServiceCatalogueVersionIndex)
+		assert.Empty(t, hits)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		hits := searchHits(t, client, &Body{
+			Sort: []*SortField{{"version": Asc}},
+			From: 1,
+			Size: HitsSize(1),
+		}, ServiceCatalogueIndex)
+		assert.Equal(t, []string{"c"}, serviceIds(t, hits))
+	})
+}
+
+func TestSQLiteClient_TermsMatchMemory(t *testing.T) {
+	sqlite, _ := seedSQLiteClient(t)
+	memory, _ := seedMemoryClient(t)
+
+	for name, term := range map[string]TermQuery{
+		"keyword field":    {"serviceId.keyword": {Value: "b"}},
+		"text field":       {"name": {Value: "payments"}},
+		"text field value": {"description": {Value: "double entry ledger for payments"}},
+		"date field":       {"updatedAt": {Value: "2024-08-01T12:00:00+02:00"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			body := &Body{Query: &Query{Bool: &BoolQuery{Filter: []Query{{Term: &term}}}}}
+			expected := serviceIds(t, searchHits(t, memory, body, ServiceCatalogueIndex))
+			assert.NotEmpty(t, expected)
+			assert.Equal(t, expected, serviceIds(t, searchHits(t, sqlite, body, ServiceCatalogueIndex)))
+		})
+	}
+}
+
+func TestSQLiteClient_UpdateAndDelete(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	client, ids := seedSQLiteClient(t)
+
+	update, err := json.Marshal(&UpdateBody{Doc: map[string]any{"name": "acquiring"}})
+	require.NoError(t, err)
+	require.NoError(t, client.UpdateDocument(cctx, update, ServiceCatalogueIndex, ids["a"], nil))
+
+	search := &Body{Query: &Query{MultiMatch: &MultiMatch{Fields: []string{NameField}, Query: "acquiring"}}}
+	hits := searchHits(t, client, search, ServiceCatalogueIndex)
+	assert.Equal(t, []string{"a"}, serviceIds(t, hits))
+	source := hits[0].(map[string]any)["_source"].(map[string]any)
+	assert.Equal(t, "processes card payments for checkout", source["description"])
+
+	require.NoError(t, client.DeleteDocument(cctx, ServiceCatalogueIndex, ids["a"], nil))
+	hits = searchHits(t, client, search, ServiceCatalogueIndex)
+	assert.Empty(t, hits)
+	assert.Error(t, client.DeleteDocument(cctx, ServiceCatalogueIndex, ids["a"], nil))
+}
+
+func TestSQLiteClient_ConditionalWrites(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	client, ids := seedSQLiteClient(t)
+	fetch := &Body{
+		Query:            &Query{Term: &TermQuery{"serviceId.keyword": {Value: "a"}}},
+		SeqNoPrimaryTerm: true,
+	}
+
+	hits := searchHits(t, client, fetch, ServiceCatalogueIndex)
+	revision, err := RevisionFromHit(hits[0].(map[string]any))
+	require.NoError(t, err)
+
+	update, err := json.Marshal(&UpdateBody{Doc: map[string]any{"version": 2}})
+	require.NoError(t, err)
+	require.NoError(t, client.UpdateDocument(cctx, update, ServiceCatalogueIndex, ids["a"], revision))
+
+	err = client.UpdateDocument(cctx, update, ServiceCatalogueIndex, ids["a"], revision)
+	assert.ErrorIs(t, err, VersionConflictErr)
+	err = client.DeleteDocument(cctx, ServiceCatalogueIndex, ids["a"], revision)
+	assert.ErrorIs(t, err, VersionConflictErr)
+}
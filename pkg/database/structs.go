@@ -1,10 +1,16 @@
 package database
 
 var (
-	NameField                    = "name"
-	DescriptionField             = "description"
+	NameField        = "name"
+	DescriptionField = "description"
+	// NameSuggestField is the search_as_you_type sub field of the name, matched as you type along with its shingles
+	NameSuggestField             = "name.suggest"
+	NameSuggestFields            = []string{NameSuggestField, NameSuggestField + "._2gram", NameSuggestField + "._3gram"}
 	ServiceCatalogueIndex        = "servicecatalogue"
 	ServiceCatalogueVersionIndex = "servicecatalogueversions"
+	ServiceCatalogueChangeIndex  = "servicecataloguechanges"
+	TeamIndex                    = "teams"
+	AttributesSchemaIndex        = "attributesschemas"
 )
 
 var (
@@ -12,6 +18,29 @@ var (
 	Desc SortOrder = "desc"
 )
 
+var (
+	// MultiMatchBoolPrefix matches every term of the query but the last one exactly, and the last one as a prefix
+	MultiMatchBoolPrefix = "bool_prefix"
+	// MultiMatchPhrase matches all the terms of the query next to each other and in order, fuzziness is not allowed
+	MultiMatchPhrase = "phrase"
+	OperatorAnd      = "and"
+	OperatorOr       = "or"
+)
+
+// HighlightEncoderHTML escapes the text of the highlight fragments as html, the tags are left as they are
+var HighlightEncoderHTML = "html"
+
+// HitsSize is the Size of a Body asking for size hits
+func HitsSize(size int) *int {
+	return &size
+}
+
+var (
+	CalendarDay   = "day"
+	CalendarMonth = "month"
+	CalendarYear  = "year"
+)
+
 type (
 	// SortOrder a custom type for sort in elasticsearch
 	SortOrder string
@@ -21,7 +50,91 @@ type (
 		Query *Query       `json:"query,omitempty"`
 		Sort  []*SortField `json:"sort,omitempty"`
 		From  int          `json:"from,omitempty"`
-		Size  int          `json:"size,omitempty"`
+		// Size is the number of hits, nil asks for the default 10 hits and 0 for none, see HitsSize
+		Size *int `json:"size,omitempty"`
+		// SeqNoPrimaryTerm returns `_seq_no` and `_primary_term` of every hit, see RevisionFromHit
+		SeqNoPrimaryTerm bool `json:"seq_no_primary_term,omitempty"`
+		// SearchAfter returns the hits sorted after these values, taken from `sort` of the last hit of a page
+		SearchAfter []any `json:"search_after,omitempty"`
+		// Pit searches a point in time opened with OpenPointInTime instead of the latest data
+		Pit *PointInTime `json:"pit,omitempty"`
+		// TrackTotalHits counts all the matching documents, elasticsearch stops counting at 10000 otherwise
+		TrackTotalHits bool `json:"track_total_hits,omitempty"`
+		// Aggs are computed over all the documents matching the query irrespective of pagination, by name
+		Aggs map[string]*Aggregation `json:"aggs,omitempty"`
+		// Source are the fields of the `_source` returned with every hit, all of them when empty
+		Source []string `json:"_source,omitempty"`
+		// Highlight returns the fragments of the fields matching the full text queries under `highlight` of every hit
+		Highlight *Highlight `json:"highlight,omitempty"`
+	}
+
+	// Highlight wraps the terms matching the query in the tags, `<em>` and `</em>` when not set. The text is
+	// returned as it is unless Encoder is HighlightEncoderHTML.
+	Highlight struct {
+		Fields   map[string]*HighlightField `json:"fields"`
+		PreTags  []string                   `json:"pre_tags,omitempty"`
+		PostTags []string                   `json:"post_tags,omitempty"`
+		Encoder  string                     `json:"encoder,omitempty"`
+	}
+
+	// HighlightField tells how the fragments of a field are cut, elasticsearch returns at most 5 fragments
+	// of about 100 characters when not set
+	HighlightField struct {
+		FragmentSize      int `json:"fragment_size,omitempty"`
+		NumberOfFragments int `json:"number_of_fragments,omitempty"`
+	}
+
+	// SearchResult is the outcome of a search
+	SearchResult struct {
+		// Hits are the hits of the requested page in the elasticsearch shape
+		Hits []any
+		// Total is the number of documents matching the query irrespective of pagination
+		Total int
+		// TotalRelation is `eq` when Total is exact and `gte` when it is a lower bound
+		TotalRelation string
+		// Aggregations are the results of the Aggs of the body by name
+		Aggregations map[string]*AggregationResult
+	}
+
+	// Aggregation is a bucket aggregation, exactly one of its members is set
+	Aggregation struct {
+		Terms         *TermsAggregation         `json:"terms,omitempty"`
+		DateHistogram *DateHistogramAggregation `json:"date_histogram,omitempty"`
+	}
+
+	// TermsAggregation counts the documents per value of the field, the most frequent values first
+	TermsAggregation struct {
+		Field string `json:"field"`
+		// Size is the number of buckets returned, elasticsearch returns 10 when not set
+		Size int `json:"size,omitempty"`
+		// Missing is the value documents without the field are counted under, they are not counted when empty
+		Missing string `json:"missing,omitempty"`
+	}
+
+	// DateHistogramAggregation counts the documents per calendar interval of a date field, oldest first
+	DateHistogramAggregation struct {
+		Field string `json:"field"`
+		// CalendarInterval is one of CalendarDay, CalendarMonth or CalendarYear
+		CalendarInterval string `json:"calendar_interval"`
+	}
+
+	// AggregationResult holds the buckets of an aggregation in the elasticsearch shape
+	AggregationResult struct {
+		Buckets []*Bucket `json:"buckets"`
+	}
+
+	// Bucket is a value of a terms aggregation, or the start of an interval in epoch milliseconds for a
+	// date histogram, along with the number of documents it holds
+	Bucket struct {
+		Key         any    `json:"key"`
+		KeyAsString string `json:"key_as_string,omitempty"`
+		DocCount    int    `json:"doc_count"`
+	}
+
+	// PointInTime represents a point in time to search
+	PointInTime struct {
+		Id        string `json:"id"`
+		KeepAlive string `json:"keep_alive,omitempty"`
 	}
 
 	// Query represents a generic Elasticsearch query structure
@@ -29,8 +142,11 @@ type (
 		Match         *MatchQuery         `json:"match,omitempty"`
 		MultiMatch    *MultiMatch         `json:"multi_match,omitempty"`
 		Term          *TermQuery          `json:"term,omitempty"`
+		Terms         *TermsQuery         `json:"terms,omitempty"`
+		Exists        *ExistsQuery        `json:"exists,omitempty"`
 		Range         *RangeQuery         `json:"range,omitempty"`
 		Bool          *BoolQuery          `json:"bool,omitempty"`
+		Nested        *NestedQuery        `json:"nested,omitempty"`
 		FunctionScore *FunctionScoreQuery `json:"function_score,omitempty"`
 		Script        *ScriptQuery        `json:"script,omitempty"`
 	}
@@ -45,7 +161,11 @@ type (
 	MultiMatch struct {
 		Fields    []string `json:"fields"`
 		Query     string   `json:"query"`
-		Fuzziness string   `json:"fuzziness"`
+		Fuzziness string   `json:"fuzziness,omitempty"`
+		// Type is best_fields when empty, MultiMatchBoolPrefix or MultiMatchPhrase
+		Type string `json:"type,omitempty"`
+		// Operator combines the terms of the query, OperatorOr when empty
+		Operator string `json:"operator,omitempty"`
 	}
 
 	// TermQuery represents a term query
@@ -55,19 +175,37 @@ type (
 		Value any `json:"value"`
 	}
 
-	// RangeQuery represents a range query
+	// TermsQuery matches documents where the field has any of the values
+	TermsQuery map[string][]any
+
+	// ExistsQuery matches documents which have a value for the field
+	ExistsQuery struct {
+		Field string `json:"field"`
+	}
+
+	// RangeQuery represents a range query, Gt and Lt leave out the bound itself
 	RangeQuery map[string]struct {
+		Gt  any `json:"gt,omitempty"`
 		Gte any `json:"gte,omitempty"`
+		Lt  any `json:"lt,omitempty"`
 		Lte any `json:"lte,omitempty"`
 	}
 
-	// BoolQuery represents a boolean query
+	// BoolQuery represents a boolean query. Filter clauses have to match like must clauses but do not score.
 	BoolQuery struct {
 		Must    []Query `json:"must,omitempty"`
+		Filter  []Query `json:"filter,omitempty"`
 		Should  []Query `json:"should,omitempty"`
 		MustNot []Query `json:"must_not,omitempty"`
 	}
 
+	// NestedQuery matches documents where one object of the nested field matches the query on its own.
+	// Fields of the query are referred to with their full path.
+	NestedQuery struct {
+		Path  string `json:"path"`
+		Query Query  `json:"query"`
+	}
+
 	// FunctionScoreQuery represents a function score query
 	FunctionScoreQuery struct {
 		Query     Query      `json:"query"`
@@ -90,4 +228,10 @@ type (
 	UpdateBody struct {
 		Doc map[string]any `json:"doc,omitempty"`
 	}
+
+	// Revision identifies the state of a document for optimistic concurrency control
+	Revision struct {
+		SeqNo       int
+		PrimaryTerm int
+	}
 )
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/internal/services"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FetchAttributesSchema returns the JSON schema the attributes of services are validated against
+func (h *Handler) FetchAttributesSchema(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	resp, err := h.Svc.FetchAttributesSchema(cctx)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		if errors.Is(err, services.NoDocumentFoundErr) {
+			c.Status(http.StatusBadRequest)
+			_ = c.Error(err)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &services.AttributesSchemaResponse{
+		AttributesSchema: resp,
+		TimeStamp:        time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// SaveAttributesSchema replaces the attributes schema with the JSON schema sent as the request body. The
+// attributes it declares become filterable and sortable, an attribute can not change its type once declared.
+// Only admins may save it, the attributes are mapped on the indices shared by all the organizations.
+func (h *Handler) SaveAttributesSchema(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	if !cctx.HasRole(config.RoleAdmin) {
+		err := fmt.Errorf("the attributes schema can only be saved by an `%s`", config.RoleAdmin)
+		cctx.Logger().ERROR("FORBIDDEN", tag.NewErrorTag(err))
+		c.Status(http.StatusForbidden)
+		_ = c.Error(err)
+		return
+	}
+
+	userId := c.GetHeader(userRequestHeader)
+	if userId == "" {
+		err := fmt.Errorf("missing `%s` header", userRequestHeader)
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	schemaBytes, err := c.GetRawData()
+	if err != nil {
+		cctx.Logger().ERROR("REQUEST_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, err := h.Svc.SaveAttributesSchema(cctx, schemaBytes, userId)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		if errors.Is(err, services.InvalidAttributesSchemaErr) {
+			c.Status(http.StatusBadRequest)
+			_ = c.Error(err)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &services.AttributesSchemaResponse{
+		AttributesSchema: resp,
+		TimeStamp:        time.Now().UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"nikki-noceps/serviceCatalogue/internal/services"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	keyDepthQueryParam  = "depth"
+	keyFormatQueryParam = "format"
+)
+
+// ListServiceDependencies returns the services a service depends on, up to `depth` hops away
+func (h *Handler) ListServiceDependencies(c *gin.Context) {
+	h.dependencyGraph(c, services.Dependencies)
+}
+
+// ListServiceDependents returns the services depending on a service, up to `depth` hops away
+func (h *Handler) ListServiceDependents(c *gin.Context) {
+	h.dependencyGraph(c, services.Dependents)
+}
+
+func (h *Handler) dependencyGraph(c *gin.Context, direction services.DependencyDirection) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	serviceId := c.Param(keyServiceIdPathParam)
+
+	graphParams := &services.DependencyGraphParameters{}
+	if err := getDependencyGraphQueryParams(c.Request.URL.Query(), graphParams); err != nil {
+		cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	graphParams.AddDefaultsIfEmpty()
+	if err := graphParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	graph, err := h.Svc.DependencyGraph(cctx, serviceId, direction, *graphParams.Depth)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		if errors.Is(err, services.NoDocumentFoundErr) {
+			c.Status(http.StatusBadRequest)
+			_ = c.Error(err)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &services.DependencyGraphResponse{
+		DependencyGraph: graph,
+		TimeStamp:       time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+func getDependencyGraphQueryParams(queryParams url.Values, graphParams *services.DependencyGraphParameters) error {
+	if value := queryParams.Get(keyDepthQueryParam); value != "" {
+		depth, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid query params: %w", err)
+		}
+		graphParams.Depth = &depth
+	}
+	return nil
+}
+
+// ServiceImpact returns the services affected when a service goes down. With `format=dot` or `format=mermaid`
+// the affected part of the dependency graph is rendered instead, ready to be pasted into a postmortem.
+func (h *Handler) ServiceImpact(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	serviceId := c.Param(keyServiceIdPathParam)
+
+	impactParams := &services.ImpactParameters{}
+	if err := getImpactQueryParams(c.Request.URL.Query(), impactParams); err != nil {
+		cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	impactParams.AddDefaultsIfEmpty()
+	if err := impactParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	impact, err := h.Svc.ImpactAnalysis(cctx, serviceId, *impactParams.Depth)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		if errors.Is(err, services.NoDocumentFoundErr) {
+			c.Status(http.StatusBadRequest)
+			_ = c.Error(err)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	switch impactParams.Format {
+	case services.ImpactFormatDOT:
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(impact.Graph.DOT()))
+	case services.ImpactFormatMermaid:
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(impact.Graph.Mermaid()))
+	default:
+		c.JSON(http.StatusOK, &services.ImpactResponse{
+			Impact:    impact,
+			TimeStamp: time.Now().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+func getImpactQueryParams(queryParams url.Values, impactParams *services.ImpactParameters) error {
+	if value := queryParams.Get(keyDepthQueryParam); value != "" {
+		depth, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid query params: %w", err)
+		}
+		impactParams.Depth = &depth
+	}
+	impactParams.Format = queryParams.Get(keyFormatQueryParam)
+	return nil
+}
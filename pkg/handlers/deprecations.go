@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/internal/services"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	keyPastSunsetQueryParam = "pastSunset"
+	deprecationHeader       = "Deprecation"
+	sunsetHeader            = "Sunset"
+	linkHeader              = "Link"
+)
+
+// ListDeprecations reports the deprecated services sorted by sunset date, paged like ListSvcCatalogue.
+// Services past their sunset which still have dependents are flagged as overdue.
+func (h *Handler) ListDeprecations(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	listParams := &services.ListDeprecationsParameters{}
+	if err := getListDeprecationsQueryParams(c.Request.URL.Query(), listParams); err != nil {
+		cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	listParams.AddDefaultsIfEmpty()
+	if err := listParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	deprecations, page, err := h.Svc.ListDeprecations(cctx, listParams)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &services.ListDeprecationsResponse{
+		Deprecations: deprecations,
+		Page:         page,
+		TimeStamp:    time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// setDeprecationHeaders announces a deprecated service with the Deprecation (RFC 9745) and Sunset (RFC 8594)
+// headers, the replacement is linked as the successor
+func setDeprecationHeaders(c *gin.Context, svcCat *services.ServiceCatalogue) {
+	if svcCat.Lifecycle != services.LifecycleDeprecated {
+		return
+	}
+	if deprecatedAt, err := time.Parse(time.RFC3339, svcCat.DeprecatedAt); err == nil {
+		c.Header(deprecationHeader, fmt.Sprintf("@%d", deprecatedAt.Unix()))
+	}
+	if sunsetAt, err := time.Parse(time.RFC3339, svcCat.SunsetAt); err == nil {
+		c.Header(sunsetHeader, sunsetAt.UTC().Format(http.TimeFormat))
+	}
+	if svcCat.ReplacedBy != "" {
+		c.Header(linkHeader, fmt.Sprintf(`</serviceCatalogue/%s>; rel="successor-version"`, url.PathEscape(svcCat.ReplacedBy)))
+	}
+}
+
+// setSearchDeprecationHeaders announces the search endpoint as deprecated once the deprecation is configured, the
+// listing with the same search, which also filters, sorts and pages through cursors, is linked as the successor
+func setSearchDeprecationHeaders(c *gin.Context, deprecation config.Deprecation, search string) {
+	if deprecation.DeprecatedAt.IsZero() {
+		return
+	}
+	c.Header(deprecationHeader, fmt.Sprintf("@%d", deprecation.DeprecatedAt.Unix()))
+	if !deprecation.SunsetAt.IsZero() {
+		c.Header(sunsetHeader, deprecation.SunsetAt.UTC().Format(http.TimeFormat))
+	}
+	c.Header(linkHeader, fmt.Sprintf(`</serviceCatalogue?search=%s>; rel="successor-version"`, url.QueryEscape(search)))
+}
+
+func getListDeprecationsQueryParams(queryParams url.Values, listParams *services.ListDeprecationsParameters) error {
+	for key, values := range queryParams {
+		if len(values) > 0 {
+			var err error
+			switch key {
+			case "from":
+				from := 0
+				from, err = strconv.Atoi(values[0])
+				listParams.From = &from
+			case "size":
+				size := 10
+				size, err = strconv.Atoi(values[0])
+				listParams.Size = &size
+			case keyCursorQueryParam:
+				listParams.Cursor, err = services.DecodeCursor(values[0])
+			case keyPitQueryParam:
+				listParams.Pit, err = strconv.ParseBool(values[0])
+			case keyPastSunsetQueryParam:
+				listParams.PastSunset, err = strconv.ParseBool(values[0])
+			}
+
+			if err != nil {
+				return fmt.Errorf("invalid query params: %w", err)
+			}
+		}
+	}
+	return nil
+}
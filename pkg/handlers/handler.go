@@ -4,39 +4,64 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"nikki-noceps/serviceCatalogue/config"
 	"nikki-noceps/serviceCatalogue/internal/services"
 	"nikki-noceps/serviceCatalogue/pkg/context"
 	"nikki-noceps/serviceCatalogue/pkg/database"
 	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 var (
-	userRequestHeader     = "x-user-id"
-	keyServiceIdPathParam = "serviceId"
-	keyVersionIdPathParam = "versionId"
+	userRequestHeader      = "x-user-id"
+	ifMatchRequestHeader   = "If-Match"
+	eTagResponseHeader     = "ETag"
+	keyServiceIdPathParam  = "serviceId"
+	keyVersionIdPathParam  = "versionId"
+	keyAsOfQueryParam      = "asOf"
+	keyCursorQueryParam    = "cursor"
+	keyOwnerQueryParam     = "owner"
+	keyLabelsQueryParam    = "labels"
+	keyFacetsQueryParam    = "facets"
+	keyLifecycleQueryParam = "lifecycle"
+	keyLinkHostQueryParam  = "linkHost"
+	keyLinkKindQueryParam  = "linkKind"
+	keyQueryQueryParam     = "q"
+	// attributes are filtered on with `attributes.<name>=value1,value2`
+	keyAttributesQueryParamPrefix = "attributes."
+	keyPitQueryParam              = "pit"
+	keyPreTagQueryParam           = "preTag"
+	keyPostTagQueryParam          = "postTag"
 )
 
 type Handler struct {
-	Svc *services.Service
+	Svc          *services.Service
+	Deprecations config.Deprecations
 }
 
-func NewHandler(service *services.Service) *Handler {
+func NewHandler(service *services.Service, deprecations config.Deprecations) *Handler {
 	return &Handler{
-		Svc: service,
+		Svc:          service,
+		Deprecations: deprecations,
 	}
 }
 
 // SearchSvcCatalogue handler parses the request query to do a fuzzy search on name and description field on elasticsearch.
 // Returns list of serviceCatalogues which match the search and error in case of an issues.
-// Sets default from 0 and size as 20 if not specified
+// Sets default from 0 and size as 20 if not specified. Pages beyond are fetched with the `next`/`prev` cursors.
+// The matching words of the name and description are highlighted with `<em>` or the `preTag` and `postTag`.
+// Deprecated in favour of `search` on ListSvcCatalogue, announced through the Deprecation, Sunset and Link headers
+// once `Deprecations.Search` is configured.
 func (h *Handler) SearchSvcCatalogue(c *gin.Context) {
 	cctx := context.CustomContextFromContext(c.Request.Context())
+	setSearchDeprecationHeaders(c, h.Deprecations.Search, c.Query("search"))
 
 	queryParams := c.Request.URL.Query()
 	searchParams := &services.SearchParameters{}
@@ -46,6 +71,7 @@ func (h *Handler) SearchSvcCatalogue(c *gin.Context) {
 		_ = c.Error(err)
 		return
 	}
+	searchParams.AddDefaultsIfEmpty()
 
 	if err := searchParams.Validate(); err != nil {
 		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
@@ -54,20 +80,67 @@ func (h *Handler) SearchSvcCatalogue(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.Svc.FuzzySearchService(cctx, searchParams)
+	resp, page, err := h.Svc.FuzzySearchService(cctx, searchParams)
 	if err != nil {
 		c.Status(http.StatusInternalServerError)
 		_ = c.Error(err)
 		return
 	}
 
-	searchResponse := generateListResponseFromDBResponse(resp)
+	searchResponse := generateListResponseFromDBResponse(resp, page)
+	if searchParams.Facets {
+		searchResponse.Facets, err = h.Svc.SearchFacets(cctx, searchParams)
+		if err != nil {
+			cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+			c.Status(http.StatusInternalServerError)
+			_ = c.Error(err)
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, searchResponse)
 }
 
+// SuggestSvcCatalogue handler completes the names of the services as they are typed in a search box, with the
+// typed prefixes highlighted. Returns 5 suggestions unless `size` asks for up to 10.
+func (h *Handler) SuggestSvcCatalogue(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	queryParams := c.Request.URL.Query()
+	suggestParams := &services.SuggestParameters{}
+	if err := getSuggestQueryParams(queryParams, suggestParams); err != nil {
+		cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	suggestParams.AddDefaultsIfEmpty()
+
+	if err := suggestParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	suggestions, err := h.Svc.Suggest(cctx, suggestParams)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &services.SuggestResponse{Suggestions: suggestions})
+}
+
 // ListSvcCatalogue handler parses request query, validates the request query and handles pagination and sorting
-// of serviceCatalogue search request to elasticsearch
+// of serviceCatalogue search request to elasticsearch. The `next`/`prev` cursors in the response page through the
+// whole catalogue, `pit=true` keeps the pages consistent with the catalogue at the first page. `search` fuzzy
+// matches the name and description on top of the filters, sorted on `relevance` unless sorted otherwise, with the
+// matching words highlighted like SearchSvcCatalogue. `q` takes a query string like
+// `owner:payments "card vault" -deprecated updatedAt>2026-01-01`, see services.ParseQuery. The `timewindow` spans
+// the last 30 days when not set, unless there is a `search` or `q` filters on the dates.
 // Returns list of serviceCatalogues and error in case of an issues.
 func (h *Handler) ListSvcCatalogue(c *gin.Context) {
 	cctx := context.CustomContextFromContext(c.Request.Context())
@@ -88,15 +161,39 @@ func (h *Handler) ListSvcCatalogue(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.Svc.ListAllServices(cctx, listParams)
+	resp, page, err := h.Svc.ListAllServices(cctx, listParams)
 	if err != nil {
 		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		if errors.Is(err, services.InvalidAttributeFilterErr) {
+			c.Status(http.StatusBadRequest)
+			_ = c.Error(err)
+			return
+		}
+		if errors.Is(err, services.SnapshotTooLargeErr) {
+			c.Status(http.StatusUnprocessableEntity)
+			_ = c.Error(err)
+			return
+		}
 		c.Status(http.StatusInternalServerError)
 		_ = c.Error(err)
 		return
 	}
 
-	searchResponse := generateListResponseFromDBResponse(resp)
+	searchResponse := generateListResponseFromDBResponse(resp, page)
+	if listParams.Facets {
+		searchResponse.Facets, err = h.Svc.ListFacets(cctx, listParams)
+		if err != nil {
+			cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+			if errors.Is(err, services.SnapshotTooLargeErr) {
+				c.Status(http.StatusUnprocessableEntity)
+				_ = c.Error(err)
+				return
+			}
+			c.Status(http.StatusInternalServerError)
+			_ = c.Error(err)
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, searchResponse)
 }
@@ -138,29 +235,46 @@ func (h *Handler) CreateSvcCatalogue(c *gin.Context) {
 			ServiceId:   resp.ServiceId,
 			Name:        resp.Name,
 			Description: resp.Description,
+			Ownership:   resp.Ownership,
+			Labels:      resp.Labels,
+			Attributes:  resp.Attributes,
+			DependsOn:   resp.DependsOn,
+			Links:       resp.Links,
+			Lifecycle:   resp.Lifecycle,
+			Deprecation: resp.Deprecation,
 			Version:     resp.Version,
 			CreatedAt:   resp.CreatedAt,
 			UpdatedAt:   resp.UpdatedAt,
 			CreatedBy:   resp.CreatedBy,
 			UpdatedBy:   resp.UpdatedBy,
+			Warnings:    resp.Warnings,
 		},
 		TimeStamp: time.Now().Format(time.RFC3339),
 	}
 
+	c.Header(eTagResponseHeader, formatETag(resp.Version))
 	c.JSON(http.StatusCreated, serviceCatalogueResp)
 }
 
 // UpdateSvcCatalogue updates the elasticsearch document and stores the older document as a version in another
-// index called servicecatalogueversion
+// index called servicecatalogueversion. An If-Match header carrying the ETag of the service makes the update
+// conditional, a stale ETag fails with 412.
 func (h *Handler) UpdateSvcCatalogue(c *gin.Context) {
 	cctx := context.CustomContextFromContext(c.Request.Context())
 
 	serviceId := c.Param(keyServiceIdPathParam)
+	expectedVersions, err := parseIfMatch(c.GetHeader(ifMatchRequestHeader))
+	if err != nil {
+		cctx.Logger().ERROR("HEADER_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(concurrencyErrorStatus(err, http.StatusBadRequest))
+		_ = c.Error(err)
+		return
+	}
 
 	serviceCatalogueReq := &services.UpdateServiceCatalogueRequest{
 		ServiceId: serviceId,
 	}
-	err := c.ShouldBindJSON(serviceCatalogueReq)
+	err = c.ShouldBindJSON(serviceCatalogueReq)
 	if err != nil {
 		cctx.Logger().ERROR("REQUEST_PARSING_FAILED", tag.NewErrorTag(err))
 		c.Status(http.StatusBadRequest)
@@ -178,10 +292,15 @@ func (h *Handler) UpdateSvcCatalogue(c *gin.Context) {
 	}
 
 	svcCatalogue := serviceCatalogueReq.RequestStructToServiceStruct(cctx)
-	resp, err := h.Svc.UpdateServiceCatalogue(cctx, svcCatalogue)
+	resp, err := h.Svc.UpdateServiceCatalogue(cctx, svcCatalogue, expectedVersions)
 	if err != nil {
 		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
-		c.Status(http.StatusBadRequest)
+		if errors.Is(err, services.InvalidLifecycleTransitionErr) {
+			c.Status(http.StatusConflict)
+			_ = c.Error(err)
+			return
+		}
+		c.Status(concurrencyErrorStatus(err, http.StatusBadRequest))
 		_ = c.Error(err)
 		return
 	}
@@ -191,31 +310,42 @@ func (h *Handler) UpdateSvcCatalogue(c *gin.Context) {
 			ServiceId:   resp.ServiceId,
 			Name:        resp.Name,
 			Description: resp.Description,
+			Ownership:   resp.Ownership,
+			Labels:      resp.Labels,
+			Attributes:  resp.Attributes,
+			DependsOn:   resp.DependsOn,
+			Links:       resp.Links,
+			Lifecycle:   resp.Lifecycle,
+			Deprecation: resp.Deprecation,
 			Version:     resp.Version,
 			CreatedAt:   resp.CreatedAt,
 			UpdatedAt:   resp.UpdatedAt,
 			CreatedBy:   resp.CreatedBy,
-			UpdatedBy:   serviceCatalogueReq.UpdatedBy,
+			UpdatedBy:   resp.UpdatedBy,
+			Warnings:    resp.Warnings,
 		},
 		TimeStamp: time.Now().Format(time.RFC3339),
 	}
-	if serviceCatalogueReq.Name != "" {
-		serviceCatalogueResp.Name = serviceCatalogueReq.Name
-	}
-	if serviceCatalogueReq.Description != "" {
-		serviceCatalogueResp.Description = serviceCatalogueReq.Description
-	}
+	c.Header(eTagResponseHeader, formatETag(resp.Version))
 	c.JSON(http.StatusOK, serviceCatalogueResp)
 }
 
-// DeleteService deletes document from servicecatalogue index but also stores a copy in the servicecatalogueversion index
+// DeleteService deletes document from servicecatalogue index but also stores a copy in the servicecatalogueversion index.
+// Honours If-Match the same way as UpdateSvcCatalogue.
 func (h *Handler) DeleteService(c *gin.Context) {
 	cctx := context.CustomContextFromContext(c.Request.Context())
 
 	serviceId := c.Param(keyServiceIdPathParam)
 	userId := c.GetHeader(userRequestHeader)
+	expectedVersions, err := parseIfMatch(c.GetHeader(ifMatchRequestHeader))
+	if err != nil {
+		cctx.Logger().ERROR("HEADER_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(concurrencyErrorStatus(err, http.StatusBadRequest))
+		_ = c.Error(err)
+		return
+	}
 
-	err := h.Svc.DeleteService(cctx, serviceId, userId)
+	err = h.Svc.DeleteService(cctx, serviceId, userId, expectedVersions)
 	if err != nil {
 		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
 		if errors.Is(err, services.NoDocumentFoundErr) {
@@ -223,20 +353,35 @@ func (h *Handler) DeleteService(c *gin.Context) {
 			_ = c.Error(err)
 			return
 		}
-		c.Status(http.StatusInternalServerError)
+		c.Status(concurrencyErrorStatus(err, http.StatusInternalServerError))
 		_ = c.Error(err)
 		return
 	}
 	c.JSON(http.StatusAccepted, nil)
 }
 
-// FetchServiceById fetches a single document from servicecatalogue index
+// FetchServiceById fetches a single document from servicecatalogue index. With the asOf query parameter the
+// service is fetched as it was at that point in time, which also finds services deleted since.
+// Deprecated services are announced through the Deprecation and Sunset headers.
 func (h *Handler) FetchServiceById(c *gin.Context) {
 	cctx := context.CustomContextFromContext(c.Request.Context())
 
 	serviceId := c.Param(keyServiceIdPathParam)
 
-	resp, err := h.Svc.FetchServiceById(cctx, serviceId)
+	var resp *services.ServiceCatalogue
+	var err error
+	asOf, isAsOf := c.GetQuery(keyAsOfQueryParam)
+	if isAsOf {
+		if _, err := time.Parse(time.RFC3339, asOf); err != nil {
+			cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+			c.Status(http.StatusBadRequest)
+			_ = c.Error(fmt.Errorf("invalid query params: %s must be in RFC3339 format", keyAsOfQueryParam))
+			return
+		}
+		resp, err = h.Svc.FetchServiceByIdAsOf(cctx, serviceId, asOf)
+	} else {
+		resp, err = h.Svc.FetchServiceById(cctx, serviceId)
+	}
 	if err != nil {
 		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
 		if errors.Is(err, services.NoDocumentFoundErr) {
@@ -252,21 +397,132 @@ func (h *Handler) FetchServiceById(c *gin.Context) {
 		ServiceId:   resp.ServiceId,
 		Name:        resp.Name,
 		Description: resp.Description,
+		Ownership:   resp.Ownership,
+		Labels:      resp.Labels,
+		Attributes:  resp.Attributes,
+		DependsOn:   resp.DependsOn,
+		Links:       resp.Links,
+		Lifecycle:   resp.Lifecycle,
+		Deprecation: resp.Deprecation,
 		Version:     resp.Version,
 		CreatedAt:   resp.CreatedAt,
 		UpdatedAt:   resp.UpdatedAt,
 		CreatedBy:   resp.CreatedBy,
 		UpdatedBy:   resp.UpdatedBy,
 	}
+	// only the live document can be used as a precondition
+	if !isAsOf {
+		c.Header(eTagResponseHeader, formatETag(resp.Version))
+	}
+	setDeprecationHeaders(c, resp)
 	c.JSON(http.StatusOK, serviceCatalogueResp)
 }
 
+// ListDeletedServices lists the tombstones of deleted services which can be restored
+func (h *Handler) ListDeletedServices(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	listParams := &services.ListDeletedParameters{}
+	if err := getListDeletedQueryParams(c.Request.URL.Query(), listParams); err != nil {
+		cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	listParams.AddDefaultsIfEmpty()
+	if err := listParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, page, err := h.Svc.ListDeletedServices(cctx, listParams)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, generateVersionListFromDBResponse(resp, page))
+}
+
+// RestoreDeletedService makes a deleted service live again with its original serviceId and version history.
+// The optional body re-assigns the service to another owner team.
+func (h *Handler) RestoreDeletedService(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	serviceId := c.Param(keyServiceIdPathParam)
+	userId := c.GetHeader(userRequestHeader)
+	if userId == "" {
+		err := fmt.Errorf("missing `%s` header", userRequestHeader)
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	restoreReq := &services.RestoreDeletedServiceRequest{}
+	if err := bindOptionalJSON(c, restoreReq); err != nil {
+		cctx.Logger().ERROR("REQUEST_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, err := h.Svc.RestoreDeletedService(cctx, serviceId, userId, restoreReq.OwnerTeam)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		switch {
+		case errors.Is(err, services.NoDocumentFoundErr), errors.Is(err, services.InvalidDependencyErr),
+			errors.Is(err, services.InvalidAttributesErr), errors.Is(err, services.UnknownTeamErr):
+			c.Status(http.StatusBadRequest)
+		case errors.Is(err, services.ServiceNotDeletedErr), errors.Is(err, services.OwnerTeamGoneErr):
+			c.Status(http.StatusConflict)
+		default:
+			c.Status(concurrencyErrorStatus(err, http.StatusInternalServerError))
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	serviceCatalogueResp := &services.CreateServiceCatalogueResponse{
+		ServiceCatalogueResponse: &services.ServiceCatalogueResponse{
+			ServiceId:   resp.ServiceId,
+			Name:        resp.Name,
+			Description: resp.Description,
+			Ownership:   resp.Ownership,
+			Labels:      resp.Labels,
+			Attributes:  resp.Attributes,
+			DependsOn:   resp.DependsOn,
+			Links:       resp.Links,
+			Lifecycle:   resp.Lifecycle,
+			Deprecation: resp.Deprecation,
+			Version:     resp.Version,
+			CreatedAt:   resp.CreatedAt,
+			UpdatedAt:   resp.UpdatedAt,
+			CreatedBy:   resp.CreatedBy,
+			UpdatedBy:   resp.UpdatedBy,
+			Warnings:    resp.Warnings,
+		},
+		TimeStamp: time.Now().Format(time.RFC3339),
+	}
+
+	c.Header(eTagResponseHeader, formatETag(resp.Version))
+	c.JSON(http.StatusCreated, serviceCatalogueResp)
+}
+
 // Parses the query parameters and generates struct object for list all services function
 func getListQueryParams(queryParams url.Values, listParams *services.ListParameters) error {
 	for key, values := range queryParams {
 		if len(values) > 0 {
 			var err error
 			switch key {
+			case "search":
+				listParams.Search = values[0]
+			case keyQueryQueryParam:
+				listParams.Query, err = services.ParseQuery(values[0])
 			case "sort":
 				sortField := []*database.SortField{}
 				err = json.Unmarshal([]byte(values[0]), &sortField)
@@ -285,6 +541,35 @@ func getListQueryParams(queryParams url.Values, listParams *services.ListParamet
 				listParams.TimeWindow = timeWindow
 			case "timestampfield":
 				listParams.TimeStampField = values[0]
+			case keyAsOfQueryParam:
+				listParams.AsOf = values[0]
+			case keyCursorQueryParam:
+				listParams.Cursor, err = services.DecodeCursor(values[0])
+			case keyPitQueryParam:
+				listParams.Pit, err = strconv.ParseBool(values[0])
+			case keyOwnerQueryParam:
+				listParams.Owner = values[0]
+			case keyLabelsQueryParam:
+				listParams.LabelSelector, err = services.ParseLabelSelector(values[0])
+			case keyFacetsQueryParam:
+				listParams.Facets, err = strconv.ParseBool(values[0])
+			case keyLifecycleQueryParam:
+				listParams.Lifecycle = strings.Split(values[0], ",")
+			case keyLinkHostQueryParam:
+				listParams.LinkHost = strings.Split(values[0], ",")
+			case keyLinkKindQueryParam:
+				listParams.LinkKind = values[0]
+			case keyPreTagQueryParam:
+				listParams.PreTag = values[0]
+			case keyPostTagQueryParam:
+				listParams.PostTag = values[0]
+			default:
+				if name, ok := strings.CutPrefix(key, keyAttributesQueryParamPrefix); ok {
+					if listParams.Attributes == nil {
+						listParams.Attributes = map[string][]string{}
+					}
+					listParams.Attributes[name] = strings.Split(values[0], ",")
+				}
 			}
 
 			if err != nil {
@@ -302,6 +587,16 @@ func getSearchQueryParams(queryParams url.Values, searchParams *services.SearchP
 			switch key {
 			case "search":
 				searchParams.Search = values[0]
+			case keyCursorQueryParam:
+				searchParams.Cursor, err = services.DecodeCursor(values[0])
+			case keyPitQueryParam:
+				searchParams.Pit, err = strconv.ParseBool(values[0])
+			case keyFacetsQueryParam:
+				searchParams.Facets, err = strconv.ParseBool(values[0])
+			case keyPreTagQueryParam:
+				searchParams.PreTag = values[0]
+			case keyPostTagQueryParam:
+				searchParams.PostTag = values[0]
 			case "from":
 				from := 0
 				from, err = strconv.Atoi(values[0])
@@ -320,28 +615,168 @@ func getSearchQueryParams(queryParams url.Values, searchParams *services.SearchP
 	return nil
 }
 
-func generateListResponseFromDBResponse(resp []*services.ServiceCatalogue) *services.ListServiceCatalogueResponse {
+func getSuggestQueryParams(queryParams url.Values, suggestParams *services.SuggestParameters) error {
+	for key, values := range queryParams {
+		if len(values) > 0 {
+			var err error
+			switch key {
+			case "q":
+				suggestParams.Q = values[0]
+			case "size":
+				size := 0
+				size, err = strconv.Atoi(values[0])
+				suggestParams.Size = &size
+			}
+
+			if err != nil {
+				return fmt.Errorf("invalid query params: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// formatETag generates a strong entity tag from the version of a service
+func formatETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// parseIfMatch parses the If-Match header into the versions of the service the client expects, any one of them
+// matches. Returns none when the header is empty or "*" which means any version. If-Match compares strongly so a
+// weak ETag never matches, a header of only weak ETags fails with services.VersionMismatchErr.
+func parseIfMatch(header string) ([]int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	versions := []int{}
+	for _, eTag := range strings.Split(header, ",") {
+		eTag = strings.TrimSpace(eTag)
+		if eTag == "" {
+			continue
+		}
+		if strings.HasPrefix(eTag, "W/") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(eTag); err == nil {
+			eTag = unquoted
+		}
+		version, err := strconv.Atoi(eTag)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid %s header: %q", ifMatchRequestHeader, header)
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: the weak ETags %s never match in an %s header", services.VersionMismatchErr, header, ifMatchRequestHeader)
+	}
+	return versions, nil
+}
+
+// bindOptionalJSON binds the JSON body of the request when there is one
+func bindOptionalJSON(c *gin.Context, obj any) error {
+	if err := c.ShouldBindJSON(obj); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// concurrencyErrorStatus maps failed preconditions to 412 and concurrent modifications to 409
+func concurrencyErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.VersionMismatchErr):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, services.ConcurrentModificationErr):
+		return http.StatusConflict
+	}
+	return fallback
+}
+
+func getListVersionsQueryParams(queryParams url.Values, listParams *services.ListVersionsParameters) error {
+	for key, values := range queryParams {
+		if len(values) > 0 {
+			var err error
+			switch key {
+			case "from":
+				from := 0
+				from, err = strconv.Atoi(values[0])
+				listParams.From = &from
+			case "size":
+				size := 10
+				size, err = strconv.Atoi(values[0])
+				listParams.Size = &size
+			case keyCursorQueryParam:
+				listParams.Cursor, err = services.DecodeCursor(values[0])
+			case keyPitQueryParam:
+				listParams.Pit, err = strconv.ParseBool(values[0])
+			}
+
+			if err != nil {
+				return fmt.Errorf("invalid query params: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func getListDeletedQueryParams(queryParams url.Values, listParams *services.ListDeletedParameters) error {
+	for key, values := range queryParams {
+		if len(values) > 0 {
+			var err error
+			switch key {
+			case "from":
+				from := 0
+				from, err = strconv.Atoi(values[0])
+				listParams.From = &from
+			case "size":
+				size := 10
+				size, err = strconv.Atoi(values[0])
+				listParams.Size = &size
+			case keyCursorQueryParam:
+				listParams.Cursor, err = services.DecodeCursor(values[0])
+			case keyPitQueryParam:
+				listParams.Pit, err = strconv.ParseBool(values[0])
+			}
+
+			if err != nil {
+				return fmt.Errorf("invalid query params: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func generateListResponseFromDBResponse(resp []*services.ServiceCatalogue, page *services.Page) *services.ListServiceCatalogueResponse {
 	serviceList := []*services.ServiceCatalogueResponse{}
 	for _, serviceCatalogue := range resp {
 		serviceList = append(serviceList, &services.ServiceCatalogueResponse{
 			ServiceId:   serviceCatalogue.ServiceId,
 			Name:        serviceCatalogue.Name,
 			Description: serviceCatalogue.Description,
+			Ownership:   serviceCatalogue.Ownership,
+			Labels:      serviceCatalogue.Labels,
+			Attributes:  serviceCatalogue.Attributes,
+			DependsOn:   serviceCatalogue.DependsOn,
+			Links:       serviceCatalogue.Links,
+			Lifecycle:   serviceCatalogue.Lifecycle,
+			Deprecation: serviceCatalogue.Deprecation,
 			Version:     serviceCatalogue.Version,
 			CreatedAt:   serviceCatalogue.CreatedAt,
 			UpdatedAt:   serviceCatalogue.UpdatedAt,
 			CreatedBy:   serviceCatalogue.CreatedBy,
 			UpdatedBy:   serviceCatalogue.UpdatedBy,
+			Highlights:  serviceCatalogue.Highlights,
 		})
 	}
 	searchRespones := &services.ListServiceCatalogueResponse{
 		ServiceList: serviceList,
+		Page:        page,
 		TimeStamp:   time.Now().UTC().Format(time.RFC3339),
 	}
 	return searchRespones
 }
 
-func generateVersionListFromDBResponse(resp []*services.ServiceCatalogueVersion) *services.ListServiceCatalogueVersionsResponse {
+func generateVersionListFromDBResponse(resp []*services.ServiceCatalogueVersion, page *services.Page) *services.ListServiceCatalogueVersionsResponse {
 	versionsList := []*services.ServiceCatalogueVersionResponse{}
 	for _, serviceCatVersion := range resp {
 		versionsList = append(versionsList, &services.ServiceCatalogueVersionResponse{
@@ -349,15 +784,25 @@ func generateVersionListFromDBResponse(resp []*services.ServiceCatalogueVersion)
 			VersionId:       serviceCatVersion.VersionId,
 			Name:            serviceCatVersion.Name,
 			Description:     serviceCatVersion.Description,
+			Ownership:       serviceCatVersion.Ownership,
+			Labels:          serviceCatVersion.Labels,
+			Attributes:      serviceCatVersion.Attributes,
+			DependsOn:       serviceCatVersion.DependsOn,
+			Links:           serviceCatVersion.Links,
+			Lifecycle:       serviceCatVersion.Lifecycle,
+			Deprecation:     serviceCatVersion.Deprecation,
+			Transition:      serviceCatVersion.Transition,
 			Version:         serviceCatVersion.Version,
 			CreatedAt:       serviceCatVersion.CreatedAt,
 			DecomissionedAt: serviceCatVersion.DecomissionedAt,
 			CreatedBy:       serviceCatVersion.CreatedBy,
 			DecomissionedBy: serviceCatVersion.DecomissionedBy,
+			Deleted:         serviceCatVersion.Deleted,
 		})
 	}
 	searchResponse := &services.ListServiceCatalogueVersionsResponse{
 		ServiceVersionsList: versionsList,
+		Page:                page,
 		TimeStamp:           time.Now().UTC().Format(time.RFC3339),
 	}
 	return searchResponse
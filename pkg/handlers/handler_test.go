@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	stdctx "context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"nikki-noceps/serviceCatalogue/config"
+	"nikki-noceps/serviceCatalogue/internal/services"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// searchDeprecation is the deprecation of the search endpoint the test router is configured with
+var searchDeprecation = config.Deprecation{
+	DeprecatedAt: time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC),
+	SunsetAt:     time.Date(2027, time.May, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func newTestRouter(t *testing.T) (*gin.Engine, *Handler) {
+	gin.SetMode(gin.TestMode)
+	svc, err := services.NewService(stdctx.Background(), &config.Configuration{Database: config.Database{Driver: config.DriverMemory}})
+	require.NoError(t, err)
+	handler := NewHandler(svc, config.Deprecations{Search: searchDeprecation})
+
+	router := gin.New()
+	router.GET("/serviceCatalogue", handler.ListSvcCatalogue)
+	router.GET("/serviceCatalogue/search", handler.SearchSvcCatalogue)
+	router.GET("/serviceCatalogue/:serviceId", handler.FetchServiceById)
+	router.PATCH("/serviceCatalogue/:serviceId", handler.UpdateSvcCatalogue)
+	router.PUT("/attributesSchema", handler.SaveAttributesSchema)
+	return router, handler
+}
+
+// withRoles authenticates the requests as a principal with the roles
+func withRoles(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cctx := context.CustomContextFromContext(c.Request.Context())
+		c.Request = c.Request.WithContext(context.WithRoles(cctx, roles))
+	}
+}
+
+func serve(t *testing.T, router *gin.Engine, method string, path string, body any) (*httptest.ResponseRecorder, map[string]any) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reqBody).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &reqBody)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(userRequestHeader, "bob")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	resp := map[string]any{}
+	if rec.Body.Len() > 0 {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+	}
+	return rec, resp
+}
+
+func TestUpdateSvcCatalogue_RespondsWithUpdatedAttributes(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	router, handler := newTestRouter(t)
+	_, err := handler.Svc.SaveAttributesSchema(cctx, []byte(`{"type": "object", "properties": {
+		"costCenter": {"type": "string"},
+		"tier": {"type": "integer"}
+	}}`), "admin")
+	require.NoError(t, err)
+	svcCat, err := handler.Svc.CreateServiceCatalogue(cctx, (&services.CreateServiceCatalogueRequest{
+		Name: "payments", Description: "processes card payments", CreatedBy: "alice",
+		Attributes: map[string]any{"costCenter": "cc-1", "tier": 1},
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	rec, resp := serve(t, router, http.MethodPatch, "/serviceCatalogue/"+svcCat.ServiceId, map[string]any{
+		"attributes": map[string]any{"tier": 2},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	updated := resp["serviceCatalogue"].(map[string]any)
+	assert.Equal(t, map[string]any{"tier": float64(2)}, updated["attributes"])
+
+	_, fetched := serve(t, router, http.MethodGet, "/serviceCatalogue/"+svcCat.ServiceId, nil)
+	assert.Equal(t, updated["attributes"], fetched["attributes"])
+}
+
+func TestUpdateSvcCatalogue_RespondsWithLinkHosts(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	router, handler := newTestRouter(t)
+	svcCat, err := handler.Svc.CreateServiceCatalogue(cctx, (&services.CreateServiceCatalogueRequest{
+		Name: "payments", Description: "processes card payments", CreatedBy: "alice",
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	rec, resp := serve(t, router, http.MethodPatch, "/serviceCatalogue/"+svcCat.ServiceId, map[string]any{
+		"links": []map[string]any{{"kind": "repository", "url": "https://github.com/acme/payments"}},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	updated := resp["serviceCatalogue"].(map[string]any)
+	assert.Equal(t, []any{map[string]any{"kind": "repository", "url": "https://github.com/acme/payments", "host": "github.com"}}, updated["links"])
+
+	_, fetched := serve(t, router, http.MethodGet, "/serviceCatalogue/"+svcCat.ServiceId, nil)
+	assert.Equal(t, updated["links"], fetched["links"])
+}
+
+func TestUpdateSvcCatalogue_RespondsWithStoredService(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	router, handler := newTestRouter(t)
+	svcCat, err := handler.Svc.CreateServiceCatalogue(cctx, (&services.CreateServiceCatalogueRequest{
+		Name: "payments", Description: "processes card payments", CreatedBy: "alice",
+		Ownership: services.Ownership{TechnicalOwner: "alice", OnCall: "payments-oncall"},
+		Labels:    map[string]string{"domain": "payments", "tier": "1"},
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	rec, resp := serve(t, router, http.MethodPatch, "/serviceCatalogue/"+svcCat.ServiceId, map[string]any{
+		"name":   "acquiring",
+		"onCall": "acquiring-oncall",
+		"labels": map[string]any{"tier": "2"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	updated := resp["serviceCatalogue"].(map[string]any)
+	assert.Equal(t, "alice", updated["technicalOwner"])
+	assert.Equal(t, map[string]any{"tier": "2"}, updated["labels"])
+	assert.Equal(t, "bob", updated["updatedBy"])
+
+	fetchRec, fetched := serve(t, router, http.MethodGet, "/serviceCatalogue/"+svcCat.ServiceId, nil)
+	assert.Equal(t, fetched, updated)
+	assert.Equal(t, fetchRec.Header().Get(eTagResponseHeader), rec.Header().Get(eTagResponseHeader))
+}
+
+func TestParseIfMatch(t *testing.T) {
+	for header, versions := range map[string][]int{
+		``:                 nil,
+		`*`:                nil,
+		`"3"`:              {3},
+		`3`:                {3},
+		`"3", "4"`:         {3, 4},
+		`"3",, W/"4", "5"`: {3, 5},
+	} {
+		parsed, err := parseIfMatch(header)
+		require.NoError(t, err, header)
+		assert.Equal(t, versions, parsed, header)
+	}
+
+	for _, header := range []string{`"a"`, `"0"`, `"3", *`, `"3", "a"`} {
+		_, err := parseIfMatch(header)
+		require.Error(t, err, header)
+		assert.Equal(t, http.StatusBadRequest, concurrencyErrorStatus(err, http.StatusBadRequest), header)
+	}
+
+	// weak ETags never match
+	for _, header := range []string{`W/"3"`, `W/"3", W/"4"`} {
+		_, err := parseIfMatch(header)
+		assert.ErrorIs(t, err, services.VersionMismatchErr, header)
+	}
+}
+
+func TestUpdateSvcCatalogue_IfMatchList(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	router, handler := newTestRouter(t)
+	svcCat, err := handler.Svc.CreateServiceCatalogue(cctx, (&services.CreateServiceCatalogueRequest{
+		Name: "payments", Description: "processes card payments", CreatedBy: "alice",
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	patch := func(ifMatch string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		require.NoError(t, json.NewEncoder(&body).Encode(map[string]any{"name": "acquiring"}))
+		req := httptest.NewRequest(http.MethodPatch, "/serviceCatalogue/"+svcCat.ServiceId, &body)
+		req.Header.Set(userRequestHeader, "bob")
+		req.Header.Set(ifMatchRequestHeader, ifMatch)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusPreconditionFailed, patch(`"4", "5"`).Code)
+	rec := patch(`"1", "2"`)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get(eTagResponseHeader))
+}
+
+func TestSearchSvcCatalogue_Deprecated(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	router, handler := newTestRouter(t)
+	svcCat, err := handler.Svc.CreateServiceCatalogue(cctx, (&services.CreateServiceCatalogueRequest{
+		Name: "payments", Description: "processes card payments", CreatedBy: "alice",
+	}).RequestStructToServiceStruct(cctx))
+	require.NoError(t, err)
+
+	rec, searched := serve(t, router, http.MethodGet, "/serviceCatalogue/search?search=card+payments", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, fmt.Sprintf("@%d", searchDeprecation.DeprecatedAt.Unix()), rec.Header().Get(deprecationHeader))
+	assert.Equal(t, "Sat, 01 May 2027 00:00:00 GMT", rec.Header().Get(sunsetHeader))
+	assert.Equal(t, `</serviceCatalogue?search=card+payments>; rel="successor-version"`, rec.Header().Get(linkHeader))
+
+	// the successor lists the same services with the same highlights
+	rec, listed := serve(t, router, http.MethodGet, "/serviceCatalogue?search=card+payments", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, listed["serviceList"], 1)
+	assert.Equal(t, searched["serviceList"], listed["serviceList"])
+	assert.Equal(t, svcCat.ServiceId, listed["serviceList"].([]any)[0].(map[string]any)["serviceId"])
+	assert.NotEmpty(t, listed["serviceList"].([]any)[0].(map[string]any)["highlights"])
+
+	// without a configured deprecation the search endpoint is not announced as deprecated
+	handler.Deprecations = config.Deprecations{}
+	rec, _ = serve(t, router, http.MethodGet, "/serviceCatalogue/search?search=card+payments", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Empty(t, rec.Header().Get(deprecationHeader))
+	assert.Empty(t, rec.Header().Get(linkHeader))
+}
+
+func TestSaveAttributesSchema_AdminsOnly(t *testing.T) {
+	cctx := context.NewCustomContext(&context.CustomContextConfig{})
+	router, handler := newTestRouter(t)
+	schema := map[string]any{"type": "object", "properties": map[string]any{"tier": map[string]any{"type": "integer"}}}
+
+	rec, _ := serve(t, router, http.MethodPut, "/attributesSchema", schema)
+	assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	_, err := handler.Svc.FetchAttributesSchema(cctx)
+	assert.ErrorIs(t, err, services.NoDocumentFoundErr, "the schema of a principal without the admin role is not saved")
+
+	adminRouter := gin.New()
+	adminRouter.Use(withRoles(config.RoleAdmin))
+	adminRouter.PUT("/attributesSchema", handler.SaveAttributesSchema)
+	rec, _ = serve(t, adminRouter, http.MethodPut, "/attributesSchema", schema)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+}
@@ -1,20 +1,38 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"nikki-noceps/serviceCatalogue/internal/services"
 	"nikki-noceps/serviceCatalogue/pkg/context"
 	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ListServiceCatalogueVersions lists the archived versions of a service oldest first, paged like ListSvcCatalogue
 func (h *Handler) ListServiceCatalogueVersions(c *gin.Context) {
 	cctx := context.CustomContextFromContext(c.Request.Context())
 
 	parentId := c.Param(keyServiceIdPathParam)
 
-	resp, err := h.Svc.ListAllServiceVersions(cctx, parentId)
+	listParams := &services.ListVersionsParameters{}
+	if err := getListVersionsQueryParams(c.Request.URL.Query(), listParams); err != nil {
+		cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	listParams.AddDefaultsIfEmpty()
+	if err := listParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, page, err := h.Svc.ListServiceVersions(cctx, parentId, listParams)
 	if err != nil {
 		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
 		c.Status(http.StatusInternalServerError)
@@ -22,7 +40,7 @@ func (h *Handler) ListServiceCatalogueVersions(c *gin.Context) {
 		return
 	}
 
-	searchResponse := generateVersionListFromDBResponse(resp)
+	searchResponse := generateVersionListFromDBResponse(resp, page)
 
 	c.JSON(http.StatusOK, searchResponse)
 }
@@ -45,12 +63,133 @@ func (h *Handler) FetchServiceCatalogueVersionById(c *gin.Context) {
 		VersionId:       resp.VersionId,
 		Name:            resp.Name,
 		Description:     resp.Description,
+		Ownership:       resp.Ownership,
+		Labels:          resp.Labels,
+		Attributes:      resp.Attributes,
+		DependsOn:       resp.DependsOn,
+		Links:           resp.Links,
+		Lifecycle:       resp.Lifecycle,
+		Deprecation:     resp.Deprecation,
+		Transition:      resp.Transition,
 		Version:         resp.Version,
 		CreatedAt:       resp.CreatedAt,
 		DecomissionedAt: resp.DecomissionedAt,
 		CreatedBy:       resp.CreatedBy,
 		DecomissionedBy: resp.DecomissionedBy,
+		Deleted:         resp.Deleted,
 	}
 
 	c.JSON(http.StatusOK, searchResponse)
 }
+
+// RestoreServiceCatalogueVersion makes an archived version of the service live again as a new version.
+// The current live version is archived like on any other update. Honours If-Match the same way as UpdateSvcCatalogue.
+// The optional body re-assigns the service to another owner team.
+func (h *Handler) RestoreServiceCatalogueVersion(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	restoreReq := &services.RestoreServiceCatalogueVersionRequest{}
+	if err := bindOptionalJSON(c, restoreReq); err != nil {
+		cctx.Logger().ERROR("REQUEST_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	restoreReq.ServiceId = c.Param(keyServiceIdPathParam)
+	restoreReq.VersionId = c.Param(keyVersionIdPathParam)
+	restoreReq.RestoredBy = c.GetHeader(userRequestHeader)
+	if err := restoreReq.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	expectedVersions, err := parseIfMatch(c.GetHeader(ifMatchRequestHeader))
+	if err != nil {
+		cctx.Logger().ERROR("HEADER_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(concurrencyErrorStatus(err, http.StatusBadRequest))
+		_ = c.Error(err)
+		return
+	}
+
+	resp, err := h.Svc.RestoreServiceCatalogueVersion(cctx, restoreReq, expectedVersions)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		if errors.Is(err, services.NoDocumentFoundErr) || errors.Is(err, services.InvalidDependencyErr) ||
+			errors.Is(err, services.InvalidAttributesErr) || errors.Is(err, services.UnknownTeamErr) {
+			c.Status(http.StatusBadRequest)
+			_ = c.Error(err)
+			return
+		}
+		if errors.Is(err, services.OwnerTeamGoneErr) {
+			c.Status(http.StatusConflict)
+			_ = c.Error(err)
+			return
+		}
+		c.Status(concurrencyErrorStatus(err, http.StatusInternalServerError))
+		_ = c.Error(err)
+		return
+	}
+
+	serviceCatalogueResp := &services.UpdateServiceCatalogueResponse{
+		ServiceCatalogueResponse: &services.ServiceCatalogueResponse{
+			ServiceId:   resp.ServiceId,
+			Name:        resp.Name,
+			Description: resp.Description,
+			Ownership:   resp.Ownership,
+			Labels:      resp.Labels,
+			Attributes:  resp.Attributes,
+			DependsOn:   resp.DependsOn,
+			Links:       resp.Links,
+			Lifecycle:   resp.Lifecycle,
+			Deprecation: resp.Deprecation,
+			Version:     resp.Version,
+			CreatedAt:   resp.CreatedAt,
+			UpdatedAt:   resp.UpdatedAt,
+			CreatedBy:   resp.CreatedBy,
+			UpdatedBy:   resp.UpdatedBy,
+			Warnings:    resp.Warnings,
+		},
+		TimeStamp: time.Now().Format(time.RFC3339),
+	}
+	c.Header(eTagResponseHeader, formatETag(resp.Version))
+	c.JSON(http.StatusOK, serviceCatalogueResp)
+}
+
+// DiffServiceCatalogueVersions returns the per field diff between two versions of a service, the live document
+// is referred to as `current`. Defaults to diffing against the live document when `to` is not specified.
+func (h *Handler) DiffServiceCatalogueVersions(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	serviceId := c.Param(keyServiceIdPathParam)
+	diffParams := &services.DiffParameters{
+		From: c.Query("from"),
+		To:   c.Query("to"),
+	}
+	diffParams.AddDefaultsIfEmpty()
+	if err := diffParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, err := h.Svc.DiffServiceVersions(cctx, serviceId, diffParams)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		if errors.Is(err, services.NoDocumentFoundErr) {
+			c.Status(http.StatusBadRequest)
+			_ = c.Error(err)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &services.ServiceCatalogueDiffResponse{
+		ServiceCatalogueDiff: resp,
+		TimeStamp:            time.Now().UTC().Format(time.RFC3339),
+	})
+}
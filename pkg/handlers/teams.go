@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"nikki-noceps/serviceCatalogue/internal/services"
+	"nikki-noceps/serviceCatalogue/pkg/context"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var keyTeamIdPathParam = "teamId"
+
+// ListTeams lists the teams sorted by name, paged like ListSvcCatalogue
+func (h *Handler) ListTeams(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	listParams := &services.ListTeamsParameters{}
+	if err := getListTeamsQueryParams(c.Request.URL.Query(), listParams); err != nil {
+		cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	listParams.AddDefaultsIfEmpty()
+	if err := listParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, page, err := h.Svc.ListTeams(cctx, listParams)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(http.StatusInternalServerError)
+		_ = c.Error(err)
+		return
+	}
+
+	teams := []*services.TeamResponse{}
+	for _, team := range resp {
+		teams = append(teams, generateTeamResponse(team))
+	}
+	c.JSON(http.StatusOK, &services.ListTeamsResponse{
+		Teams:     teams,
+		Page:      page,
+		TimeStamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// CreateTeam creates a team document in the teams index
+func (h *Handler) CreateTeam(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	teamReq := &services.CreateTeamRequest{}
+	if err := c.ShouldBindJSON(teamReq); err != nil {
+		cctx.Logger().ERROR("REQUEST_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	teamReq.CreatedBy = c.GetHeader(userRequestHeader)
+
+	if err := teamReq.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, err := h.Svc.CreateTeam(cctx, teamReq.RequestStructToTeamStruct(cctx))
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(teamErrorStatus(err, http.StatusInternalServerError))
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, &services.CreateTeamResponse{
+		TeamResponse: generateTeamResponse(resp),
+		TimeStamp:    time.Now().Format(time.RFC3339),
+	})
+}
+
+func (h *Handler) FetchTeamById(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	teamId := c.Param(keyTeamIdPathParam)
+
+	resp, err := h.Svc.FetchTeamById(cctx, teamId)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(teamErrorStatus(err, http.StatusInternalServerError))
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, generateTeamResponse(resp))
+}
+
+// UpdateTeam changes the fields sent in the request, members are replaced when sent
+func (h *Handler) UpdateTeam(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	teamReq := &services.UpdateTeamRequest{
+		TeamId: c.Param(keyTeamIdPathParam),
+	}
+	if err := c.ShouldBindJSON(teamReq); err != nil {
+		cctx.Logger().ERROR("REQUEST_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	teamReq.UpdatedBy = c.GetHeader(userRequestHeader)
+
+	if err := teamReq.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, err := h.Svc.UpdateTeam(cctx, teamReq.RequestStructToTeamStruct(cctx))
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(teamErrorStatus(err, http.StatusInternalServerError))
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, &services.UpdateTeamResponse{
+		TeamResponse: generateTeamResponse(resp),
+		TimeStamp:    time.Now().Format(time.RFC3339),
+	})
+}
+
+// DeleteTeam deletes a team, a team which still owns services or has child teams can not be deleted
+func (h *Handler) DeleteTeam(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	teamId := c.Param(keyTeamIdPathParam)
+
+	if err := h.Svc.DeleteTeam(cctx, teamId); err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(teamErrorStatus(err, http.StatusInternalServerError))
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusAccepted, nil)
+}
+
+// ListTeamServices lists the services owned by a team sorted by name
+func (h *Handler) ListTeamServices(c *gin.Context) {
+	cctx := context.CustomContextFromContext(c.Request.Context())
+
+	teamId := c.Param(keyTeamIdPathParam)
+
+	listParams := &services.ListTeamsParameters{}
+	if err := getListTeamsQueryParams(c.Request.URL.Query(), listParams); err != nil {
+		cctx.Logger().ERROR("QUERY_PARSING_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+	listParams.AddDefaultsIfEmpty()
+	if err := listParams.Validate(); err != nil {
+		cctx.Logger().ERROR("VALIDATION_FAILED", tag.NewErrorTag(err))
+		c.Status(http.StatusBadRequest)
+		_ = c.Error(err)
+		return
+	}
+
+	resp, page, err := h.Svc.ListTeamServices(cctx, teamId, listParams)
+	if err != nil {
+		cctx.Logger().ERROR("SERVICE_ERROR", tag.NewErrorTag(err))
+		c.Status(teamErrorStatus(err, http.StatusInternalServerError))
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, generateListResponseFromDBResponse(resp, page))
+}
+
+func teamErrorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.NoDocumentFoundErr),
+		errors.Is(err, services.UnknownTeamErr),
+		errors.Is(err, services.TeamHierarchyCycleErr):
+		return http.StatusBadRequest
+	case errors.Is(err, services.TeamInUseErr):
+		return http.StatusConflict
+	}
+	return fallback
+}
+
+func getListTeamsQueryParams(queryParams url.Values, listParams *services.ListTeamsParameters) error {
+	for key, values := range queryParams {
+		if len(values) > 0 {
+			var err error
+			switch key {
+			case "from":
+				from := 0
+				from, err = strconv.Atoi(values[0])
+				listParams.From = &from
+			case "size":
+				size := 10
+				size, err = strconv.Atoi(values[0])
+				listParams.Size = &size
+			case keyCursorQueryParam:
+				listParams.Cursor, err = services.DecodeCursor(values[0])
+			case keyPitQueryParam:
+				listParams.Pit, err = strconv.ParseBool(values[0])
+			}
+
+			if err != nil {
+				return fmt.Errorf("invalid query params: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func generateTeamResponse(team *services.Team) *services.TeamResponse {
+	members := team.Members
+	if members == nil {
+		members = []string{}
+	}
+	return &services.TeamResponse{
+		TeamId:       team.TeamId,
+		Name:         team.Name,
+		Description:  team.Description,
+		Members:      members,
+		SlackChannel: team.SlackChannel,
+		Email:        team.Email,
+		ParentTeamId: team.ParentTeamId,
+		CreatedAt:    team.CreatedAt,
+		UpdatedAt:    team.UpdatedAt,
+		CreatedBy:    team.CreatedBy,
+		UpdatedBy:    team.UpdatedBy,
+	}
+}
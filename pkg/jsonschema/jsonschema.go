@@ -0,0 +1,315 @@
+// Package jsonschema validates documents against the subset of JSON Schema (draft 2020-12) the catalogue
+// supports for custom attributes. Schemas using keywords outside the subset are rejected when parsed
+// instead of being silently ignored.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	types       = []string{TypeObject, TypeArray, TypeString, TypeInteger, TypeNumber, TypeBoolean}
+
+	FormatDateTime = "date-time"
+	FormatDate     = "date"
+	FormatEmail    = "email"
+	FormatURI      = "uri"
+	formats        = []string{FormatDateTime, FormatDate, FormatEmail, FormatURI}
+)
+
+// InvalidSchemaErr is returned when a schema can not be parsed or uses keywords outside the supported subset
+var InvalidSchemaErr error = fmt.Errorf("INVALID_SCHEMA")
+
+// Schema is a parsed schema. Supported are the keywords below, every schema needs a type.
+type Schema struct {
+	SchemaURI   string `json:"$schema,omitempty"`
+	Id          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+	Enum        []any  `json:"enum,omitempty"`
+	// object
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	// array
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int    `json:"minItems,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+	// string
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	Format    string `json:"format,omitempty"`
+	// integer and number
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// ValidationError is a single violation of the schema, Path is the JSON pointer of the offending value
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors are all the violations found in a document
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Parse parses and checks the schema
+func Parse(data []byte) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+	schema := &Schema{}
+	if err := decoder.Decode(schema); err != nil {
+		return nil, fmt.Errorf("%w: %w", InvalidSchemaErr, err)
+	}
+	if err := schema.compile(""); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// compile checks the keywords fit the type and compiles the pattern
+func (s *Schema) compile(path string) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s: %s", InvalidSchemaErr, pointer(path), fmt.Sprintf(format, args...))
+	}
+	if !slices.Contains(types, s.Type) {
+		return invalid("type must be one of %s", strings.Join(types, ", "))
+	}
+	if s.Type != TypeObject && (s.Properties != nil || s.Required != nil || s.AdditionalProperties != nil) {
+		return invalid("properties, required and additionalProperties only apply to objects")
+	}
+	if s.Type != TypeArray && (s.Items != nil || s.MinItems != nil || s.MaxItems != nil) {
+		return invalid("items, minItems and maxItems only apply to arrays")
+	}
+	if s.Type != TypeString && (s.MinLength != nil || s.MaxLength != nil || s.Pattern != "" || s.Format != "") {
+		return invalid("minLength, maxLength, pattern and format only apply to strings")
+	}
+	if s.Type != TypeInteger && s.Type != TypeNumber && (s.Minimum != nil || s.Maximum != nil) {
+		return invalid("minimum and maximum only apply to integers and numbers")
+	}
+	if s.Type == TypeArray && s.Items == nil {
+		return invalid("arrays need items")
+	}
+	if s.Format != "" && !slices.Contains(formats, s.Format) {
+		return invalid("format must be one of %s", strings.Join(formats, ", "))
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return invalid("invalid pattern: %s", err)
+		}
+		s.pattern = pattern
+	}
+	for i, value := range s.Enum {
+		s.Enum[i] = normalize(value)
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			return invalid("required property %q is not declared", name)
+		}
+	}
+	for name, property := range s.Properties {
+		if property == nil {
+			return invalid("property %q has no schema", name)
+		}
+		if err := property.compile(path + "/" + escape(name)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "/items")
+	}
+	return nil
+}
+
+// Validate validates the value decoded from JSON against the schema, returning ValidationErrors
+// with every violation found
+func (s *Schema) Validate(value any) error {
+	errs := s.validate("", normalize(value), ValidationErrors{})
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, value any, errs ValidationErrors) ValidationErrors {
+	violation := func(format string, args ...any) ValidationErrors {
+		return append(errs, &ValidationError{Path: pointer(path), Message: fmt.Sprintf(format, args...)})
+	}
+	if !s.hasType(value) {
+		return violation("must be of type %s", s.Type)
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(allowed any) bool { return equal(allowed, value) }) {
+		return violation("must be one of %v", s.Enum)
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				errs = append(errs, &ValidationError{Path: pointer(path + "/" + escape(name)), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					errs = append(errs, &ValidationError{Path: pointer(path + "/" + escape(name)), Message: "is not allowed"})
+				}
+				continue
+			}
+			errs = property.validate(path+"/"+escape(name), value[name], errs)
+		}
+	case []any:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			errs = violation("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			errs = violation("must have at most %d items", *s.MaxItems)
+		}
+		for i, item := range value {
+			errs = s.Items.validate(fmt.Sprintf("%s/%d", path, i), item, errs)
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.MinLength != nil && length < *s.MinLength {
+			errs = violation("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			errs = violation("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			errs = violation("must match the pattern %s", s.Pattern)
+		}
+		if s.Format != "" && !hasFormat(s.Format, value) {
+			errs = violation("must be a valid %s", s.Format)
+		}
+	case float64:
+		if s.Minimum != nil && value < *s.Minimum {
+			errs = violation("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			errs = violation("must be at most %v", *s.Maximum)
+		}
+	}
+	return errs
+}
+
+func (s *Schema) hasType(value any) bool {
+	switch value := value.(type) {
+	case map[string]any:
+		return s.Type == TypeObject
+	case []any:
+		return s.Type == TypeArray
+	case string:
+		return s.Type == TypeString
+	case bool:
+		return s.Type == TypeBoolean
+	case float64:
+		return s.Type == TypeNumber || (s.Type == TypeInteger && value == math.Trunc(value))
+	}
+	return false
+}
+
+func hasFormat(format string, value string) bool {
+	switch format {
+	case FormatDateTime:
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case FormatDate:
+		_, err := time.Parse(time.DateOnly, value)
+		return err == nil
+	case FormatEmail:
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	case FormatURI:
+		uri, err := url.Parse(value)
+		return err == nil && uri.IsAbs()
+	}
+	return false
+}
+
+// normalize converts the numbers of a decoded value to float64, whichever way it was decoded
+func normalize(value any) any {
+	switch value := value.(type) {
+	case json.Number:
+		number, _ := value.Float64()
+		return number
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case float32:
+		return float64(value)
+	case map[string]any:
+		normalized := make(map[string]any, len(value))
+		for key, item := range value {
+			normalized[key] = normalize(item)
+		}
+		return normalized
+	case []any:
+		normalized := make([]any, 0, len(value))
+		for _, item := range value {
+			normalized = append(normalized, normalize(item))
+		}
+		return normalized
+	}
+	return value
+}
+
+func equal(a, b any) bool {
+	aBytes, aErr := json.Marshal(a)
+	bBytes, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aBytes, bBytes)
+}
+
+// escape escapes a property name as a JSON pointer reference token
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+func pointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
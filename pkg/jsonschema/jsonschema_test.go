@@ -0,0 +1,80 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var attributesSchema = []byte(`{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"additionalProperties": false,
+	"required": ["costCenter"],
+	"properties": {
+		"costCenter": {"type": "string", "pattern": "^cc-[0-9]+$"},
+		"repoUrl": {"type": "string", "format": "uri"},
+		"dataClassification": {"type": "string", "enum": ["public", "internal", "confidential"]},
+		"tier": {"type": "integer", "minimum": 1, "maximum": 3},
+		"pii": {"type": "boolean"},
+		"regions": {"type": "array", "items": {"type": "string", "minLength": 2}, "maxItems": 2}
+	}
+}`)
+
+func TestParse(t *testing.T) {
+	schema, err := Parse(attributesSchema)
+	require.NoError(t, err)
+	assert.Equal(t, TypeObject, schema.Type)
+	assert.Len(t, schema.Properties, 6)
+
+	for name, raw := range map[string]string{
+		"unknown keyword":         `{"type": "object", "oneOf": []}`,
+		"missing type":            `{"properties": {}}`,
+		"keyword of another type": `{"type": "string", "minimum": 1}`,
+		"array without items":     `{"type": "array"}`,
+		"unknown format":          `{"type": "string", "format": "ipv4"}`,
+		"invalid pattern":         `{"type": "string", "pattern": "("}`,
+		"undeclared required":     `{"type": "object", "required": ["a"]}`,
+		"nested unknown keyword":  `{"type": "object", "properties": {"a": {"type": "string", "const": "a"}}}`,
+	} {
+		_, err := Parse([]byte(raw))
+		assert.ErrorIs(t, err, InvalidSchemaErr, name)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Parse(attributesSchema)
+	require.NoError(t, err)
+
+	validate := func(document string) error {
+		var value any
+		require.NoError(t, json.Unmarshal([]byte(document), &value))
+		return schema.Validate(value)
+	}
+
+	assert.NoError(t, validate(`{"costCenter": "cc-42", "repoUrl": "https://github.com/acme/ledger", "dataClassification": "internal", "tier": 2, "pii": true, "regions": ["eu", "us"]}`))
+	assert.NoError(t, schema.Validate(map[string]any{"costCenter": "cc-42", "tier": 1}))
+
+	err = validate(`{"repoUrl": "ledger", "dataClassification": "secret", "tier": 1.5, "pii": "yes", "regions": ["e", "us", "ap"], "owner": "x"}`)
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	paths := map[string]string{}
+	for _, err := range errs {
+		paths[err.Path] = err.Message
+	}
+	assert.Equal(t, map[string]string{
+		"/costCenter":         "is required",
+		"/repoUrl":            "must be a valid uri",
+		"/dataClassification": "must be one of [public internal confidential]",
+		"/tier":               "must be of type integer",
+		"/pii":                "must be of type boolean",
+		"/regions":            "must have at most 2 items",
+		"/regions/0":          "must be at least 2 characters long",
+		"/owner":              "is not allowed",
+	}, paths)
+
+	assert.ErrorContains(t, validate(`{"costCenter": "42", "tier": 4}`), "/costCenter: must match the pattern ^cc-[0-9]+$; /tier: must be at most 3")
+	assert.ErrorContains(t, validate(`[]`), "/: must be of type object")
+}
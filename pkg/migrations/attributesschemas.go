@@ -0,0 +1,37 @@
+package migrations
+
+// the uploaded schema is kept as is and never searched, so it is not indexed
+var attributesSchemasMapping = []byte(`{
+		"mappings": {
+            "properties": {
+                "schema": {
+                    "type": "object",
+                    "enabled": false
+                },
+                "version": {
+                    "type": "long"
+                },
+                "updatedAt": {
+                    "type": "date"
+                },
+                "updatedBy": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "orgId": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                }
+            }
+        }
+	}`)
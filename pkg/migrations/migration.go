@@ -3,6 +3,7 @@ package migrations
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"nikki-noceps/serviceCatalogue/config"
 	"nikki-noceps/serviceCatalogue/pkg/database"
@@ -14,10 +15,15 @@ import (
 )
 
 func RunMigrations(ctx context.Context, cfg *config.Configuration) error {
+	if cfg.Database.Driver != config.DriverElasticSearch {
+		logger.INFO("skipping index migrations", tag.NewAnyTag("driver", cfg.Database.Driver))
+		return nil
+	}
+
 	esConfig := es.Config{
-		Addresses: []string{fmt.Sprintf("%s:%s", cfg.ElasticSearch.Host, cfg.ElasticSearch.Port)},
-		Username:  cfg.ElasticSearch.Username,
-		Password:  cfg.ElasticSearch.Password,
+		Addresses: []string{fmt.Sprintf("%s:%s", cfg.Database.Host, cfg.Database.Port)},
+		Username:  cfg.Database.Username,
+		Password:  cfg.Database.Password,
 	}
 
 	esClient, err := es.NewClient(esConfig)
@@ -31,12 +37,48 @@ func RunMigrations(ctx context.Context, cfg *config.Configuration) error {
 		logger.ERROR("failed to create index", tag.NewAnyTag("index", database.ServiceCatalogueIndex), tag.NewErrorTag(err))
 	}
 
+	// Map the labels and links of a servicecatalogue index created before them and backfill the label pairs
+	err = migrateIndex(ctx, esClient, database.ServiceCatalogueIndex, serviceCatalogueMapping, serviceCatalogueMigratedFields, labelPairsScript)
+	if err != nil {
+		logger.ERROR("failed to migrate index", tag.NewAnyTag("index", database.ServiceCatalogueIndex), tag.NewErrorTag(err))
+	}
+
+	// Add name suggestions to a servicecatalogue index created before them
+	err = upgradeIndex(ctx, esClient, database.ServiceCatalogueIndex, database.NameSuggestField, nameSuggestMapping)
+	if err != nil {
+		logger.ERROR("failed to upgrade index", tag.NewAnyTag("index", database.ServiceCatalogueIndex), tag.NewErrorTag(err))
+	}
+
 	// Create servicecatalogueversions index
 	err = createIndex(ctx, esClient, database.ServiceCatalogueVersionIndex, serviceCatalogueVersionsMapping)
 	if err != nil {
 		logger.ERROR("failed to create index", tag.NewAnyTag("index", database.ServiceCatalogueVersionIndex), tag.NewErrorTag(err))
 	}
 
+	// Map the labels and links of a servicecatalogueversions index created before them
+	err = migrateIndex(ctx, esClient, database.ServiceCatalogueVersionIndex, serviceCatalogueVersionsMapping, serviceCatalogueVersionsMigratedFields, "")
+	if err != nil {
+		logger.ERROR("failed to migrate index", tag.NewAnyTag("index", database.ServiceCatalogueVersionIndex), tag.NewErrorTag(err))
+	}
+
+	// Create servicecataloguechanges index
+	err = createIndex(ctx, esClient, database.ServiceCatalogueChangeIndex, serviceCatalogueChangesMapping)
+	if err != nil {
+		logger.ERROR("failed to create index", tag.NewAnyTag("index", database.ServiceCatalogueChangeIndex), tag.NewErrorTag(err))
+	}
+
+	// Create teams index
+	err = createIndex(ctx, esClient, database.TeamIndex, teamsMapping)
+	if err != nil {
+		logger.ERROR("failed to create index", tag.NewAnyTag("index", database.TeamIndex), tag.NewErrorTag(err))
+	}
+
+	// Create attributesschemas index
+	err = createIndex(ctx, esClient, database.AttributesSchemaIndex, attributesSchemasMapping)
+	if err != nil {
+		logger.ERROR("failed to create index", tag.NewAnyTag("index", database.AttributesSchemaIndex), tag.NewErrorTag(err))
+	}
+
 	return nil
 }
 
@@ -58,3 +100,87 @@ func createIndex(ctx context.Context, es *es.Client, index string, mapping []byt
 	logger.INFO("Index Created !!", tag.NewAnyTag("index", index))
 	return nil
 }
+
+// upgradeIndex maps a field on an index created before the field was mapped and reindexes the documents of the
+// index in place, in the background, so the field is indexed for them too. Nothing is done when the field is
+// mapped already.
+func upgradeIndex(ctx context.Context, es *es.Client, index string, field string, mapping []byte) error {
+	req := esapi.IndicesGetFieldMappingRequest{
+		Index:  []string{index},
+		Fields: []string{field},
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return fmt.Errorf("error getting field mapping: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error getting field mapping: %s", res.Status())
+	}
+	var fieldMappings map[string]struct {
+		Mappings map[string]any `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&fieldMappings); err != nil {
+		return fmt.Errorf("error parsing field mapping: %s", err)
+	}
+	// the mappings are keyed by the index the name resolves to, which is a version of it once it was recreated
+	for _, fieldMapping := range fieldMappings {
+		if len(fieldMapping.Mappings) > 0 {
+			return nil
+		}
+	}
+
+	if err := putMapping(ctx, es, index, mapping); err != nil {
+		return err
+	}
+	if err := updateByQuery(ctx, es, index, ""); err != nil {
+		return err
+	}
+
+	logger.INFO("Index Upgraded !!", tag.NewAnyTag("index", index), tag.NewAnyTag("field", field))
+	return nil
+}
+
+// putMapping adds the fields of the mapping to the mapping of the index
+func putMapping(ctx context.Context, es *es.Client, index string, mapping []byte) error {
+	req := esapi.IndicesPutMappingRequest{
+		Index: []string{index},
+		Body:  bytes.NewReader(mapping),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return fmt.Errorf("error updating mapping: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error updating mapping: %s", res.Status())
+	}
+	return nil
+}
+
+// updateByQuery reindexes the documents of the index in place, in the background, running the painless script on
+// each of them when one is given.
+func updateByQuery(ctx context.Context, es *es.Client, index string, script string) error {
+	waitForCompletion := false
+	req := esapi.UpdateByQueryRequest{
+		Index:             []string{index},
+		Conflicts:         "proceed",
+		WaitForCompletion: &waitForCompletion,
+	}
+	if script != "" {
+		body, err := json.Marshal(map[string]any{"script": map[string]any{"source": script, "lang": "painless"}})
+		if err != nil {
+			return fmt.Errorf("error building reindex request: %s", err)
+		}
+		req.Body = bytes.NewReader(body)
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return fmt.Errorf("error reindexing: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error reindexing: %s", res.Status())
+	}
+	return nil
+}
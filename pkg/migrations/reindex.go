@@ -0,0 +1,351 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"nikki-noceps/serviceCatalogue/pkg/logger"
+	"nikki-noceps/serviceCatalogue/pkg/logger/tag"
+	"strconv"
+	"strings"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// indexVersionSeparator names the versions of an index recreated by a migration, like servicecatalogue_v2. The name
+// of the index is then an alias of its latest version.
+const indexVersionSeparator = "_v"
+
+var (
+	// serviceCatalogueMigratedFields are the fields of the servicecatalogue index mapped after it was first deployed
+	serviceCatalogueMigratedFields = []string{"labels", "labelPairs", "links"}
+	// serviceCatalogueVersionsMigratedFields are the fields of the servicecatalogueversions index mapped after it
+	// was first deployed
+	serviceCatalogueVersionsMigratedFields = []string{"labels", "links"}
+)
+
+// labelPairsScript backfills the labels of a service as sorted `key=value` pairs, the way the catalogue stores them
+// under labelPairs for the label facets, for the services written before labelPairs was introduced
+const labelPairsScript = `if (ctx._source.labels instanceof Map) {
+	List pairs = new ArrayList();
+	for (def label : ctx._source.labels.entrySet()) {
+		pairs.add(label.getKey() + '=' + label.getValue());
+	}
+	Collections.sort(pairs);
+	ctx._source.labelPairs = pairs;
+}`
+
+// indexMapping is the mapping an index is created with, as well as the mapping of an index returned by elasticsearch
+type indexMapping struct {
+	Mappings struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	} `json:"mappings"`
+}
+
+// fieldType is the type a field is mapped as, fields mapped with properties but no type are objects
+func fieldType(property json.RawMessage) string {
+	var field struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(property, &field); err != nil || field.Type == "" {
+		return "object"
+	}
+	return field.Type
+}
+
+// mergeMappings adds the fields the index has mapped besides the ones of the mapping it should have, like the
+// attributes mapped from the attributes schema, to the mapping. The fields of objects are merged the same way, the
+// mapping wins for a field mapped on both with different types.
+func mergeMappings(want, have map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	merged := make(map[string]json.RawMessage, len(have))
+	for field, property := range have {
+		merged[field] = property
+	}
+	for field, property := range want {
+		current, ok := have[field]
+		if !ok || fieldType(current) != "object" || fieldType(property) != "object" {
+			merged[field] = property
+			continue
+		}
+
+		var wantObject, haveObject map[string]json.RawMessage
+		if err := json.Unmarshal(property, &wantObject); err != nil {
+			return nil, fmt.Errorf("error parsing mapping of %s: %s", field, err)
+		}
+		if err := json.Unmarshal(current, &haveObject); err != nil {
+			return nil, fmt.Errorf("error parsing mapping of %s: %s", field, err)
+		}
+		var wantProperties, haveProperties map[string]json.RawMessage
+		json.Unmarshal(wantObject["properties"], &wantProperties)
+		json.Unmarshal(haveObject["properties"], &haveProperties)
+		properties, err := mergeMappings(wantProperties, haveProperties)
+		if err != nil {
+			return nil, err
+		}
+		if len(properties) > 0 {
+			if wantObject["properties"], err = json.Marshal(properties); err != nil {
+				return nil, fmt.Errorf("error building mapping of %s: %s", field, err)
+			}
+		}
+		if merged[field], err = json.Marshal(wantObject); err != nil {
+			return nil, fmt.Errorf("error building mapping of %s: %s", field, err)
+		}
+	}
+	return merged, nil
+}
+
+// pendingFields compares the fields of the mapping an index has to the mapping it should have. It returns the
+// fields which are not mapped yet, these can be added in place, and the fields which are mapped with another type,
+// elasticsearch maps the fields of documents written before the field was mapped dynamically, these can only be
+// changed by recreating the index.
+func pendingFields(want, have indexMapping, fields []string) (missing []string, conflicting []string) {
+	for _, field := range fields {
+		current, ok := have.Mappings.Properties[field]
+		if !ok {
+			missing = append(missing, field)
+			continue
+		}
+		if fieldType(current) != fieldType(want.Mappings.Properties[field]) {
+			conflicting = append(conflicting, field)
+		}
+	}
+	return missing, conflicting
+}
+
+// migrateIndex brings the fields of an index created before they were mapped to the mapping. Fields which are not
+// mapped yet are added in place and the documents are reindexed in the background, when a field was mapped
+// dynamically with another type the index is recreated with the mapping, merged with the fields the index has mapped
+// besides, and its documents copied over, see recreateIndex. The painless script, when given, is run on each of the
+// documents reindexed.
+func migrateIndex(ctx context.Context, es *es.Client, index string, mapping []byte, fields []string, script string) error {
+	var want indexMapping
+	if err := json.Unmarshal(mapping, &want); err != nil {
+		return fmt.Errorf("error parsing mapping: %s", err)
+	}
+
+	current, have, err := getMapping(ctx, es, index)
+	if err != nil {
+		return err
+	}
+
+	missing, conflicting := pendingFields(want, have, fields)
+	if len(conflicting) > 0 {
+		var recreated indexMapping
+		if recreated.Mappings.Properties, err = mergeMappings(want.Mappings.Properties, have.Mappings.Properties); err != nil {
+			return err
+		}
+		recreatedMapping, err := json.Marshal(recreated)
+		if err != nil {
+			return fmt.Errorf("error building mapping: %s", err)
+		}
+		if err := recreateIndex(ctx, es, index, current, recreatedMapping, script); err != nil {
+			return err
+		}
+		logger.INFO("Index Recreated !!", tag.NewAnyTag("index", index), tag.NewAnyTag("fields", conflicting))
+		return nil
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var missingMapping indexMapping
+	missingMapping.Mappings.Properties = make(map[string]json.RawMessage, len(missing))
+	for _, field := range missing {
+		missingMapping.Mappings.Properties[field] = want.Mappings.Properties[field]
+	}
+	body, err := json.Marshal(missingMapping.Mappings)
+	if err != nil {
+		return fmt.Errorf("error building mapping: %s", err)
+	}
+	if err := putMapping(ctx, es, index, body); err != nil {
+		return err
+	}
+	if err := updateByQuery(ctx, es, index, script); err != nil {
+		return err
+	}
+
+	logger.INFO("Index Upgraded !!", tag.NewAnyTag("index", index), tag.NewAnyTag("fields", missing))
+	return nil
+}
+
+// getMapping gets the mapping of the index the name resolves to, directly or through an alias, and the name of
+// that index
+func getMapping(ctx context.Context, es *es.Client, index string) (string, indexMapping, error) {
+	req := esapi.IndicesGetMappingRequest{
+		Index: []string{index},
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return "", indexMapping{}, fmt.Errorf("error getting mapping: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", indexMapping{}, fmt.Errorf("error getting mapping: %s", res.Status())
+	}
+	var mappings map[string]indexMapping
+	if err := json.NewDecoder(res.Body).Decode(&mappings); err != nil {
+		return "", indexMapping{}, fmt.Errorf("error parsing mapping: %s", err)
+	}
+	if len(mappings) != 1 {
+		return "", indexMapping{}, fmt.Errorf("error getting mapping: %s resolves to %d indices", index, len(mappings))
+	}
+	for current, mapping := range mappings {
+		return current, mapping, nil
+	}
+	return "", indexMapping{}, nil
+}
+
+// nextIndexVersion names the version of the index following the current one, the first version follows the index
+// created without one
+func nextIndexVersion(index string, current string) string {
+	version := 0
+	if suffix, ok := strings.CutPrefix(current, index+indexVersionSeparator); ok {
+		version, _ = strconv.Atoi(suffix)
+	}
+	return fmt.Sprintf("%s%s%d", index, indexVersionSeparator, version+1)
+}
+
+// recreateIndex copies the documents of the current index, the name resolves to, to the next version of the index
+// created with the mapping and swaps the name over to it. Writes to the current index are blocked while the
+// documents are copied, so they fail rather than being lost, and reads are served by it until the swap. The swap
+// points the name, as an alias, at the next version and deletes the current index in one atomic step. When the copy
+// fails the writes are unblocked and the next version deleted again, when a next version is left over from a
+// migration which did not finish nothing is done.
+func recreateIndex(ctx context.Context, es *es.Client, index string, current string, mapping []byte, script string) error {
+	next := nextIndexVersion(index, current)
+
+	existsReq := esapi.IndicesExistsRequest{
+		Index: []string{next},
+	}
+	existsRes, err := existsReq.Do(ctx, es)
+	if err != nil {
+		return fmt.Errorf("error checking index version: %s", err)
+	}
+	existsRes.Body.Close()
+	if existsRes.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("index %s of a previous migration of %s exists, delete it before migrating again", next, index)
+	}
+
+	if err := createIndex(ctx, es, next, mapping); err != nil {
+		return err
+	}
+	if err := blockWrites(ctx, es, current, true); err != nil {
+		return errors.Join(err, deleteIndex(ctx, es, next))
+	}
+	if err := copyIndex(ctx, es, current, next, script); err != nil {
+		return errors.Join(err, blockWrites(ctx, es, current, false), deleteIndex(ctx, es, next))
+	}
+	if err := swapIndex(ctx, es, index, current, next); err != nil {
+		return errors.Join(err, blockWrites(ctx, es, current, false), deleteIndex(ctx, es, next))
+	}
+	return nil
+}
+
+// blockWrites blocks or unblocks the writes to the index
+func blockWrites(ctx context.Context, es *es.Client, index string, block bool) error {
+	body, err := json.Marshal(map[string]any{"index.blocks.write": block})
+	if err != nil {
+		return fmt.Errorf("error building index settings: %s", err)
+	}
+	req := esapi.IndicesPutSettingsRequest{
+		Index: []string{index},
+		Body:  bytes.NewReader(body),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return fmt.Errorf("error blocking writes to %s: %s", index, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error blocking writes to %s: %s", index, res.Status())
+	}
+	return nil
+}
+
+// swapIndex points the alias at the next index and deletes the current index in one atomic step, the current
+// index may have the name of the alias
+func swapIndex(ctx context.Context, es *es.Client, alias string, current string, next string) error {
+	body, err := json.Marshal(map[string]any{
+		"actions": []map[string]any{
+			{"add": map[string]any{"index": next, "alias": alias}},
+			{"remove_index": map[string]any{"index": current}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error building alias actions: %s", err)
+	}
+	req := esapi.IndicesUpdateAliasesRequest{
+		Body: bytes.NewReader(body),
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return fmt.Errorf("error swapping %s to %s: %s", alias, next, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error swapping %s to %s: %s", alias, next, res.Status())
+	}
+	return nil
+}
+
+// copyIndex copies all the documents of the source index to the destination index running the painless script,
+// when given, on each of them. It waits for the copy and fails unless every document was copied.
+func copyIndex(ctx context.Context, es *es.Client, source string, dest string, script string) error {
+	reindex := map[string]any{
+		"source": map[string]any{"index": source},
+		"dest":   map[string]any{"index": dest},
+	}
+	if script != "" {
+		reindex["script"] = map[string]any{"source": script, "lang": "painless"}
+	}
+	body, err := json.Marshal(reindex)
+	if err != nil {
+		return fmt.Errorf("error building reindex request: %s", err)
+	}
+
+	refresh := true
+	req := esapi.ReindexRequest{
+		Body:    bytes.NewReader(body),
+		Refresh: &refresh,
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return fmt.Errorf("error reindexing %s to %s: %s", source, dest, err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error reindexing %s to %s: %s", source, dest, res.Status())
+	}
+
+	var result struct {
+		Total    int   `json:"total"`
+		Created  int   `json:"created"`
+		Failures []any `json:"failures"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("error parsing reindex response: %s", err)
+	}
+	if len(result.Failures) > 0 || result.Created != result.Total {
+		return fmt.Errorf("error reindexing %s to %s: copied %d of %d documents", source, dest, result.Created, result.Total)
+	}
+	return nil
+}
+
+func deleteIndex(ctx context.Context, es *es.Client, index string) error {
+	req := esapi.IndicesDeleteRequest{
+		Index: []string{index},
+	}
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return fmt.Errorf("error deleting index: %s", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error deleting index: %s", res.Status())
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// baselineServiceCatalogueMapping is the mapping of a servicecatalogue index created with the first mapping of the
+// catalogue, after services with labels and links were written to it and elasticsearch mapped them dynamically, with
+// an attribute mapped from the attributes schema
+const baselineServiceCatalogueMapping = `{"mappings":{"properties":{
+	"attributes":{"type":"object","dynamic":false,"properties":{"costCenter":{"type":"keyword"}}},
+	"createdAt":{"type":"date"},
+	"createdBy":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}},
+	"description":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}},
+	"name":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}},
+	"serviceId":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}},
+	"updatedAt":{"type":"date"},
+	"updatedBy":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}},
+	"version":{"type":"long"},
+	"labels":{"properties":{"tier":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}}}},
+	"labelPairs":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}},
+	"links":{"properties":{"kind":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}},"url":{"type":"text","fields":{"keyword":{"type":"keyword","ignore_above":256}}}}}
+}}}`
+
+// fakeElasticsearch keeps the mappings, the aliases and the write blocks of the indices and records the requests
+// made to it. Copies fail while failReindex is set.
+type fakeElasticsearch struct {
+	mu            sync.Mutex
+	indices       map[string]indexMapping
+	aliases       map[string]string
+	writesBlocked map[string]bool
+	failReindex   bool
+	requests      []string
+	bodies        map[string][]string
+}
+
+func newFakeElasticsearch(t *testing.T, indices map[string]string) (*fakeElasticsearch, *es.Client) {
+	fake := &fakeElasticsearch{
+		indices:       map[string]indexMapping{},
+		aliases:       map[string]string{},
+		writesBlocked: map[string]bool{},
+		bodies:        map[string][]string{},
+	}
+	for index, mapping := range indices {
+		var m indexMapping
+		require.NoError(t, json.Unmarshal([]byte(mapping), &m))
+		fake.indices[index] = m
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client, err := es.NewClient(es.Config{Addresses: []string{server.URL}})
+	require.NoError(t, err)
+	return fake, client
+}
+
+func (f *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	body, _ := io.ReadAll(r.Body)
+	request := r.Method + " " + r.URL.Path
+	f.requests = append(f.requests, request)
+	f.bodies[request] = append(f.bodies[request], string(body))
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	index := parts[0]
+	if aliased, ok := f.aliases[index]; ok {
+		index = aliased
+	}
+	_, exists := f.indices[index]
+	switch {
+	case r.Method == http.MethodPost && index == "_reindex" && f.failReindex:
+		w.Write([]byte(`{"total":2,"created":1,"failures":[]}`))
+	case r.Method == http.MethodPost && index == "_reindex":
+		w.Write([]byte(`{"total":2,"created":2,"failures":[]}`))
+	case r.Method == http.MethodPost && index == "_aliases":
+		var aliases struct {
+			Actions []struct {
+				Add *struct {
+					Index string `json:"index"`
+					Alias string `json:"alias"`
+				} `json:"add"`
+				RemoveIndex *struct {
+					Index string `json:"index"`
+				} `json:"remove_index"`
+			} `json:"actions"`
+		}
+		json.Unmarshal(body, &aliases)
+		for _, action := range aliases.Actions {
+			if action.RemoveIndex != nil {
+				delete(f.indices, action.RemoveIndex.Index)
+				delete(f.writesBlocked, action.RemoveIndex.Index)
+			}
+		}
+		for _, action := range aliases.Actions {
+			if action.Add != nil {
+				f.aliases[action.Add.Alias] = action.Add.Index
+			}
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	case !exists && r.Method != http.MethodPut:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{}`))
+	case r.Method == http.MethodHead:
+	case r.Method == http.MethodDelete:
+		delete(f.indices, index)
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[1] == "_mapping":
+		json.NewEncoder(w).Encode(map[string]indexMapping{index: f.indices[index]})
+	case r.Method == http.MethodPut && len(parts) == 2 && parts[1] == "_settings":
+		var settings map[string]bool
+		json.Unmarshal(body, &settings)
+		f.writesBlocked[index] = settings["index.blocks.write"]
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodPut && len(parts) == 1:
+		if exists {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{}`))
+			return
+		}
+		var m indexMapping
+		json.Unmarshal(body, &m)
+		f.indices[index] = m
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodPut && len(parts) == 2 && parts[1] == "_mapping":
+		var m indexMapping
+		json.Unmarshal(body, &m.Mappings)
+		for field, property := range m.Mappings.Properties {
+			f.indices[index].Mappings.Properties[field] = property
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "_update_by_query":
+		w.Write([]byte(`{"task":"node:1"}`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{}`))
+	}
+}
+
+func (f *fakeElasticsearch) indexNames() []string {
+	names := []string{}
+	for index := range f.indices {
+		names = append(names, index)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *fakeElasticsearch) fieldTypes(index string, fields ...string) []string {
+	if aliased, ok := f.aliases[index]; ok {
+		index = aliased
+	}
+	types := []string{}
+	for _, field := range fields {
+		types = append(types, fieldType(f.indices[index].Mappings.Properties[field]))
+	}
+	return types
+}
+
+func TestMigrateIndex_RecreatesBaselineIndex(t *testing.T) {
+	fake, client := newFakeElasticsearch(t, map[string]string{"servicecatalogue": baselineServiceCatalogueMapping})
+
+	err := migrateIndex(context.Background(), client, "servicecatalogue", serviceCatalogueMapping, serviceCatalogueMigratedFields, labelPairsScript)
+	require.NoError(t, err)
+
+	// the dynamically mapped labels and links can not be changed in place so the documents are copied to a new
+	// version of the index, with the writes to the index blocked, which the name is then swapped over to
+	assert.Equal(t, []string{
+		"GET /servicecatalogue/_mapping",
+		"HEAD /servicecatalogue_v1",
+		"PUT /servicecatalogue_v1",
+		"PUT /servicecatalogue/_settings",
+		"POST /_reindex",
+		"POST /_aliases",
+	}, fake.requests)
+	assert.Equal(t, []string{`{"index.blocks.write":true}`}, fake.bodies["PUT /servicecatalogue/_settings"])
+	assert.Equal(t, map[string]string{"servicecatalogue": "servicecatalogue_v1"}, fake.aliases)
+	assert.Equal(t, []string{"servicecatalogue_v1"}, fake.indexNames())
+	assert.Equal(t, []string{"flattened", "keyword", "nested"}, fake.fieldTypes("servicecatalogue", "labels", "labelPairs", "links"))
+
+	// the attributes mapped from the attributes schema are kept
+	assert.JSONEq(t, `{"type":"object","dynamic":false,"properties":{"costCenter":{"type":"keyword"}}}`,
+		string(fake.indices["servicecatalogue_v1"].Mappings.Properties["attributes"]))
+
+	// the label pairs are backfilled on the copy
+	var reindex struct {
+		Source struct {
+			Index string `json:"index"`
+		} `json:"source"`
+		Dest struct {
+			Index string `json:"index"`
+		} `json:"dest"`
+		Script struct {
+			Source string `json:"source"`
+		} `json:"script"`
+	}
+	require.Len(t, fake.bodies["POST /_reindex"], 1)
+	require.NoError(t, json.Unmarshal([]byte(fake.bodies["POST /_reindex"][0]), &reindex))
+	assert.Equal(t, "servicecatalogue", reindex.Source.Index)
+	assert.Equal(t, "servicecatalogue_v1", reindex.Dest.Index)
+	assert.Equal(t, labelPairsScript, reindex.Script.Source)
+
+	// a migrated index is left alone
+	fake.requests = nil
+	require.NoError(t, migrateIndex(context.Background(), client, "servicecatalogue", serviceCatalogueMapping, serviceCatalogueMigratedFields, labelPairsScript))
+	assert.Equal(t, []string{"GET /servicecatalogue/_mapping"}, fake.requests)
+}
+
+func TestMigrateIndex_MapsMissingFieldsInPlace(t *testing.T) {
+	var baseline indexMapping
+	require.NoError(t, json.Unmarshal([]byte(baselineServiceCatalogueMapping), &baseline))
+	delete(baseline.Mappings.Properties, "labels")
+	delete(baseline.Mappings.Properties, "labelPairs")
+	delete(baseline.Mappings.Properties, "links")
+	mapping, err := json.Marshal(baseline)
+	require.NoError(t, err)
+	fake, client := newFakeElasticsearch(t, map[string]string{"servicecatalogue": string(mapping)})
+
+	err = migrateIndex(context.Background(), client, "servicecatalogue", serviceCatalogueMapping, serviceCatalogueMigratedFields, labelPairsScript)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"GET /servicecatalogue/_mapping",
+		"PUT /servicecatalogue/_mapping",
+		"POST /servicecatalogue/_update_by_query",
+	}, fake.requests)
+	require.Len(t, fake.bodies["POST /servicecatalogue/_update_by_query"], 1)
+	assert.Contains(t, fake.bodies["POST /servicecatalogue/_update_by_query"][0], "ctx._source.labelPairs = pairs")
+	assert.Equal(t, []string{"flattened", "keyword", "nested", "text"}, fake.fieldTypes("servicecatalogue", "labels", "labelPairs", "links", "name"))
+}
+
+func TestMigrateIndex_LeftOverIndexVersion(t *testing.T) {
+	fake, client := newFakeElasticsearch(t, map[string]string{
+		"servicecatalogue":    baselineServiceCatalogueMapping,
+		"servicecatalogue_v1": baselineServiceCatalogueMapping,
+	})
+
+	err := migrateIndex(context.Background(), client, "servicecatalogue", serviceCatalogueMapping, serviceCatalogueMigratedFields, "")
+	assert.ErrorContains(t, err, "index servicecatalogue_v1 of a previous migration of servicecatalogue exists")
+	assert.Contains(t, fake.indices, "servicecatalogue")
+	assert.NotContains(t, fake.requests, "PUT /servicecatalogue/_settings")
+}
+
+func TestMigrateIndex_FailedCopy(t *testing.T) {
+	fake, client := newFakeElasticsearch(t, map[string]string{"servicecatalogue": baselineServiceCatalogueMapping})
+	fake.failReindex = true
+
+	err := migrateIndex(context.Background(), client, "servicecatalogue", serviceCatalogueMapping, serviceCatalogueMigratedFields, labelPairsScript)
+	assert.ErrorContains(t, err, "copied 1 of 2 documents")
+
+	// the index is left as it was, writable and under its name
+	assert.Equal(t, []string{"servicecatalogue"}, fake.indexNames())
+	assert.Empty(t, fake.aliases)
+	assert.False(t, fake.writesBlocked["servicecatalogue"])
+}
+
+func TestNextIndexVersion(t *testing.T) {
+	assert.Equal(t, "servicecatalogue_v1", nextIndexVersion("servicecatalogue", "servicecatalogue"))
+	assert.Equal(t, "servicecatalogue_v2", nextIndexVersion("servicecatalogue", "servicecatalogue_v1"))
+	assert.Equal(t, "servicecatalogueversions_v1", nextIndexVersion("servicecatalogueversions", "servicecatalogueversions"))
+}
+
+func TestMigrateIndex_RecreatesIndexWithObjectLinks(t *testing.T) {
+	// links written before they were mapped are a plain object, which the nested link queries fail on
+	var baseline indexMapping
+	require.NoError(t, json.Unmarshal([]byte(baselineServiceCatalogueMapping), &baseline))
+	delete(baseline.Mappings.Properties, "labels")
+	delete(baseline.Mappings.Properties, "labelPairs")
+	mapping, err := json.Marshal(baseline)
+	require.NoError(t, err)
+	fake, client := newFakeElasticsearch(t, map[string]string{"servicecatalogueversions": string(mapping)})
+
+	err = migrateIndex(context.Background(), client, "servicecatalogueversions", serviceCatalogueVersionsMapping, serviceCatalogueVersionsMigratedFields, "")
+	require.NoError(t, err)
+
+	assert.Contains(t, fake.requests, "POST /_aliases")
+	assert.NotContains(t, fake.requests, "PUT /servicecatalogueversions/_mapping")
+	assert.Equal(t, []string{"flattened", "nested"}, fake.fieldTypes("servicecatalogueversions", "labels", "links"))
+}
@@ -3,6 +3,10 @@ package migrations
 var serviceCatalogueMapping = []byte(`{
 		"mappings": {
             "properties": {
+                "attributes": {
+                    "type": "object",
+                    "dynamic": false
+                },
                 "createdAt": {
                     "type": "date"
                 },
@@ -15,6 +19,25 @@ var serviceCatalogueMapping = []byte(`{
                         }
                     }
                 },
+                "deprecatedAt": {
+                    "type": "date"
+                },
+                "dependsOn": {
+                    "properties": {
+                        "serviceId": {
+                            "type": "text",
+                            "fields": {
+                                "keyword": {
+                                    "type": "keyword",
+                                    "ignore_above": 256
+                                }
+                            }
+                        },
+                        "type": {
+                            "type": "keyword"
+                        }
+                    }
+                },
                 "description": {
                     "type": "text",
                     "fields": {
@@ -24,7 +47,112 @@ var serviceCatalogueMapping = []byte(`{
                         }
                     }
                 },
+                "email": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "onCall": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "ownerTeam": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "slackChannel": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "technicalOwner": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "labels": {
+                    "type": "flattened"
+                },
+                "labelPairs": {
+                    "type": "keyword"
+                },
+                "lifecycle": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "links": {
+                    "type": "nested",
+                    "properties": {
+                        "environment": {
+                            "type": "keyword"
+                        },
+                        "host": {
+                            "type": "keyword"
+                        },
+                        "kind": {
+                            "type": "keyword"
+                        },
+                        "title": {
+                            "type": "text"
+                        },
+                        "url": {
+                            "type": "keyword",
+                            "ignore_above": 2048
+                        }
+                    }
+                },
+                "replacedBy": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "sunsetAt": {
+                    "type": "date"
+                },
                 "name": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        },
+                        "suggest": {
+                            "type": "search_as_you_type"
+                        }
+                    }
+                },
+                "orgId": {
                     "type": "text",
                     "fields": {
                         "keyword": {
@@ -60,3 +188,21 @@ var serviceCatalogueMapping = []byte(`{
             }
         }
 	}`)
+
+// nameSuggestMapping adds the search_as_you_type sub field of the name to indices created before it existed
+var nameSuggestMapping = []byte(`{
+            "properties": {
+                "name": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        },
+                        "suggest": {
+                            "type": "search_as_you_type"
+                        }
+                    }
+                }
+            }
+        }`)
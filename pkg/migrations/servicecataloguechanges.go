@@ -0,0 +1,49 @@
+package migrations
+
+var serviceCatalogueChangesMapping = []byte(`{
+		"mappings": {
+            "properties": {
+                "changeId": {
+                    "type": "keyword"
+                },
+                "operation": {
+                    "type": "keyword"
+                },
+                "orgId": {
+                    "type": "keyword"
+                },
+                "serviceId": {
+                    "type": "keyword"
+                },
+                "documentId": {
+                    "type": "keyword"
+                },
+                "version": {
+                    "type": "long"
+                },
+                "createdAt": {
+                    "type": "date"
+                },
+                "updatedAt": {
+                    "type": "date"
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "retryAt": {
+                    "type": "date"
+                },
+                "deadLetteredAt": {
+                    "type": "date"
+                },
+                "archive": {
+                    "type": "object",
+                    "enabled": false
+                },
+                "update": {
+                    "type": "object",
+                    "enabled": false
+                }
+            }
+        }
+	}`)
@@ -3,6 +3,10 @@ package migrations
 var serviceCatalogueVersionsMapping = []byte(`{
 		"mappings":{
             "properties": {
+                "attributes": {
+                    "type": "object",
+                    "dynamic": false
+                },
                 "createdAt": {
                     "type": "date"
                 },
@@ -18,6 +22,9 @@ var serviceCatalogueVersionsMapping = []byte(`{
                 "decomissionedAt": {
                     "type": "date"
                 },
+                "deleted": {
+                    "type": "boolean"
+                },
                 "decomissionedBy": {
                     "type": "text",
                     "fields": {
@@ -27,6 +34,25 @@ var serviceCatalogueVersionsMapping = []byte(`{
                         }
                     }
                 },
+                "deprecatedAt": {
+                    "type": "date"
+                },
+                "dependsOn": {
+                    "properties": {
+                        "serviceId": {
+                            "type": "text",
+                            "fields": {
+                                "keyword": {
+                                    "type": "keyword",
+                                    "ignore_above": 256
+                                }
+                            }
+                        },
+                        "type": {
+                            "type": "keyword"
+                        }
+                    }
+                },
                 "description": {
                     "type": "text",
                     "fields": {
@@ -36,6 +62,96 @@ var serviceCatalogueVersionsMapping = []byte(`{
                         }
                     }
                 },
+                "email": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "onCall": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "ownerTeam": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "slackChannel": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "technicalOwner": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "labels": {
+                    "type": "flattened"
+                },
+                "lifecycle": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "links": {
+                    "type": "nested",
+                    "properties": {
+                        "environment": {
+                            "type": "keyword"
+                        },
+                        "host": {
+                            "type": "keyword"
+                        },
+                        "kind": {
+                            "type": "keyword"
+                        },
+                        "title": {
+                            "type": "text"
+                        },
+                        "url": {
+                            "type": "keyword",
+                            "ignore_above": 2048
+                        }
+                    }
+                },
+                "replacedBy": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
+                "sunsetAt": {
+                    "type": "date"
+                },
                 "name": {
                     "type": "text",
                     "fields": {
@@ -45,6 +161,15 @@ var serviceCatalogueVersionsMapping = []byte(`{
                         }
                     }
                 },
+                "orgId": {
+                    "type": "text",
+                    "fields": {
+                        "keyword": {
+                            "type": "keyword",
+                            "ignore_above": 256
+                        }
+                    }
+                },
                 "parentId": {
                     "type": "text",
                     "fields": {
@@ -54,6 +179,16 @@ var serviceCatalogueVersionsMapping = []byte(`{
                         }
                     }
                 },
+                "transition": {
+                    "properties": {
+                        "from": {
+                            "type": "keyword"
+                        },
+                        "to": {
+                            "type": "keyword"
+                        }
+                    }
+                },
                 "version": {
                     "type": "long"
                 },
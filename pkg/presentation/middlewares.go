@@ -1,6 +1,7 @@
 package presentation
 
 import (
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"net/http"
@@ -55,6 +56,7 @@ func CORSMiddleware(c *gin.Context) {
 		"Authorization",
 		"Accept",
 		"X-Auth-Client-ID",
+		"If-Match",
 	}
 
 	corsHeaders := map[string]string{
@@ -62,6 +64,7 @@ func CORSMiddleware(c *gin.Context) {
 		"Access-Control-Allow-Headers":     strings.Join(allowedCorsHeaders, ", "),
 		"Access-Control-Allow-Methods":     "POST, OPTIONS, GET, PUT, PATCH, DELETE",
 		"Access-Control-Allow-Origin":      origin,
+		"Access-Control-Expose-Headers":    "ETag",
 	}
 
 	for key, value := range corsHeaders {
@@ -137,11 +140,24 @@ func PanicRecovery() gin.HandlerFunc {
 	return nice.Recovery(panicHandler)
 }
 
-// PoorMansBasicAuthenticationMiddleware is a simple basic auth middleware with hard coded credentials
-// This is not a recommended way to authenticate requests, see [Authentication](https://github.com/nikki-noceps/serviceCatalogue/tree/main/docs)
+// PoorMansBasicAuthenticationMiddleware is a simple basic auth middleware with hard coded credentials and the principals
+// of the config. This is not a recommended way to authenticate requests, see [Authentication](https://github.com/nikki-noceps/serviceCatalogue/tree/main/docs)
 // Rejects all non GET requests if authentication header is missing.
-// Only accepts Basic authentication
-func PoorMansBasicAuthenticationMiddleware(c *gin.Context) {
+// Only accepts Basic authentication. The request is scoped to the organization and the roles of the authenticated
+// principal.
+func PoorMansBasicAuthenticationMiddleware(auth config.Auth) gin.HandlerFunc {
+	principals := map[string]config.Principal{
+		config.Username: {Username: config.Username, Password: config.Password, OrgId: config.DefaultOrgId, Roles: []string{config.RoleAdmin}},
+	}
+	for _, principal := range auth.Principals {
+		principals[principal.Username] = principal
+	}
+	return func(c *gin.Context) {
+		basicAuthentication(c, principals)
+	}
+}
+
+func basicAuthentication(c *gin.Context, principals map[string]config.Principal) {
 	authHeader := c.GetHeader("Authorization")
 
 	if authHeader == "" && c.Request.Method != http.MethodGet {
@@ -166,11 +182,19 @@ func PoorMansBasicAuthenticationMiddleware(c *gin.Context) {
 
 	// Split credentials into username and password
 	parts := strings.SplitN(string(credentials), ":", 2)
-	if len(parts) != 2 || parts[0] != config.Username || parts[1] != config.Password {
+	if len(parts) != 2 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	principal, ok := principals[parts[0]]
+	if !ok || subtle.ConstantTimeCompare([]byte(parts[1]), []byte(principal.Password)) != 1 {
 		c.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
 
-	// If authentication is successful, call the next handler
+	// If authentication is successful, scope the request to the organization and the roles of the principal and
+	// call the next handler
+	cctx := context.CustomContextFromContext(c.Request.Context())
+	c.Request = c.Request.WithContext(context.WithRoles(context.WithOrgID(cctx, principal.OrgId), principal.Roles))
 	c.Next()
 }
@@ -41,9 +41,9 @@ func NewRouter(ctx context.Context, cfg *config.Configuration, svc *services.Ser
 		loggerMiddleware(),
 		ErrorMiddleware,
 		PanicRecovery(),
-		PoorMansBasicAuthenticationMiddleware,
+		PoorMansBasicAuthenticationMiddleware(cfg.Auth),
 	)
-	setupRoutes(ctx, router, handlers.NewHandler(svc))
+	setupRoutes(ctx, router, handlers.NewHandler(svc, cfg.Deprecations))
 	return router, nil
 }
 
@@ -57,10 +57,28 @@ func setupRoutes(ctx context.Context, router *gin.Engine, handler *handlers.Hand
 	})
 	router.GET("/serviceCatalogue", handler.ListSvcCatalogue)
 	router.POST("/serviceCatalogue", handler.CreateSvcCatalogue)
+	// deprecated, `search` on the listing above combines the search with the filters
 	router.GET("/serviceCatalogue/search", handler.SearchSvcCatalogue)
+	router.GET("/serviceCatalogue/suggest", handler.SuggestSvcCatalogue)
+	router.GET("/serviceCatalogue/deleted", handler.ListDeletedServices)
+	router.GET("/serviceCatalogue/deprecations", handler.ListDeprecations)
 	router.GET("/serviceCatalogue/:serviceId", handler.FetchServiceById)
 	router.PATCH("/serviceCatalogue/:serviceId", handler.UpdateSvcCatalogue)
 	router.DELETE("/serviceCatalogue/:serviceId", handler.DeleteService)
+	router.POST("/serviceCatalogue/:serviceId/restore", handler.RestoreDeletedService)
 	router.GET("/serviceCatalogue/:serviceId/versions", handler.ListServiceCatalogueVersions)
+	router.GET("/serviceCatalogue/:serviceId/diff", handler.DiffServiceCatalogueVersions)
+	router.GET("/serviceCatalogue/:serviceId/dependencies", handler.ListServiceDependencies)
+	router.GET("/serviceCatalogue/:serviceId/dependents", handler.ListServiceDependents)
+	router.GET("/serviceCatalogue/:serviceId/impact", handler.ServiceImpact)
+	router.POST("/serviceCatalogue/:serviceId/versions/:versionId/restore", handler.RestoreServiceCatalogueVersion)
 	router.GET("/serviceCatalogue/versions/:versionId", handler.FetchServiceCatalogueVersionById)
+	router.GET("/attributesSchema", handler.FetchAttributesSchema)
+	router.PUT("/attributesSchema", handler.SaveAttributesSchema)
+	router.GET("/teams", handler.ListTeams)
+	router.POST("/teams", handler.CreateTeam)
+	router.GET("/teams/:teamId", handler.FetchTeamById)
+	router.PATCH("/teams/:teamId", handler.UpdateTeam)
+	router.DELETE("/teams/:teamId", handler.DeleteTeam)
+	router.GET("/teams/:teamId/services", handler.ListTeamServices)
 }